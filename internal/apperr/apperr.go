@@ -0,0 +1,130 @@
+// Package apperr defines the domain errors that repositories and handlers
+// return for conditions a client can act on. Each error has a Kind, which
+// decides the HTTP status, a stable machine-readable Code and a message that
+// is safe to show to clients. Any other error is treated as internal and its
+// text never leaves the server.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindPreconditionFailed
+	KindUnprocessable
+	KindTooLarge
+	KindUnsupportedMedia
+	KindFailedDependency
+	KindNotImplemented
+)
+
+var statuses = map[Kind]int{
+	KindInternal:           http.StatusInternalServerError,
+	KindBadRequest:         http.StatusBadRequest,
+	KindValidation:         http.StatusBadRequest,
+	KindUnauthorized:       http.StatusUnauthorized,
+	KindForbidden:          http.StatusForbidden,
+	KindNotFound:           http.StatusNotFound,
+	KindConflict:           http.StatusConflict,
+	KindPreconditionFailed: http.StatusPreconditionFailed,
+	KindUnprocessable:      http.StatusUnprocessableEntity,
+	KindTooLarge:           http.StatusRequestEntityTooLarge,
+	KindUnsupportedMedia:   http.StatusUnsupportedMediaType,
+	KindFailedDependency:   http.StatusFailedDependency,
+	KindNotImplemented:     http.StatusNotImplemented,
+}
+
+// Status returns the HTTP status for errors of kind k.
+func (k Kind) Status() int {
+	if status, ok := statuses[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is a domain error. Fields holds per-field messages keyed by the JSON
+// name of the field, for validation errors.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  map[string]string
+
+	// base is the sentinel this error was derived from with Withf, so that
+	// errors.Is still matches it.
+	base *Error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return e.base != nil && e.base == target
+}
+
+// Withf returns an error of the same kind and code with a more specific
+// message. The result matches e with errors.Is.
+func (e *Error) Withf(format string, args ...any) *Error {
+	return &Error{Kind: e.Kind, Code: e.Code, Message: fmt.Sprintf(format, args...), Fields: e.Fields, base: e}
+}
+
+func New(kind Kind, code, format string, args ...any) *Error {
+	return &Error{Kind: kind, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func BadRequest(code, format string, args ...any) *Error {
+	return New(KindBadRequest, code, format, args...)
+}
+
+func Unauthorized(code, format string, args ...any) *Error {
+	return New(KindUnauthorized, code, format, args...)
+}
+
+func Forbidden(code, format string, args ...any) *Error {
+	return New(KindForbidden, code, format, args...)
+}
+
+func NotFound(code, format string, args ...any) *Error {
+	return New(KindNotFound, code, format, args...)
+}
+
+func Conflict(code, format string, args ...any) *Error {
+	return New(KindConflict, code, format, args...)
+}
+
+// Validation reports invalid request fields, keyed by their JSON names.
+func Validation(fields map[string]string) *Error {
+	return &Error{Kind: KindValidation, Code: "validation_failed", Message: "request validation failed", Fields: fields}
+}
+
+// Field reports a single invalid field.
+func Field(name, message string) *Error {
+	return Validation(map[string]string{name: message})
+}
+
+// As returns the domain error in err's chain, if any.
+func As(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// IsNotFound reports whether err is a not-found domain error.
+func IsNotFound(err error) bool {
+	e, ok := As(err)
+	return ok && e.Kind == KindNotFound
+}
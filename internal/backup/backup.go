@@ -0,0 +1,240 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	filePrefix = "chinook-"
+	fileSuffix = ".db"
+	// Nanoseconds keep two backups taken in the same second apart.
+	timeLayout = "20060102T150405.000000000Z"
+	// legacyTimeLayout names the backups written before sub-second names,
+	// which List still picks up so that they are pruned in turn.
+	legacyTimeLayout = "20060102T150405Z"
+)
+
+// now is the clock backups are named by; tests stop it.
+var now = time.Now
+
+// Manager writes consistent snapshots of the live SQLite database into Dir
+// and keeps at most Retention of them.
+type Manager struct {
+	DB        *database.DB
+	Dir       string
+	Retention int
+
+	mu sync.Mutex
+}
+
+// Create writes a new snapshot and prunes old ones beyond the retention count.
+func (m *Manager) Create(ctx context.Context) (models.Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return models.Backup{}, fmt.Errorf("creating backup directory: %w", err)
+	}
+
+	createdAt := now().UTC()
+	name := filePrefix + createdAt.Format(timeLayout) + fileSuffix
+	path := filepath.Join(m.Dir, name)
+	// The rename below would replace an existing backup without a word, so
+	// a name that is already taken is an error rather than an overwrite.
+	if _, err := os.Lstat(path); err == nil {
+		return models.Backup{}, fmt.Errorf("backup %s: %w", name, os.ErrExist)
+	} else if !os.IsNotExist(err) {
+		return models.Backup{}, fmt.Errorf("checking backup name: %w", err)
+	}
+	// VACUUM INTO refuses to overwrite, and a half-written file must never
+	// look like a finished backup, so write under a temporary name first.
+	tmp := path + ".partial"
+	os.Remove(tmp)
+
+	start := time.Now()
+	if err := m.DB.Snapshot(ctx, tmp); err != nil {
+		os.Remove(tmp)
+		log.Error().Err(err).Str("path", path).Msg("Backup failed")
+		return models.Backup{}, fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return models.Backup{}, fmt.Errorf("finalizing snapshot: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return models.Backup{}, fmt.Errorf("reading snapshot: %w", err)
+	}
+	log.Info().Str("path", path).Int64("bytes", info.Size()).Dur("took", time.Since(start)).Msg("Backup created")
+
+	if err := m.prune(); err != nil {
+		log.Error().Err(err).Msg("Failed to prune old backups")
+	}
+	return models.Backup{Name: name, Path: path, Size: info.Size(), CreatedAt: createdAt}, nil
+}
+
+// List returns the snapshots in Dir, newest first.
+func (m *Manager) List() ([]models.Backup, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []models.Backup{}, nil
+		}
+		return nil, fmt.Errorf("reading backup directory: %w", err)
+	}
+
+	backups := []models.Backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+		createdAt, err := time.Parse(timeLayout, stamp)
+		if err != nil {
+			if createdAt, err = time.Parse(legacyTimeLayout, stamp); err != nil {
+				continue
+			}
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, models.Backup{
+			Name:      name,
+			Path:      filepath.Join(m.Dir, name),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+func (m *Manager) prune() error {
+	if m.Retention <= 0 {
+		return nil
+	}
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(m.Retention, len(backups)):] {
+		if err := os.Remove(b.Path); err != nil {
+			return fmt.Errorf("removing %s: %w", b.Name, err)
+		}
+		log.Info().Str("path", b.Path).Msg("Pruned old backup")
+	}
+	return nil
+}
+
+// Schedule creates a backup every interval until ctx is cancelled.
+func (m *Manager) Schedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	log.Info().Dur("interval", interval).Str("dir", m.Dir).Int("retention", m.Retention).Msg("Scheduled backups enabled")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Errors are already logged by Create.
+			m.Create(ctx)
+		}
+	}
+}
+
+// Restore replaces the SQLite database at dbPath with the snapshot at src.
+// The snapshot must pass PRAGMA integrity_check first. The previous database
+// and its WAL files are kept next to it with a ".pre-restore-<time>"
+// suffix, so that every restore can be undone. The
+// server must not be running against dbPath.
+func Restore(ctx context.Context, src, dbPath string) error {
+	if err := database.IntegrityCheck(ctx, src); err != nil {
+		return err
+	}
+
+	// Copy next to the target so the final rename stays on one filesystem.
+	tmp := dbPath + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("copying snapshot: %w", err)
+	}
+	if err := database.IntegrityCheck(ctx, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("verifying copied snapshot: %w", err)
+	}
+
+	// A WAL left behind by the old database would be replayed on top of the
+	// restored file, so it moves aside together with the database. The
+	// suffix is unique to this restore, so an earlier restore's copy of the
+	// original database is never replaced.
+	aside := ".pre-restore-" + now().UTC().Format(timeLayout)
+	var moved []string
+	putBack := func() {
+		for _, old := range moved {
+			if err := os.Rename(old+aside, old); err != nil {
+				log.Error().Err(err).Str("path", old+aside).Msg("Failed to move back the previous database")
+			}
+		}
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		old := dbPath + suffix
+		if _, err := os.Stat(old); err == nil {
+			if _, err := os.Lstat(old + aside); err == nil {
+				putBack()
+				os.Remove(tmp)
+				return fmt.Errorf("moving aside %s: %s: %w", old, old+aside, os.ErrExist)
+			}
+			if err := os.Rename(old, old+aside); err != nil {
+				putBack()
+				os.Remove(tmp)
+				return fmt.Errorf("moving aside %s: %w", old, err)
+			}
+			moved = append(moved, old)
+		}
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		os.Remove(tmp)
+		putBack()
+		return fmt.Errorf("swapping in snapshot: %w", err)
+	}
+	log.Info().Str("from", src).Str("to", dbPath).Str("previous", dbPath+aside).Msg("Database restored")
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
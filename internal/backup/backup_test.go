@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"chinook-api/internal/database/dbtest"
+)
+
+func TestCreateWithinOneSecond(t *testing.T) {
+	ctx := context.Background()
+	m := &Manager{DB: dbtest.SQLite(t), Dir: t.TempDir()}
+
+	first, err := m.Create(ctx)
+	if err != nil {
+		t.Fatalf("first Create: %v", err)
+	}
+	second, err := m.Create(ctx)
+	if err != nil {
+		t.Fatalf("second Create: %v", err)
+	}
+	if first.Name == second.Name {
+		t.Fatalf("two backups share the name %s", first.Name)
+	}
+
+	// one left over from before sub-second names
+	legacy := filePrefix + "20250101T000000Z" + fileSuffix
+	if err := os.WriteFile(filepath.Join(m.Dir, legacy), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(backups) != 3 || backups[0].Name != second.Name || backups[1].Name != first.Name || backups[2].Name != legacy {
+		t.Errorf("List = %+v, want %s, %s and %s", backups, second.Name, first.Name, legacy)
+	}
+}
+
+func TestCreateRefusesExistingName(t *testing.T) {
+	stopped := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return stopped }
+	t.Cleanup(func() { now = time.Now })
+
+	m := &Manager{DB: dbtest.SQLite(t), Dir: t.TempDir()}
+	b, err := m.Create(context.Background())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	before, err := os.ReadFile(b.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create(context.Background()); !errors.Is(err, os.ErrExist) {
+		t.Errorf("second Create at the same instant: err = %v, want os.ErrExist", err)
+	}
+	if after, err := os.ReadFile(b.Path); err != nil || !bytes.Equal(before, after) {
+		t.Errorf("the first backup was changed: %v", err)
+	}
+}
+
+func TestBackupJSONHidesPath(t *testing.T) {
+	m := &Manager{DB: dbtest.SQLite(t), Dir: t.TempDir()}
+	b, err := m.Create(context.Background())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	out, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), m.Dir) || strings.Contains(string(out), `"path"`) {
+		t.Errorf("backup JSON %s exposes the server path", out)
+	}
+}
+
+func TestRestoreKeepsEveryPrevious(t *testing.T) {
+	ctx := context.Background()
+	m := &Manager{DB: dbtest.SQLite(t), Dir: t.TempDir()}
+	b, err := m.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "chinook.db")
+	if err := os.WriteFile(dbPath, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := Restore(ctx, b.Path, dbPath); err != nil {
+			t.Fatalf("restore %d: %v", i+1, err)
+		}
+	}
+	previous, err := filepath.Glob(dbPath + ".pre-restore-*")
+	if err != nil || len(previous) != 2 {
+		t.Fatalf("moved-aside files = %v, %v; want one per restore", previous, err)
+	}
+	kept := false
+	for _, p := range previous {
+		if data, _ := os.ReadFile(p); string(data) == "original" {
+			kept = true
+		}
+	}
+	if !kept {
+		t.Errorf("the second restore lost the original database; kept %v", previous)
+	}
+}
+
+func TestRestorePutsBackOnFailure(t *testing.T) {
+	stopped := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return stopped }
+	t.Cleanup(func() { now = time.Now })
+
+	ctx := context.Background()
+	m := &Manager{DB: dbtest.SQLite(t), Dir: t.TempDir()}
+	b, err := m.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "chinook.db")
+	for _, name := range []string{dbPath, dbPath + "-wal"} {
+		if err := os.WriteFile(name, []byte("original"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the WAL cannot be moved aside after the database has been
+	taken := dbPath + "-wal.pre-restore-" + stopped.Format(timeLayout)
+	if err := os.WriteFile(taken, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(ctx, b.Path, dbPath); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Restore: err = %v, want os.ErrExist", err)
+	}
+	for _, name := range []string{dbPath, dbPath + "-wal"} {
+		if data, err := os.ReadFile(name); err != nil || string(data) != "original" {
+			t.Errorf("%s after a failed restore = %q, %v; want the original", filepath.Base(name), data, err)
+		}
+	}
+	if leftover, _ := filepath.Glob(filepath.Join(dir, "chinook.db.*")); len(leftover) != 0 {
+		t.Errorf("a failed restore left %v behind", leftover)
+	}
+}
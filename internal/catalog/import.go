@@ -0,0 +1,657 @@
+// Package catalog imports label deliveries: CSV or JSON files with one
+// track per row, naming its artist, album, genre and media type. Those are
+// matched by name, ignoring case, and created when no existing one matches;
+// the track itself is matched by name on its album.
+package catalog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+	"chinook-api/internal/search"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Format is the file format of a delivery.
+type Format string
+
+const (
+	CSV  Format = "csv"
+	JSON Format = "json"
+)
+
+// Conflict strategies decide what happens to a row whose track already
+// exists on its album.
+const (
+	// Skip leaves the existing track as it is.
+	Skip = "skip"
+	// Update overwrites the existing track with the row.
+	Update = "update"
+	// Fail reports the row as an error, so nothing is imported.
+	Fail = "fail"
+)
+
+// Options control an import.
+type Options struct {
+	Format     Format
+	OnConflict string
+	// DryRun reports what the import would do without writing anything.
+	DryRun bool
+	// Progress, if set, is called after each row.
+	Progress func()
+}
+
+// ErrInvalidFile is returned when the file as a whole cannot be read, as
+// opposed to single rows that are invalid.
+var ErrInvalidFile = apperr.BadRequest("invalid_import", "import file is not valid")
+
+// columns are the columns of a delivery; the required ones must be in every
+// CSV header.
+var columns = []struct {
+	name     string
+	required bool
+}{
+	{"artist", true},
+	{"album", true},
+	{"track", true},
+	{"genre", true},
+	{"media_type", true},
+	{"price", true},
+	{"composer", false},
+	{"milliseconds", false},
+	{"bytes", false},
+}
+
+// Importer imports deliveries into DB and tells Watchers about the artists,
+// albums and tracks it creates.
+type Importer struct {
+	DB       *database.DB
+	Watchers search.Watchers
+}
+
+// Import reads the delivery from r and imports it in one transaction. Every
+// row is checked and reported; the import is applied only when no row
+// failed and it is not a dry run. The error is set only when the file cannot
+// be read at all or the database fails.
+func (im *Importer) Import(ctx context.Context, r io.Reader, opts Options) (models.ImportReport, error) {
+	report := models.ImportReport{
+		DryRun:        opts.DryRun,
+		OnConflict:    opts.OnConflict,
+		NewArtists:    []string{},
+		NewAlbums:     []string{},
+		NewGenres:     []string{},
+		NewMediaTypes: []string{},
+		Results:       []models.ImportRow{},
+	}
+	switch opts.OnConflict {
+	case Skip, Update, Fail:
+	default:
+		return report, apperr.Field("on_conflict", "must be one of skip update fail")
+	}
+	rows, err := newRowReader(r, opts.Format)
+	if err != nil {
+		return report, err
+	}
+
+	tx, err := im.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin import transaction")
+		return report, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	run := &importRun{
+		tx:         tx,
+		opts:       opts,
+		report:     &report,
+		artists:    map[string]int{},
+		albums:     map[string]int{},
+		genres:     map[string]int{},
+		mediaTypes: map[string]int{},
+	}
+	for n := 1; ; n++ {
+		values, rowErrs, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		result := models.ImportRow{Row: n}
+		if len(rowErrs) == 0 {
+			var row Row
+			if row, rowErrs = parseRow(values); len(rowErrs) == 0 {
+				if rowErrs, err = run.apply(ctx, row, &result); err != nil {
+					return report, err
+				}
+			}
+		}
+		if len(rowErrs) > 0 {
+			result = models.ImportRow{Row: n, Action: "error", Errors: rowErrs}
+		}
+		report.Rows++
+		switch result.Action {
+		case "create":
+			report.Created++
+		case "update":
+			report.Updated++
+		case "unchanged":
+			report.Unchanged++
+		case "skip":
+			report.Skipped++
+		case "error":
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+		if opts.Progress != nil {
+			opts.Progress()
+		}
+	}
+
+	if opts.DryRun || report.Failed > 0 {
+		// ids of tracks that were never committed mean nothing
+		for i := range report.Results {
+			if report.Results[i].Action == "create" {
+				report.Results[i].TrackID = 0
+			}
+		}
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit import")
+		return report, fmt.Errorf("error committing import: %w", err)
+	}
+	report.Applied = true
+	for _, e := range run.created {
+		im.Watchers.Upsert(e)
+	}
+	log.Info().Int("rows", report.Rows).Int("created", report.Created).Int("updated", report.Updated).Msg("Catalog imported")
+	return report, nil
+}
+
+// Row is a parsed row of a delivery.
+type Row struct {
+	Artist       string
+	Album        string
+	Track        string
+	Genre        string
+	MediaType    string
+	UnitPrice    float64
+	Composer     *string
+	Milliseconds int
+	Bytes        *int
+}
+
+// parseRow checks the values of a row, keyed by column, and reports the
+// invalid ones by column.
+func parseRow(values map[string]string) (Row, map[string]string) {
+	var row Row
+	errs := map[string]string{}
+	text := func(column string, max int) string {
+		v := strings.TrimSpace(values[column])
+		if utf8.RuneCountInString(v) > max {
+			errs[column] = fmt.Sprintf("must be at most %d characters", max)
+		}
+		return v
+	}
+	required := func(column string, max int) string {
+		v := text(column, max)
+		if v == "" {
+			errs[column] = "is required"
+		}
+		return v
+	}
+	integer := func(column string, min int) *int {
+		raw := strings.TrimSpace(values[column])
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			errs[column] = "must be an integer"
+			return nil
+		}
+		if n < min {
+			errs[column] = fmt.Sprintf("must be at least %d", min)
+		}
+		return &n
+	}
+
+	row.Artist = required("artist", 120)
+	row.Album = required("album", 160)
+	row.Track = required("track", 200)
+	row.Genre = required("genre", 120)
+	row.MediaType = required("media_type", 120)
+	if composer := text("composer", 220); composer != "" {
+		row.Composer = &composer
+	}
+	if ms := integer("milliseconds", 1); ms != nil {
+		row.Milliseconds = *ms
+	}
+	row.Bytes = integer("bytes", 0)
+
+	switch raw := strings.TrimSpace(values["price"]); {
+	case raw == "":
+		errs["price"] = "is required"
+	default:
+		price, err := strconv.ParseFloat(raw, 64)
+		switch {
+		case err != nil || math.IsNaN(price) || math.IsInf(price, 0):
+			errs["price"] = "must be a number"
+		case price < 0:
+			errs["price"] = "must be at least 0"
+		case price >= 100000000:
+			errs["price"] = "must be less than 100000000"
+		}
+		row.UnitPrice = price
+	}
+	if len(errs) > 0 {
+		return row, errs
+	}
+	return row, nil
+}
+
+// rowReader reads the rows of a delivery as values keyed by column. Errors
+// that only affect one row are returned as field errors; err is set when
+// the file cannot be read on.
+type rowReader interface {
+	next() (values map[string]string, rowErrs map[string]string, err error)
+}
+
+func newRowReader(r io.Reader, format Format) (rowReader, error) {
+	switch format {
+	case CSV:
+		return newCSVRows(r)
+	case JSON:
+		return newJSONRows(r)
+	}
+	return nil, apperr.Field("format", "must be one of csv json")
+}
+
+type csvRows struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVRows(r io.Reader) (*csvRows, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrInvalidFile.Withf("the file is empty")
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	seen := map[string]bool{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = columnName(name)
+		if !knownColumn(name) {
+			return nil, ErrInvalidFile.Withf("unknown column %q; the columns are %s", header[i], columnList())
+		}
+		if seen[name] {
+			return nil, ErrInvalidFile.Withf("column %q appears twice", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	for _, c := range columns {
+		if c.required && !seen[c.name] {
+			return nil, ErrInvalidFile.Withf("missing column %q; the columns are %s", c.name, columnList())
+		}
+	}
+	return &csvRows{r: cr, header: header}, nil
+}
+
+func (c *csvRows) next() (map[string]string, map[string]string, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, csvError(err)
+	}
+	if len(record) != len(c.header) {
+		return nil, map[string]string{"row": fmt.Sprintf("has %d fields, the header has %d", len(record), len(c.header))}, nil
+	}
+	values := make(map[string]string, len(record))
+	for i, v := range record {
+		values[c.header[i]] = v
+	}
+	return values, nil, nil
+}
+
+// csvError reports a CSV syntax error, after which the rest of the file
+// cannot be trusted.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return ErrInvalidFile.Withf("line %d: %v", parseErr.Line, parseErr.Err)
+	}
+	return err
+}
+
+// columnName normalizes a header such as "Media Type" to media_type.
+// unit_price is accepted for price, as tracks call it.
+func columnName(header string) string {
+	name := strings.ToLower(strings.TrimSpace(header))
+	name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+	if name == "unit_price" {
+		return "price"
+	}
+	return name
+}
+
+func knownColumn(name string) bool {
+	for _, c := range columns {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func columnList() string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// jsonRows reads a JSON array of row objects one element at a time.
+type jsonRows struct {
+	dec *json.Decoder
+}
+
+func newJSONRows(r io.Reader) (*jsonRows, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil && !isJSONSyntax(err) {
+		return nil, err
+	}
+	if err != nil || tok != json.Delim('[') {
+		return nil, ErrInvalidFile.Withf("a JSON import must be an array of rows")
+	}
+	return &jsonRows{dec: dec}, nil
+}
+
+func (j *jsonRows) next() (map[string]string, map[string]string, error) {
+	if !j.dec.More() {
+		if _, err := j.dec.Token(); err != nil {
+			if !isJSONSyntax(err) {
+				return nil, nil, err
+			}
+			return nil, nil, ErrInvalidFile.Withf("the JSON array is not closed")
+		}
+		return nil, nil, io.EOF
+	}
+	var object map[string]any
+	if err := j.dec.Decode(&object); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			// the decoder has read past the element and can go on
+			return nil, map[string]string{"row": "must be an object"}, nil
+		}
+		if !isJSONSyntax(err) {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidFile.Withf("the file is not valid JSON")
+	}
+
+	values := map[string]string{}
+	errs := map[string]string{}
+	for key, v := range object {
+		name := columnName(key)
+		if !knownColumn(name) {
+			errs[key] = "is not a known field"
+			continue
+		}
+		switch v := v.(type) {
+		case nil:
+		case string:
+			values[name] = v
+		case json.Number:
+			values[name] = v.String()
+		default:
+			errs[key] = "must be a string or number"
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs, nil
+	}
+	return values, nil, nil
+}
+
+// isJSONSyntax reports whether err is a fault of the file rather than of
+// reading it, such as a body over the size limit.
+func isJSONSyntax(err error) bool {
+	var syntaxErr *json.SyntaxError
+	return errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// importRun is the state of one import: the transaction, the ids resolved
+// so far, keyed by lowercased name, and the catalog entries created.
+type importRun struct {
+	tx      *database.Tx
+	opts    Options
+	report  *models.ImportReport
+	created []search.Entry
+
+	artists    map[string]int
+	albums     map[string]int
+	genres     map[string]int
+	mediaTypes map[string]int
+}
+
+// apply writes one valid row. It returns the row's errors, such as a
+// conflict under the fail strategy, or err when the database fails.
+func (run *importRun) apply(ctx context.Context, row Row, result *models.ImportRow) (map[string]string, error) {
+	artistID, err := run.resolve(run.artists, strings.ToLower(row.Artist), &run.report.NewArtists, row.Artist,
+		func() (int, error) {
+			return run.lookup(ctx, "SELECT ArtistId FROM Artist WHERE LOWER(Name) = LOWER(?)", row.Artist)
+		},
+		func() (int, error) {
+			id, err := run.insert(ctx, "ArtistId", "INSERT INTO Artist (Name) VALUES (?)", row.Artist)
+			run.created = append(run.created, search.Entry{Kind: search.Artist, ID: id, Name: row.Artist})
+			return id, err
+		})
+	if err != nil {
+		return nil, err
+	}
+	albumKey := strconv.Itoa(artistID) + "\x00" + strings.ToLower(row.Album)
+	albumID, err := run.resolve(run.albums, albumKey, &run.report.NewAlbums, row.Artist+" - "+row.Album,
+		func() (int, error) {
+			return run.lookup(ctx, "SELECT AlbumId FROM Album WHERE ArtistId = ? AND LOWER(Title) = LOWER(?)", artistID, row.Album)
+		},
+		func() (int, error) {
+			id, err := run.insert(ctx, "AlbumId", "INSERT INTO Album (Title, ArtistId) VALUES (?, ?)", row.Album, artistID)
+			run.created = append(run.created, search.Entry{Kind: search.Album, ID: id, Name: row.Album})
+			return id, err
+		})
+	if err != nil {
+		return nil, err
+	}
+	genreID, err := run.resolve(run.genres, strings.ToLower(row.Genre), &run.report.NewGenres, row.Genre,
+		func() (int, error) {
+			return run.lookup(ctx, "SELECT GenreId FROM Genre WHERE LOWER(Name) = LOWER(?)", row.Genre)
+		},
+		func() (int, error) {
+			return run.insert(ctx, "GenreId", "INSERT INTO Genre (Name) VALUES (?)", row.Genre)
+		})
+	if err != nil {
+		return nil, err
+	}
+	mediaTypeID, err := run.resolve(run.mediaTypes, strings.ToLower(row.MediaType), &run.report.NewMediaTypes, row.MediaType,
+		func() (int, error) {
+			return run.lookup(ctx, "SELECT MediaTypeId FROM MediaType WHERE LOWER(Name) = LOWER(?)", row.MediaType)
+		},
+		func() (int, error) {
+			return run.insert(ctx, "MediaTypeId", "INSERT INTO MediaType (Name) VALUES (?)", row.MediaType)
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := run.findTrack(ctx, albumID, row.Track)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		id, err := run.insert(ctx, "TrackId", `
+			INSERT INTO Track (Name, AlbumId, MediaTypeId, GenreId, Composer, Milliseconds, Bytes, UnitPrice)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, row.Track, albumID, mediaTypeID, genreID, row.Composer, row.Milliseconds, row.Bytes, row.UnitPrice)
+		if err != nil {
+			return nil, err
+		}
+		run.created = append(run.created, search.Entry{Kind: search.Track, ID: id, Name: row.Track})
+		result.Action, result.TrackID = "create", id
+		return nil, nil
+	}
+
+	result.TrackID = existing.id
+	switch run.opts.OnConflict {
+	case Skip:
+		result.Action = "skip"
+		return nil, nil
+	case Fail:
+		return map[string]string{"track": fmt.Sprintf("already exists on this album as track %d", existing.id)}, nil
+	}
+
+	changes := map[string]models.ImportChange{}
+	if !strings.EqualFold(existing.genre, row.Genre) {
+		changes["genre"] = models.ImportChange{From: existing.genre, To: row.Genre}
+	}
+	if !strings.EqualFold(existing.mediaType, row.MediaType) {
+		changes["media_type"] = models.ImportChange{From: existing.mediaType, To: row.MediaType}
+	}
+	if math.Abs(existing.unitPrice-row.UnitPrice) >= 0.005 {
+		changes["price"] = models.ImportChange{From: existing.unitPrice, To: row.UnitPrice}
+	}
+	// optional columns only change the track when the row has them
+	composer, milliseconds, bytes := existing.composer, existing.milliseconds, existing.bytes
+	if row.Composer != nil && (composer == nil || *composer != *row.Composer) {
+		changes["composer"] = models.ImportChange{From: composer, To: *row.Composer}
+		composer = row.Composer
+	}
+	if row.Milliseconds != 0 && row.Milliseconds != milliseconds {
+		changes["milliseconds"] = models.ImportChange{From: milliseconds, To: row.Milliseconds}
+		milliseconds = row.Milliseconds
+	}
+	if row.Bytes != nil && (bytes == nil || *bytes != *row.Bytes) {
+		changes["bytes"] = models.ImportChange{From: bytes, To: *row.Bytes}
+		bytes = row.Bytes
+	}
+	if len(changes) == 0 {
+		result.Action = "unchanged"
+		return nil, nil
+	}
+	_, err = run.tx.ExecContext(ctx, `
+		UPDATE Track SET MediaTypeId = ?, GenreId = ?, Composer = ?, Milliseconds = ?, Bytes = ?, UnitPrice = ?
+		WHERE TrackId = ?
+	`, mediaTypeID, genreID, composer, milliseconds, bytes, row.UnitPrice, existing.id)
+	if err != nil {
+		log.Error().Err(err).Int("id", existing.id).Msg("failed to update imported track")
+		return nil, fmt.Errorf("error updating track: %w", err)
+	}
+	result.Action, result.Changes = "update", changes
+	return nil, nil
+}
+
+// resolve returns the id cached under key, or looks it up, or creates it
+// and records name in created.
+func (run *importRun) resolve(cache map[string]int, key string, created *[]string, name string, lookup, insert func() (int, error)) (int, error) {
+	if id, ok := cache[key]; ok {
+		return id, nil
+	}
+	id, err := lookup()
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		if id, err = insert(); err != nil {
+			return 0, err
+		}
+		*created = append(*created, name)
+	}
+	cache[key] = id
+	return id, nil
+}
+
+// lookup returns the id the query selects, or 0 when it selects no row.
+func (run *importRun) lookup(ctx context.Context, query string, args ...any) (int, error) {
+	var id int
+	err := run.tx.QueryRowContext(ctx, query+" ORDER BY 1 LIMIT 1", args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to look up catalog entry")
+		return 0, fmt.Errorf("error looking up catalog entry: %w", err)
+	}
+	return id, nil
+}
+
+func (run *importRun) insert(ctx context.Context, idColumn, query string, args ...any) (int, error) {
+	id, err := run.tx.InsertReturningID(ctx, idColumn, query, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to insert imported row")
+		return 0, fmt.Errorf("error importing row: %w", err)
+	}
+	return int(id), nil
+}
+
+// existingTrack is a track already on an album, with the names of its
+// genre and media type for the diff.
+type existingTrack struct {
+	id           int
+	genre        string
+	mediaType    string
+	composer     *string
+	milliseconds int
+	bytes        *int
+	unitPrice    float64
+}
+
+func (run *importRun) findTrack(ctx context.Context, albumID int, name string) (*existingTrack, error) {
+	var t existingTrack
+	var genre, mediaType sql.NullString
+	err := run.tx.QueryRowContext(ctx, `
+		SELECT t.TrackId, g.Name, m.Name, t.Composer, t.Milliseconds, t.Bytes, t.UnitPrice
+		FROM Track t
+		LEFT JOIN Genre g ON g.GenreId = t.GenreId
+		LEFT JOIN MediaType m ON m.MediaTypeId = t.MediaTypeId
+		WHERE t.AlbumId = ? AND LOWER(t.Name) = LOWER(?)
+		ORDER BY t.TrackId
+		LIMIT 1
+	`, albumID, name).Scan(&t.id, &genre, &mediaType, &t.composer, &t.milliseconds, &t.bytes, &t.unitPrice)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to look up track")
+		return nil, fmt.Errorf("error looking up track: %w", err)
+	}
+	t.genre, t.mediaType = genre.String, mediaType.String
+	return &t, nil
+}
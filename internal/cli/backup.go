@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"chinook-api/internal/backup"
+	"chinook-api/internal/config"
+	"chinook-api/internal/database"
+
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Write a consistent snapshot of the database into backup.dir",
+	Long:  "Write a consistent snapshot of the SQLite database with VACUUM INTO. Safe to run while the server is serving traffic.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+
+		backups := &backup.Manager{DB: db, Dir: cfg.Backup.Dir, Retention: cfg.Backup.Retention}
+		b, err := backups.Create(context.Background())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), b.Path)
+		return nil
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <backup-file>",
+	Short: "Replace the database with a verified snapshot",
+	Long:  "Replace the SQLite database with a snapshot after it passes PRAGMA integrity_check. Stop the server first.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dialect, _ := database.ParseDialect(cfg.DB.Driver); dialect != database.SQLite {
+			return fmt.Errorf("restore is only supported on SQLite")
+		}
+		return backup.Restore(context.Background(), args[0], cfg.DB.Path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd, restoreCmd)
+}
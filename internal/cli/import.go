@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"chinook-api/internal/catalog"
+	"chinook-api/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var importFlags struct {
+	dryRun     bool
+	onConflict string
+	format     string
+	json       bool
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a label delivery of tracks from CSV or JSON",
+	Long: "Import tracks from a CSV file with a header, or a JSON array of objects, with the columns artist, album, track, genre, media_type and price, " +
+		"and optionally composer, milliseconds and bytes. Artists, albums, genres and media types are matched by name ignoring case and created when missing. " +
+		"Nothing is imported unless every row succeeds. A running server picks up the new names in fuzzy search and autocomplete when it restarts.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := catalog.Format(importFlags.format)
+		if format == "" {
+			format = catalog.Format(strings.TrimPrefix(strings.ToLower(filepath.Ext(args[0])), "."))
+		}
+		if format != catalog.CSV && format != catalog.JSON {
+			return fmt.Errorf("cannot tell the format of %s; pass --format csv or --format json", args[0])
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+
+		importer := &catalog.Importer{DB: db}
+		report, err := importer.Import(cmd.Context(), f, catalog.Options{
+			Format:     format,
+			OnConflict: importFlags.onConflict,
+			DryRun:     importFlags.dryRun,
+		})
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if importFlags.json {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintf(out, "%d rows: %d created, %d updated, %d unchanged, %d skipped, %d failed\n",
+				report.Rows, report.Created, report.Updated, report.Unchanged, report.Skipped, report.Failed)
+			for _, list := range []struct {
+				label string
+				names []string
+			}{
+				{"new artists", report.NewArtists},
+				{"new albums", report.NewAlbums},
+				{"new genres", report.NewGenres},
+				{"new media types", report.NewMediaTypes},
+			} {
+				if len(list.names) > 0 {
+					fmt.Fprintf(out, "%s: %s\n", list.label, strings.Join(list.names, "; "))
+				}
+			}
+			for _, row := range report.Results {
+				if row.Action != "error" {
+					continue
+				}
+				fields := make([]string, 0, len(row.Errors))
+				for field := range row.Errors {
+					fields = append(fields, field)
+				}
+				sort.Strings(fields)
+				for _, field := range fields {
+					fmt.Fprintf(out, "row %d: %s %s\n", row.Row, field, row.Errors[field])
+				}
+			}
+		}
+
+		switch {
+		case report.Failed > 0:
+			return fmt.Errorf("%d rows failed; nothing was imported", report.Failed)
+		case report.DryRun:
+			fmt.Fprintln(cmd.ErrOrStderr(), "dry run; nothing was imported")
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.Flags().BoolVar(&importFlags.dryRun, "dry-run", false, "report what would change without writing anything")
+	importCmd.Flags().StringVar(&importFlags.onConflict, "on-conflict", catalog.Fail, "what to do with tracks that already exist: skip, update or fail")
+	importCmd.Flags().StringVar(&importFlags.format, "format", "", "csv or json; inferred from the file extension when omitted")
+	importCmd.Flags().BoolVar(&importFlags.json, "json", false, "print the full report as JSON")
+	rootCmd.AddCommand(importCmd)
+}
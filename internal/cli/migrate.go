@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"chinook-api/internal/config"
+	"chinook-api/internal/database"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+
+		applied, err := database.Migrate(cmd.Context(), db)
+		for _, version := range applied {
+			fmt.Fprintln(cmd.OutOrStdout(), "applied", version)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "database is up to date")
+		}
+		return nil
+	},
+}
+
+var seedCmd = &cobra.Command{
+	Use:   "seed <fixture.json>...",
+	Short: "Load JSON fixtures into the database",
+	Long:  "Load JSON fixture files into the database. Each file is an array of {\"table\": ..., \"rows\": [...]} objects; rows whose key already exists are skipped.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+
+		for _, path := range args {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			n, err := database.LoadFixtures(cmd.Context(), db, f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: inserted %d rows\n", path, n)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd, seedCmd)
+}
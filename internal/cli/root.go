@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+
+	"chinook-api/internal/config"
+	"chinook-api/internal/logging"
+	"chinook-api/internal/utils"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configPath string
+	cfg        *config.AppConfig
+	logFile    *os.File
+)
+
+var rootCmd = &cobra.Command{
+	Use:           "chinook-api",
+	Short:         "RESTful API and admin tooling for the Chinook database",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	// Running the binary without a subcommand starts the server, as before.
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return serveCmd.RunE(cmd, args)
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		loaded, err := config.Load(configPath, config.FlagOverrides(cmd.Flags()))
+		if err != nil {
+			return err
+		}
+		cfg = loaded
+		utils.ConfigureJWT(cfg.Auth.JWTSecret, cfg.Auth.AccessTokenTTL)
+
+		// Only the server logs to stdout; other commands keep stdout for
+		// their own output.
+		var console io.Writer = os.Stderr
+		if cmd == serveCmd || cmd == cmd.Root() {
+			console = os.Stdout
+		}
+		logFile, err = logging.InitLogger(cfg.Log.File, cfg.Log.Level, console)
+		return err
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if logFile != nil {
+			logFile.Close()
+		}
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file (env CONFIG_FILE)")
+	config.RegisterFlags(rootCmd.PersistentFlags())
+}
+
+// Execute runs the command selected on the command line.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatal().Err(err).Msg("Command failed")
+	}
+}
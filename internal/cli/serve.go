@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"chinook-api/internal/backup"
+	"chinook-api/internal/config"
+	"chinook-api/internal/database"
+	"chinook-api/internal/handlers"
+	"chinook-api/internal/jobs"
+	"chinook-api/internal/logging"
+	"chinook-api/internal/repositories"
+	"chinook-api/internal/routes"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the HTTP server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+
+		if cfg.DB.AutoMigrate {
+			if _, err := database.Migrate(context.Background(), db); err != nil {
+				return err
+			}
+		}
+
+		backups := &backup.Manager{DB: db, Dir: cfg.Backup.Dir, Retention: cfg.Backup.Retention}
+		backupCtx, stopBackups := context.WithCancel(context.Background())
+		defer stopBackups()
+		if cfg.Backup.Interval > 0 {
+			go backups.Schedule(backupCtx, cfg.Backup.Interval)
+		}
+
+		jobQueue := &jobs.Queue{
+			Repo:        &repositories.JobRepository{DB: db},
+			Workers:     cfg.Jobs.Workers,
+			MaxAttempts: cfg.Jobs.MaxAttempts,
+			Backoff:     cfg.Jobs.RetryBackoff,
+			Retention:   cfg.Jobs.Retention,
+		}
+
+		r := gin.New()
+		r.Use(logging.RequestContextMiddleware())
+		// r.Use(cors.Default())
+
+		r.Use(cors.New(cors.Config{
+			AllowOrigins:     cfg.CORS.AllowOrigins,
+			AllowMethods:     cfg.CORS.AllowMethods,
+			AllowHeaders:     cfg.CORS.AllowHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			// clients need the ETag for conditional requests and to tell
+			// replayed responses apart
+			ExposeHeaders: []string{"ETag", "Idempotent-Replayed", "Content-Disposition"},
+		}))
+
+		r.Use(logging.ZerologMiddleware(), gin.CustomRecovery(handlers.Recover))
+		routes.SetupRoutes(r, db, cfg, backups, jobQueue)
+		// after the routes, which register the job runners
+		if err := jobQueue.Start(context.Background()); err != nil {
+			return err
+		}
+
+		srv := &http.Server{
+			Addr:    ":" + cfg.Server.Port,
+			Handler: r,
+		}
+
+		// Start server in a goroutine
+		go func() {
+			log.Info().Msgf("Server running at http://localhost:%s", cfg.Server.Port)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Msgf("listen: %s", err)
+			}
+		}()
+
+		// Wait for interrupt signal to gracefully shutdown the server
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+		log.Info().Msg("Shutting down server...")
+		stopBackups()
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error().Msgf("Server forced to shutdown: %v", err)
+			return err
+		}
+
+		// let running jobs finish; the rest resume after the next start
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Jobs.DrainTimeout)
+		defer cancelDrain()
+		if err := jobQueue.Shutdown(drainCtx); err != nil {
+			log.Warn().Err(err).Msg("Background jobs interrupted")
+		}
+
+		log.Info().Msg("Server exiting")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+}
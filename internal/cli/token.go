@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"chinook-api/internal/config"
+	"chinook-api/internal/repositories"
+	"chinook-api/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage access tokens",
+}
+
+var tokenIssueFlags struct {
+	ttl time.Duration
+}
+
+var tokenIssueCmd = &cobra.Command{
+	Use:   "issue <username>",
+	Short: "Issue a long-lived access token for a service account",
+	Long:  "Issue an access token for an existing user, typically a service account created with 'user create'. The token carries the user's identity, so its role applies.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tokenIssueFlags.ttl <= 0 {
+			return fmt.Errorf("--ttl must be positive")
+		}
+
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+		repo := &repositories.UserRepository{DB: db}
+
+		if _, err := repo.GetUserByUsername(cmd.Context(), args[0]); err != nil {
+			return fmt.Errorf("user %s not found; create it first with 'user create'", args[0])
+		}
+		token, err := utils.GenerateJWTWithTTL(args[0], tokenIssueFlags.ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), token)
+		return nil
+	},
+}
+
+func init() {
+	tokenIssueCmd.Flags().DurationVar(&tokenIssueFlags.ttl, "ttl", 30*24*time.Hour, "token lifetime")
+	tokenCmd.AddCommand(tokenIssueCmd)
+	rootCmd.AddCommand(tokenCmd)
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"text/tabwriter"
+
+	"chinook-api/internal/config"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+	"chinook-api/internal/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+)
+
+var rolePattern = regexp.MustCompile(`^[a-z][a-z_]*$`)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage API users",
+}
+
+var userCreateFlags struct {
+	email    string
+	password string
+	role     string
+}
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create <username>",
+	Short: "Create a user, generating a password when none is given",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password := userCreateFlags.password
+		generated := password == ""
+		if generated {
+			password = randomPassword()
+		}
+		req := models.SignupRequest{Username: args[0], Email: userCreateFlags.email, Password: password}
+		if err := validator.New().Struct(&req); err != nil {
+			return err
+		}
+		if err := checkRole(userCreateFlags.role); err != nil {
+			return err
+		}
+
+		hashed, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+		repo := &repositories.UserRepository{DB: db}
+
+		id, err := repo.CreateUser(cmd.Context(), models.User{Username: req.Username, Email: req.Email, Password: hashed})
+		if err != nil {
+			return err
+		}
+		if userCreateFlags.role != "" {
+			if err := repo.UpdateRole(cmd.Context(), req.Username, userCreateFlags.role); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "created user %s (id %d)\n", req.Username, id)
+		if generated {
+			fmt.Fprintf(cmd.OutOrStdout(), "password: %s\n", password)
+		}
+		return nil
+	},
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users and their roles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+		repo := &repositories.UserRepository{DB: db}
+
+		users, err := repo.ListUsers(cmd.Context())
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tROLE\tEMPLOYEE")
+		for _, u := range users {
+			employee := ""
+			if u.EmployeeID != nil {
+				employee = strconv.Itoa(*u.EmployeeID)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", u.ID, u.Username, u.Email, u.Role, employee)
+		}
+		return w.Flush()
+	},
+}
+
+var userSetRoleCmd = &cobra.Command{
+	Use:   "set-role <username> <role>",
+	Short: "Change a user's role",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkRole(args[1]); err != nil {
+			return err
+		}
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+		repo := &repositories.UserRepository{DB: db}
+
+		if err := repo.UpdateRole(cmd.Context(), args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "user %s now has role %s\n", args[0], args[1])
+		return nil
+	},
+}
+
+var userSetEmployeeCmd = &cobra.Command{
+	Use:   "set-employee <username> <employee-id|none>",
+	Short: "Link a user to the employee whose sales reports they may see",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+		users := &repositories.UserRepository{DB: db}
+
+		if args[1] == "none" {
+			if err := users.SetEmployee(cmd.Context(), args[0], nil); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "user %s is no longer linked to an employee\n", args[0])
+			return nil
+		}
+
+		id, err := strconv.Atoi(args[1])
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid employee id %q", args[1])
+		}
+		employees := &repositories.EmployeeRepository{DB: db}
+		if _, err := employees.GetEmployeeByID(cmd.Context(), id); err != nil {
+			return err
+		}
+		if err := users.SetEmployee(cmd.Context(), args[0], &id); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "user %s now acts as employee %d\n", args[0], id)
+		return nil
+	},
+}
+
+var userResetPasswordFlags struct {
+	password string
+}
+
+var userResetPasswordCmd = &cobra.Command{
+	Use:   "reset-password <username>",
+	Short: "Set a new password and revoke the user's refresh tokens",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password := userResetPasswordFlags.password
+		generated := password == ""
+		if generated {
+			password = randomPassword()
+		}
+		if err := validator.New().Var(password, "min=6"); err != nil {
+			return fmt.Errorf("password must be at least 6 characters")
+		}
+		hashed, err := utils.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		db := config.SetupDB(cfg.DB)
+		defer db.Close()
+		users := &repositories.UserRepository{DB: db}
+		tokens := &repositories.RefreshTokenRepository{DB: db}
+
+		if err := users.UpdatePassword(cmd.Context(), args[0], hashed); err != nil {
+			return err
+		}
+		if err := tokens.DeleteByUsername(cmd.Context(), args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "password reset for %s\n", args[0])
+		if generated {
+			fmt.Fprintf(cmd.OutOrStdout(), "password: %s\n", password)
+		}
+		return nil
+	},
+}
+
+func checkRole(role string) error {
+	if role != "" && !rolePattern.MatchString(role) {
+		return fmt.Errorf("invalid role %q: use lowercase letters and underscores", role)
+	}
+	return nil
+}
+
+func randomPassword() string {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func init() {
+	userCreateCmd.Flags().StringVar(&userCreateFlags.email, "email", "", "email address (required)")
+	userCreateCmd.Flags().StringVar(&userCreateFlags.password, "password", "", "password; generated and printed when omitted")
+	userCreateCmd.Flags().StringVar(&userCreateFlags.role, "role", "", "role, e.g. admin")
+	userCreateCmd.MarkFlagRequired("email")
+
+	userResetPasswordCmd.Flags().StringVar(&userResetPasswordFlags.password, "password", "", "new password; generated and printed when omitted")
+
+	userCmd.AddCommand(userCreateCmd, userListCmd, userSetRoleCmd, userSetEmployeeCmd, userResetPasswordCmd)
+	rootCmd.AddCommand(userCmd)
+}
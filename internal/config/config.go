@@ -1,31 +1,596 @@
 package config
 
 import (
+	"chinook-api/internal/database"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/rs/zerolog"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
 )
 
+// AppConfig is the typed configuration for the whole application.
 type AppConfig struct {
-	JWTSecret string
-	Port      string
-	DBPath    string
+	Server      ServerConfig
+	DB          DBConfig
+	Auth        AuthConfig
+	CORS        CORSConfig
+	Log         LogConfig
+	Limits      LimitsConfig
+	Idempotency IdempotencyConfig
+	Backup      BackupConfig
+	Jobs        JobsConfig
+	GraphQL     GraphQLConfig
+	Commission  CommissionConfig
 }
 
-func LoadConfig() *AppConfig {
-	cfg := &AppConfig{
-		JWTSecret: os.Getenv("JWT_SECRET"),
-		Port:      os.Getenv("PORT"),
-		DBPath:    os.Getenv("DB_PATH"),
+type ServerConfig struct {
+	Port            string
+	APIVersion      string
+	Env             string
+	ShutdownTimeout time.Duration
+}
+
+type DBConfig struct {
+	Driver          string
+	Path            string
+	DSN             string
+	AutoMigrate     bool
+	JournalMode     string
+	Synchronous     string
+	BusyTimeout     time.Duration
+	ForeignKeys     bool
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+type AuthConfig struct {
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	AllowCredentials bool
+}
+
+type LogConfig struct {
+	File  string
+	Level string
+}
+
+type BackupConfig struct {
+	Dir       string
+	Interval  time.Duration
+	Retention int
+}
+
+// JobsConfig sizes the background job worker pool. A failed attempt is
+// retried after RetryBackoff, doubling each time, up to MaxAttempts in all.
+// On shutdown, running jobs get DrainTimeout to finish before they are
+// interrupted and queued again.
+type JobsConfig struct {
+	Workers      int
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	Retention    time.Duration
+	DrainTimeout time.Duration
+}
+
+// GraphQLConfig bounds the queries accepted by the GraphQL endpoint; see
+// graph.Limits for how depth and complexity are counted.
+type GraphQLConfig struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// CommissionConfig drives the sales rep commission reports. Rates are
+// fractions of revenue; Threshold is the revenue a rep must exceed in a
+// period before commission is paid on the remainder.
+type CommissionConfig struct {
+	Rate         float64
+	OverrideRate float64
+	Threshold    float64
+}
+
+// IdempotencyConfig sets how long responses to POST requests with an
+// Idempotency-Key are kept for replay.
+type IdempotencyConfig struct {
+	TTL time.Duration
+}
+
+type LimitsConfig struct {
+	DefaultPageSize int
+	MaxPageSize     int
+	MaxBodyBytes    int64
+	MaxBulkOps      int
+}
+
+// IsProduction reports whether the server runs in production mode.
+func (c *AppConfig) IsProduction() bool {
+	return c.Server.Env == "production"
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() *AppConfig {
+	return &AppConfig{
+		Server: ServerConfig{
+			Port:            "8080",
+			APIVersion:      "v1",
+			Env:             "development",
+			ShutdownTimeout: 5 * time.Second,
+		},
+		DB: DBConfig{
+			Driver:          "sqlite",
+			Path:            "chinook.db",
+			AutoMigrate:     true,
+			JournalMode:     "WAL",
+			Synchronous:     "NORMAL",
+			BusyTimeout:     5 * time.Second,
+			ForeignKeys:     true,
+			MaxOpenConns:    8,
+			MaxIdleConns:    8,
+			ConnMaxLifetime: time.Hour,
+			ConnMaxIdleTime: 10 * time.Minute,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  24 * time.Hour,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowOrigins:     []string{"http://localhost:3000"},
+			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "If-Match", "If-None-Match", "Idempotency-Key"},
+			AllowCredentials: true,
+		},
+		Log: LogConfig{
+			File:  "app.log",
+			Level: "info",
+		},
+		Limits: LimitsConfig{
+			DefaultPageSize: 50,
+			MaxPageSize:     500,
+			MaxBodyBytes:    1 << 20,
+			MaxBulkOps:      1000,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: 24 * time.Hour,
+		},
+		Backup: BackupConfig{
+			Dir:       "backups",
+			Retention: 7,
+		},
+		Jobs: JobsConfig{
+			Workers:      2,
+			MaxAttempts:  3,
+			RetryBackoff: 30 * time.Second,
+			Retention:    7 * 24 * time.Hour,
+			DrainTimeout: 30 * time.Second,
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      10,
+			MaxComplexity: 20000,
+		},
+		Commission: CommissionConfig{
+			Rate:         0.05,
+			OverrideRate: 0.01,
+		},
+	}
+}
+
+// field binds a configuration key to its environment variable and destination.
+type field struct {
+	key   string
+	env   string
+	usage string
+	ptr   any
+}
+
+func (c *AppConfig) fields() []field {
+	return []field{
+		{"server.port", "PORT", "HTTP listen port", &c.Server.Port},
+		{"server.api_version", "API_VERSION", "API version used in the route prefix", &c.Server.APIVersion},
+		{"server.env", "GO_ENV", "environment: development, test or production", &c.Server.Env},
+		{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "graceful shutdown timeout", &c.Server.ShutdownTimeout},
+		{"db.driver", "DB_DRIVER", "database backend: sqlite or postgres", &c.DB.Driver},
+		{"db.path", "DB_PATH", "path to the SQLite database", &c.DB.Path},
+		{"db.dsn", "DATABASE_URL", "Postgres connection string", &c.DB.DSN},
+		{"db.auto_migrate", "DB_AUTO_MIGRATE", "apply pending migrations at startup", &c.DB.AutoMigrate},
+		{"db.journal_mode", "DB_JOURNAL_MODE", "SQLite journal mode", &c.DB.JournalMode},
+		{"db.synchronous", "DB_SYNCHRONOUS", "SQLite synchronous level", &c.DB.Synchronous},
+		{"db.busy_timeout", "DB_BUSY_TIMEOUT", "how long to wait on a locked database", &c.DB.BusyTimeout},
+		{"db.foreign_keys", "DB_FOREIGN_KEYS", "enforce foreign key constraints", &c.DB.ForeignKeys},
+		{"db.max_open_conns", "DB_MAX_OPEN_CONNS", "maximum open read connections", &c.DB.MaxOpenConns},
+		{"db.max_idle_conns", "DB_MAX_IDLE_CONNS", "maximum idle read connections", &c.DB.MaxIdleConns},
+		{"db.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", "maximum lifetime of a connection", &c.DB.ConnMaxLifetime},
+		{"db.conn_max_idle_time", "DB_CONN_MAX_IDLE_TIME", "maximum idle time of a connection", &c.DB.ConnMaxIdleTime},
+		{"auth.jwt_secret", "JWT_SECRET", "secret used to sign access tokens", &c.Auth.JWTSecret},
+		{"auth.access_token_ttl", "JWT_ACCESS_TTL", "access token lifetime", &c.Auth.AccessTokenTTL},
+		{"auth.refresh_token_ttl", "JWT_REFRESH_TTL", "refresh token lifetime", &c.Auth.RefreshTokenTTL},
+		{"cors.allow_origins", "FRONTEND_WEB_URL", "comma-separated allowed origins", &c.CORS.AllowOrigins},
+		{"cors.allow_methods", "CORS_ALLOW_METHODS", "comma-separated allowed methods", &c.CORS.AllowMethods},
+		{"cors.allow_headers", "CORS_ALLOW_HEADERS", "comma-separated allowed headers", &c.CORS.AllowHeaders},
+		{"cors.allow_credentials", "CORS_ALLOW_CREDENTIALS", "allow credentials in CORS requests", &c.CORS.AllowCredentials},
+		{"log.file", "LOG_FILE", "path to the JSON log file", &c.Log.File},
+		{"log.level", "LOG_LEVEL", "minimum log level", &c.Log.Level},
+		{"limits.default_page_size", "PAGE_SIZE_DEFAULT", "page size when no limit is given", &c.Limits.DefaultPageSize},
+		{"limits.max_page_size", "PAGE_SIZE_MAX", "largest accepted page size", &c.Limits.MaxPageSize},
+		{"limits.max_body_bytes", "MAX_BODY_BYTES", "largest accepted request body in bytes", &c.Limits.MaxBodyBytes},
+		{"limits.max_bulk_operations", "MAX_BULK_OPERATIONS", "most operations accepted in one bulk request", &c.Limits.MaxBulkOps},
+		{"idempotency.ttl", "IDEMPOTENCY_TTL", "how long POST responses are kept for Idempotency-Key replays", &c.Idempotency.TTL},
+		{"backup.dir", "BACKUP_DIR", "directory for database backups", &c.Backup.Dir},
+		{"backup.interval", "BACKUP_INTERVAL", "time between scheduled backups, 0 to disable", &c.Backup.Interval},
+		{"backup.retention", "BACKUP_RETENTION", "number of backups to keep, 0 to keep all", &c.Backup.Retention},
+		{"jobs.workers", "JOB_WORKERS", "number of background jobs run at once", &c.Jobs.Workers},
+		{"jobs.max_attempts", "JOB_MAX_ATTEMPTS", "attempts at a background job before it fails", &c.Jobs.MaxAttempts},
+		{"jobs.retry_backoff", "JOB_RETRY_BACKOFF", "delay before retrying a failed job, doubled for each further attempt", &c.Jobs.RetryBackoff},
+		{"jobs.retention", "JOB_RETENTION", "how long finished jobs and their results are kept", &c.Jobs.Retention},
+		{"jobs.drain_timeout", "JOB_DRAIN_TIMEOUT", "how long shutdown waits for running jobs before interrupting them", &c.Jobs.DrainTimeout},
+		{"graphql.max_depth", "GRAPHQL_MAX_DEPTH", "deepest field nesting accepted in a GraphQL query", &c.GraphQL.MaxDepth},
+		{"graphql.max_complexity", "GRAPHQL_MAX_COMPLEXITY", "highest complexity accepted in a GraphQL query", &c.GraphQL.MaxComplexity},
+		{"commission.rate", "COMMISSION_RATE", "share of a rep's customer revenue paid as commission", &c.Commission.Rate},
+		{"commission.override_rate", "COMMISSION_OVERRIDE_RATE", "share of their team's revenue paid to managers", &c.Commission.OverrideRate},
+		{"commission.threshold", "COMMISSION_THRESHOLD", "revenue per period a rep must exceed before commission is paid", &c.Commission.Threshold},
+	}
+}
+
+// Load builds the configuration from defaults, then the optional file at path
+// (YAML or TOML), then environment variables, then overrides keyed like
+// "server.port", and validates the result.
+func Load(path string, overrides map[string]string) (*AppConfig, error) {
+	cfg := Default()
+	fields := cfg.fields()
+
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			f, ok := lookup(fields, key)
+			if !ok {
+				return nil, fmt.Errorf("config file %s: unknown key %q", path, key)
+			}
+			if err := setValue(f.ptr, value); err != nil {
+				return nil, fmt.Errorf("config file %s: %s: %w", path, key, err)
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if value, ok := os.LookupEnv(f.env); ok && value != "" {
+			if err := setValue(f.ptr, value); err != nil {
+				return nil, fmt.Errorf("env %s: %w", f.env, err)
+			}
+		}
+	}
+
+	for key, value := range overrides {
+		f, ok := lookup(fields, key)
+		if !ok {
+			return nil, fmt.Errorf("flag --%s: unknown key", key)
+		}
+		if err := setValue(f.ptr, value); err != nil {
+			return nil, fmt.Errorf("flag --%s: %w", key, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// RegisterFlags declares one flag per configuration key on fs.
+func RegisterFlags(fs *pflag.FlagSet) {
+	for _, f := range Default().fields() {
+		fs.String(f.key, "", f.usage+" (env "+f.env+")")
+	}
+}
+
+// FlagOverrides collects the configuration flags explicitly set on fs.
+func FlagOverrides(fs *pflag.FlagSet) map[string]string {
+	keys := map[string]bool{}
+	for _, f := range Default().fields() {
+		keys[f.key] = true
+	}
+	overrides := map[string]string{}
+	fs.Visit(func(fl *pflag.Flag) {
+		if keys[fl.Name] {
+			overrides[fl.Name] = fl.Value.String()
+		}
+	})
+	return overrides
+}
+
+// Validate checks every section and reports all problems at once.
+func (c *AppConfig) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		fail("server.port", "must be a number between 1 and 65535, got %q", c.Server.Port)
+	}
+	if c.Server.APIVersion == "" || strings.Contains(c.Server.APIVersion, "/") {
+		fail("server.api_version", "must be a single path segment, got %q", c.Server.APIVersion)
+	}
+	switch c.Server.Env {
+	case "development", "test", "production":
+	default:
+		fail("server.env", "must be development, test or production, got %q", c.Server.Env)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		fail("server.shutdown_timeout", "must be positive")
+	}
+
+	switch dialect, err := database.ParseDialect(c.DB.Driver); {
+	case err != nil:
+		fail("db.driver", "must be sqlite or postgres, got %q", c.DB.Driver)
+	case dialect == database.Postgres && c.DB.DSN == "":
+		fail("db.dsn", "is required when db.driver is postgres")
+	case dialect == database.SQLite && c.DB.Path == "":
+		fail("db.path", "is required")
+	}
+	switch strings.ToUpper(c.DB.JournalMode) {
+	case "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF":
+	default:
+		fail("db.journal_mode", "must be DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF, got %q", c.DB.JournalMode)
+	}
+	switch strings.ToUpper(c.DB.Synchronous) {
+	case "OFF", "NORMAL", "FULL", "EXTRA":
+	default:
+		fail("db.synchronous", "must be OFF, NORMAL, FULL or EXTRA, got %q", c.DB.Synchronous)
+	}
+	if c.DB.BusyTimeout < 0 {
+		fail("db.busy_timeout", "must not be negative")
 	}
-	if cfg.JWTSecret == "" {
-		log.Fatal().Msg("JWT_SECRET is required")
+	if c.DB.MaxOpenConns <= 0 {
+		fail("db.max_open_conns", "must be positive")
 	}
-	if cfg.Port == "" {
-		cfg.Port = "8080"
+	if c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		fail("db.max_idle_conns", "must be between 0 and db.max_open_conns")
 	}
-	if cfg.DBPath == "" {
-		cfg.DBPath = "chinook.db"
+	if c.DB.ConnMaxLifetime < 0 {
+		fail("db.conn_max_lifetime", "must not be negative")
+	}
+	if c.DB.ConnMaxIdleTime < 0 {
+		fail("db.conn_max_idle_time", "must not be negative")
+	}
+
+	if c.Auth.JWTSecret == "" {
+		fail("auth.jwt_secret", "is required")
+	} else if c.IsProduction() && len(c.Auth.JWTSecret) < 32 {
+		fail("auth.jwt_secret", "must be at least 32 characters in production")
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		fail("auth.access_token_ttl", "must be positive")
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.AccessTokenTTL {
+		fail("auth.refresh_token_ttl", "must not be shorter than auth.access_token_ttl")
+	}
+
+	if len(c.CORS.AllowOrigins) == 0 {
+		fail("cors.allow_origins", "needs at least one origin")
+	}
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				fail("cors.allow_origins", "wildcard origin cannot be combined with cors.allow_credentials")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			fail("cors.allow_origins", "invalid origin %q", origin)
+		}
+	}
+	if len(c.CORS.AllowMethods) == 0 {
+		fail("cors.allow_methods", "needs at least one method")
+	}
+
+	if c.Log.File == "" {
+		fail("log.file", "is required")
+	}
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+		fail("log.level", "unknown level %q", c.Log.Level)
+	}
+
+	if c.Limits.DefaultPageSize <= 0 {
+		fail("limits.default_page_size", "must be positive")
+	}
+	if c.Limits.MaxPageSize < c.Limits.DefaultPageSize {
+		fail("limits.max_page_size", "must not be smaller than limits.default_page_size")
+	}
+	if c.Limits.MaxBodyBytes <= 0 {
+		fail("limits.max_body_bytes", "must be positive")
+	}
+	if c.Limits.MaxBulkOps <= 0 {
+		fail("limits.max_bulk_operations", "must be positive")
+	}
+
+	if c.Idempotency.TTL <= 0 {
+		fail("idempotency.ttl", "must be positive")
+	}
+
+	if c.Backup.Dir == "" {
+		fail("backup.dir", "is required")
+	}
+	if c.Backup.Interval < 0 {
+		fail("backup.interval", "must not be negative")
+	} else if c.Backup.Interval > 0 && c.Backup.Interval < time.Minute {
+		fail("backup.interval", "must be at least 1m")
+	}
+	if c.Backup.Retention < 0 {
+		fail("backup.retention", "must not be negative")
+	}
+
+	if c.Jobs.Workers <= 0 {
+		fail("jobs.workers", "must be positive")
+	}
+	if c.Jobs.MaxAttempts <= 0 {
+		fail("jobs.max_attempts", "must be positive")
+	}
+	if c.Jobs.RetryBackoff <= 0 {
+		fail("jobs.retry_backoff", "must be positive")
+	}
+	if c.Jobs.Retention <= 0 {
+		fail("jobs.retention", "must be positive")
+	}
+	if c.Jobs.DrainTimeout < 0 {
+		fail("jobs.drain_timeout", "must not be negative")
+	}
+
+	if c.GraphQL.MaxDepth <= 0 {
+		fail("graphql.max_depth", "must be positive")
+	}
+	if c.GraphQL.MaxComplexity <= 0 {
+		fail("graphql.max_complexity", "must be positive")
+	}
+
+	if c.Commission.Rate < 0 || c.Commission.Rate > 1 {
+		fail("commission.rate", "must be between 0 and 1")
+	}
+	if c.Commission.OverrideRate < 0 || c.Commission.OverrideRate > 1 {
+		fail("commission.override_rate", "must be between 0 and 1")
+	}
+	if c.Commission.Threshold < 0 {
+		fail("commission.threshold", "must not be negative")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func lookup(fields []field, key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// readFile decodes a YAML or TOML file into flattened "section.key" values.
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	values := map[string]any{}
+	flatten("", raw, values)
+	return values, nil
+}
+
+func flatten(prefix string, in map[string]any, out map[string]any) {
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := in[k].(map[string]any); ok {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = in[k]
+	}
+}
+
+// setValue assigns a raw value (string from env/flags, or a decoded file value) to ptr.
+func setValue(ptr any, value any) error {
+	if list, ok := value.([]any); ok {
+		p, ok := ptr.(*[]string)
+		if !ok {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		*p = items
+		return nil
+	}
+
+	s := strings.TrimSpace(fmt.Sprint(value))
+	switch p := ptr.(type) {
+	case *string:
+		*p = s
+	case *[]string:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*p = items
+	case *bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		*p = b
+	case *int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		*p = n
+	case *float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		*p = f
+	case *int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		*p = n
+	case *time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		*p = d
+	default:
+		return fmt.Errorf("unsupported config type %T", ptr)
 	}
-	return cfg
+	return nil
 }
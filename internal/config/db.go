@@ -1,26 +1,46 @@
 package config
 
 import (
-	"database/sql"
-	"fmt"
+	"chinook-api/internal/database"
 
 	"github.com/rs/zerolog/log"
-
-	_ "modernc.org/sqlite"
 )
 
-func SetupDB(dbPath string) *sql.DB {
-	db, err := sql.Open("sqlite", dbPath)
+func SetupDB(cfg DBConfig) *database.DB {
+	opts := database.Options{
+		JournalMode:     cfg.JournalMode,
+		Synchronous:     cfg.Synchronous,
+		BusyTimeout:     cfg.BusyTimeout,
+		ForeignKeys:     cfg.ForeignKeys,
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+	}
 
+	dialect, err := database.ParseDialect(cfg.Driver)
 	if err != nil {
-		log.Fatal().Msgf("failed to connect database: %v", err)
+		log.Fatal().Err(err).Msg("failed to connect database")
 	}
 
-	err = db.Ping()
+	var db *database.DB
+	if dialect == database.Postgres {
+		db, err = database.OpenPostgres(cfg.DSN, opts)
+	} else {
+		db, err = database.Open(cfg.Path, opts)
+	}
 	if err != nil {
-		log.Fatal().Msgf("unable to reach database: %v", err)
+		log.Fatal().Msgf("failed to connect database: %v", err)
 	}
 
-	fmt.Println("Connected to SQLite database!")
+	if dialect == database.Postgres {
+		log.Info().Int("max_open_conns", cfg.MaxOpenConns).Msg("Connected to Postgres database")
+	} else {
+		log.Info().
+			Str("path", cfg.Path).
+			Str("journal_mode", cfg.JournalMode).
+			Int("max_open_conns", cfg.MaxOpenConns).
+			Msg("Connected to SQLite database")
+	}
 	return db
 }
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"chinook-api/internal/apperr"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Options tunes the SQLite pragmas and connection pools.
+type Options struct {
+	JournalMode     string
+	Synchronous     string
+	BusyTimeout     time.Duration
+	ForeignKeys     bool
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DB pairs a read pool, which serves queries concurrently, with a write
+// pool. On SQLite the write pool holds a single connection so that writers
+// never contend for the lock; on Postgres both share one pool.
+// Repositories call it like a *sql.DB: queries go to Read, Exec and
+// transactions go to Write, and placeholders are rebound for the dialect.
+type DB struct {
+	Read    *sql.DB
+	Write   *sql.DB
+	Dialect Dialect
+}
+
+// Open opens both pools on the SQLite file at path and verifies they are reachable.
+func Open(path string, opts Options) (*DB, error) {
+	// The writer is opened first so that persistent pragmas such as
+	// journal_mode=WAL are in place before any reader connects.
+	write, err := sql.Open(SQLite.driverName(), dsn(path, opts, false))
+	if err != nil {
+		return nil, fmt.Errorf("opening write pool: %w", err)
+	}
+	write.SetMaxOpenConns(1)
+	write.SetMaxIdleConns(1)
+	write.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	write.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	if err := write.Ping(); err != nil {
+		write.Close()
+		return nil, fmt.Errorf("reaching database: %w", err)
+	}
+
+	read, err := sql.Open(SQLite.driverName(), dsn(path, opts, true))
+	if err != nil {
+		write.Close()
+		return nil, fmt.Errorf("opening read pool: %w", err)
+	}
+	read.SetMaxOpenConns(opts.MaxOpenConns)
+	read.SetMaxIdleConns(opts.MaxIdleConns)
+	read.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	read.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	if err := read.Ping(); err != nil {
+		read.Close()
+		write.Close()
+		return nil, fmt.Errorf("reaching database: %w", err)
+	}
+
+	return &DB{Read: read, Write: write, Dialect: SQLite}, nil
+}
+
+// OpenPostgres opens a single pool on the Postgres server at dsn. The
+// SQLite-specific pragmas in opts are ignored.
+func OpenPostgres(dsn string, opts Options) (*DB, error) {
+	pool, err := sql.Open(Postgres.driverName(), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening pool: %w", err)
+	}
+	pool.SetMaxOpenConns(opts.MaxOpenConns)
+	pool.SetMaxIdleConns(opts.MaxIdleConns)
+	pool.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	pool.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+	if err := pool.Ping(); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("reaching database: %w", err)
+	}
+	return &DB{Read: pool, Write: pool, Dialect: Postgres}, nil
+}
+
+// dsn builds a modernc.org/sqlite connection string that applies the
+// pragmas on every new connection.
+func dsn(path string, opts Options, readOnly bool) string {
+	params := url.Values{}
+	foreignKeys := 0
+	if opts.ForeignKeys {
+		foreignKeys = 1
+	}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()))
+	params.Add("_pragma", fmt.Sprintf("foreign_keys(%d)", foreignKeys))
+	params.Add("_pragma", fmt.Sprintf("journal_mode(%s)", strings.ToUpper(opts.JournalMode)))
+	params.Add("_pragma", fmt.Sprintf("synchronous(%s)", strings.ToUpper(opts.Synchronous)))
+	if readOnly {
+		params.Add("_pragma", "query_only(1)")
+	} else {
+		// Take the write lock at BEGIN so a transaction never fails
+		// halfway through when upgrading from a read lock.
+		params.Set("_txlock", "immediate")
+	}
+	return "file:" + path + "?" + params.Encode()
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return db.Read.QueryContext(ctx, db.Dialect.Rebind(query), args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.Read.QueryRowContext(ctx, db.Dialect.Rebind(query), args...)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.Write.ExecContext(ctx, db.Dialect.Rebind(query), args...)
+}
+
+// InsertReturningID runs an INSERT on the write pool and returns the value
+// generated for idColumn. Postgres drivers do not implement LastInsertId, so
+// the statement is extended with a RETURNING clause there.
+func (db *DB) InsertReturningID(ctx context.Context, idColumn, query string, args ...any) (int64, error) {
+	return insertReturningID(ctx, db.Write, db.Dialect, idColumn, query, args...)
+}
+
+// BeginTx starts a transaction on the write pool.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.Write.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, Dialect: db.Dialect}, nil
+}
+
+func (db *DB) PingContext(ctx context.Context) error {
+	return db.Write.PingContext(ctx)
+}
+
+func (db *DB) Close() error {
+	if db.Read == db.Write {
+		return db.Write.Close()
+	}
+	readErr := db.Read.Close()
+	if err := db.Write.Close(); err != nil {
+		return err
+	}
+	return readErr
+}
+
+// Tx is a transaction that rebinds placeholders like DB does.
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.Dialect.Rebind(query), args...)
+}
+
+// InsertReturningID is the transactional form of DB.InsertReturningID.
+func (tx *Tx) InsertReturningID(ctx context.Context, idColumn, query string, args ...any) (int64, error) {
+	return insertReturningID(ctx, tx.Tx, tx.Dialect, idColumn, query, args...)
+}
+
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertReturningID(ctx context.Context, q execQueryer, dialect Dialect, idColumn, query string, args ...any) (int64, error) {
+	if dialect == Postgres {
+		var id int64
+		err := q.QueryRowContext(ctx, dialect.Rebind(query)+" RETURNING "+idColumn, args...).Scan(&id)
+		return id, err
+	}
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// ErrUnsupported is returned for operations the connected dialect cannot perform.
+var ErrUnsupported = apperr.New(apperr.KindNotImplemented, "unsupported_backend", "operation not supported by this database backend")
+
+// Snapshot writes a consistent copy of a SQLite database to dest with
+// VACUUM INTO. It runs on a read connection, so writers keep going while the
+// copy is taken; query_only is lifted on that connection only for the
+// duration of the statement.
+func (db *DB) Snapshot(ctx context.Context, dest string) error {
+	if db.Dialect != SQLite {
+		return ErrUnsupported
+	}
+	conn, err := db.Read.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = 0"); err != nil {
+		return err
+	}
+	defer func() {
+		// Never hand a writable connection back to the read pool.
+		if _, err := conn.ExecContext(context.Background(), "PRAGMA query_only = 1"); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, "VACUUM INTO ?", dest)
+	return err
+}
+
+// IntegrityCheck opens the SQLite file at path read-only and runs
+// PRAGMA integrity_check, returning an error describing any problem found.
+func IntegrityCheck(ctx context.Context, path string) error {
+	conn, err := sql.Open(SQLite.driverName(), "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("running integrity_check on %s: %w", path, err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return fmt.Errorf("reading integrity_check result: %w", err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading integrity_check result: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity_check failed on %s: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
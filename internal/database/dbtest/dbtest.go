@@ -0,0 +1,109 @@
+// Package dbtest opens throwaway, migrated databases for tests: a SQLite
+// file always, and a Postgres schema when PostgresEnv names a server.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chinook-api/internal/database"
+)
+
+// PostgresEnv holds the connection string of the Postgres server that
+// Postgres tests run against. They are skipped when it is unset.
+const PostgresEnv = "TEST_DATABASE_URL"
+
+var options = database.Options{
+	JournalMode:  "wal",
+	Synchronous:  "normal",
+	BusyTimeout:  5 * time.Second,
+	ForeignKeys:  true,
+	MaxOpenConns: 4,
+	MaxIdleConns: 4,
+}
+
+// Each runs fn as a subtest against a fresh database of every backend:
+// SQLite, and Postgres when PostgresEnv is set.
+func Each(t *testing.T, fn func(t *testing.T, db *database.DB)) {
+	t.Run("sqlite", func(t *testing.T) { fn(t, SQLite(t)) })
+	t.Run("postgres", func(t *testing.T) { fn(t, Postgres(t)) })
+}
+
+// SQLite returns a migrated database in a file under the test's temporary
+// directory.
+func SQLite(t testing.TB) *database.DB {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "chinook.db"), options)
+	if err != nil {
+		t.Fatalf("opening SQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrate(t, db)
+	return db
+}
+
+var schemas atomic.Int64
+
+// Postgres returns a migrated database in a new schema on the server named
+// by PostgresEnv, dropped when the test ends, or skips the test when
+// PostgresEnv is unset.
+func Postgres(t testing.TB) *database.DB {
+	t.Helper()
+	dsn := os.Getenv(PostgresEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", PostgresEnv)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("opening Postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("chinook_test_%d_%d", os.Getpid(), schemas.Add(1))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("dropping schema: %v", err)
+		}
+	})
+
+	db, err := database.OpenPostgres(withSearchPath(dsn, schema), options)
+	if err != nil {
+		t.Fatalf("opening Postgres: %v", err)
+	}
+	// registered after the drop, so it runs first
+	t.Cleanup(func() { db.Close() })
+	migrate(t, db)
+	return db
+}
+
+func migrate(t testing.TB, db *database.DB) {
+	t.Helper()
+	if _, err := database.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+}
+
+// withSearchPath sets the search_path of every connection made with dsn, a
+// URL or a key=value connection string.
+func withSearchPath(dsn, schema string) string {
+	if strings.Contains(dsn, "://") {
+		if u, err := url.Parse(dsn); err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
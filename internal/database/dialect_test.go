@@ -0,0 +1,73 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/database/dbtest"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := map[string]database.Dialect{
+		"sqlite":     database.SQLite,
+		"SQLite3":    database.SQLite,
+		"postgres":   database.Postgres,
+		"postgresql": database.Postgres,
+		"pgx":        database.Postgres,
+	}
+	for name, want := range tests {
+		if got, err := database.ParseDialect(name); err != nil || got != want {
+			t.Errorf("ParseDialect(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := database.ParseDialect("mysql"); err == nil {
+		t.Error("ParseDialect(mysql) succeeded")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect     database.Dialect
+		query, want string
+	}{
+		{database.SQLite, "SELECT * FROM Artist WHERE ArtistId = ? AND Name = ?", "SELECT * FROM Artist WHERE ArtistId = ? AND Name = ?"},
+		{database.Postgres, "SELECT * FROM Artist WHERE ArtistId = ? AND Name = ?", "SELECT * FROM Artist WHERE ArtistId = $1 AND Name = $2"},
+		{database.Postgres, "SELECT 1", "SELECT 1"},
+		{database.Postgres, "SELECT '?', ? FROM t", "SELECT '?', $1 FROM t"},
+		{database.Postgres, `SELECT "a?b" FROM t WHERE x = ?`, `SELECT "a?b" FROM t WHERE x = $1`},
+		{database.Postgres, "SELECT 'it''s?' WHERE x IN (?, ?, ?)", "SELECT 'it''s?' WHERE x IN ($1, $2, $3)"},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.Rebind(tt.query); got != tt.want {
+			t.Errorf("%s Rebind(%q) = %q, want %q", tt.dialect, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestConstraintViolations(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		if _, err := db.ExecContext(ctx, "INSERT INTO Artist (ArtistId, Name) VALUES (?, ?)", 1, "AC/DC"); err != nil {
+			t.Fatalf("inserting artist: %v", err)
+		}
+
+		_, err := db.ExecContext(ctx, "INSERT INTO Artist (ArtistId, Name) VALUES (?, ?)", 1, "Accept")
+		if !database.IsUniqueViolation(err) || database.IsForeignKeyViolation(err) {
+			t.Errorf("duplicate key: err = %v, want a unique violation only", err)
+		}
+
+		_, err = db.ExecContext(ctx, "INSERT INTO Album (AlbumId, Title, ArtistId) VALUES (?, ?, ?)", 1, "Orphan", 99)
+		if !database.IsForeignKeyViolation(err) || database.IsUniqueViolation(err) {
+			t.Errorf("missing parent: err = %v, want a foreign key violation only", err)
+		}
+
+		if other := errors.New("boom"); database.IsUniqueViolation(other) || database.IsForeignKeyViolation(other) {
+			t.Error("plain error reported as a constraint violation")
+		}
+		if database.IsUniqueViolation(nil) || database.IsForeignKeyViolation(nil) {
+			t.Error("nil reported as a constraint violation")
+		}
+	})
+}
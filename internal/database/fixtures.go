@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Fixture is a batch of rows for one table. Fixture files are JSON arrays of
+// fixtures, applied in order so that parents can precede children:
+//
+//	[{"table": "Artist", "rows": [{"ArtistId": 1001, "Name": "Example"}]}]
+type Fixture struct {
+	Table string           `json:"table"`
+	Rows  []map[string]any `json:"rows"`
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// LoadFixtures inserts every row from the JSON fixture file in r inside one
+// transaction. Rows whose key already exists are skipped, so seeding twice
+// is harmless. It returns the number of rows inserted.
+func LoadFixtures(ctx context.Context, db *DB, r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var fixtures []Fixture
+	if err := dec.Decode(&fixtures); err != nil {
+		return 0, fmt.Errorf("parsing fixtures: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	inserted := 0
+	for _, f := range fixtures {
+		if !identifier.MatchString(f.Table) {
+			return 0, fmt.Errorf("invalid table name %q", f.Table)
+		}
+		table := quoteTable(f.Table)
+		for i, row := range f.Rows {
+			columns := make([]string, 0, len(row))
+			for column := range row {
+				if !identifier.MatchString(column) {
+					return 0, fmt.Errorf("%s row %d: invalid column name %q", f.Table, i, column)
+				}
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+
+			args := make([]any, len(columns))
+			for j, column := range columns {
+				args[j] = fixtureValue(row[column])
+			}
+			query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+				table, strings.Join(columns, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+			result, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return 0, fmt.Errorf("%s row %d: %w", f.Table, i, err)
+			}
+			if n, err := result.RowsAffected(); err == nil {
+				inserted += int(n)
+			}
+		}
+		if db.Dialect == Postgres {
+			if err := syncIdentity(ctx, tx, f); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return inserted, nil
+}
+
+// quoteTable quotes the one table name that is reserved on Postgres.
+func quoteTable(table string) string {
+	if strings.EqualFold(table, "User") {
+		return `"User"`
+	}
+	return table
+}
+
+// syncIdentity moves a Postgres identity sequence past explicitly inserted
+// keys, which follow the <Table>Id naming used by the Chinook schema.
+func syncIdentity(ctx context.Context, tx *Tx, f Fixture) error {
+	if len(f.Rows) == 0 {
+		return nil
+	}
+	idColumn := f.Table + "Id"
+	if _, ok := f.Rows[0][idColumn]; !ok {
+		return nil
+	}
+	table := quoteTable(f.Table)
+	regclass := strings.ToLower(f.Table)
+	if table != f.Table {
+		regclass = table
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"SELECT setval(pg_get_serial_sequence(?, ?), (SELECT MAX(%s) FROM %s))", idColumn, table),
+		regclass, strings.ToLower(idColumn))
+	if err != nil {
+		return fmt.Errorf("syncing %s identity: %w", f.Table, err)
+	}
+	return nil
+}
+
+// fixtureValue turns JSON numbers into int64 where possible so integer
+// columns receive integers on every driver.
+func fixtureValue(v any) any {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
@@ -0,0 +1,98 @@
+package database_test
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/database/dbtest"
+)
+
+func TestMigrateFresh(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "fresh.db"), database.Options{JournalMode: "wal", Synchronous: "normal", ForeignKeys: true, MaxOpenConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations, err := database.Migrations(database.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, m := range migrations {
+		want = append(want, m.Version)
+	}
+	applied, err := database.Migrate(context.Background(), db)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if !slices.Equal(applied, want) {
+		t.Errorf("applied %v, want %v", applied, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		migrations, err := database.Migrations(db.Dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(migrations) == 0 || !strings.HasPrefix(migrations[0].Version, "0001") {
+			t.Fatalf("migrations = %v, want 0001 first", migrations)
+		}
+
+		// dbtest has migrated already, so a second run is a no-op
+		applied, err := database.Migrate(ctx, db)
+		if err != nil {
+			t.Fatalf("Migrate again: %v", err)
+		}
+		if len(applied) != 0 {
+			t.Errorf("second Migrate applied %v", applied)
+		}
+
+		var recorded int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&recorded); err != nil {
+			t.Fatal(err)
+		}
+		if recorded != len(migrations) {
+			t.Errorf("schema_migrations has %d rows, want %d", recorded, len(migrations))
+		}
+
+		for _, table := range []string{"Artist", "Album", "Track", "Customer", "Invoice", "InvoiceLine", "Employee", `"User"`} {
+			if _, err := db.ExecContext(ctx, "SELECT COUNT(*) FROM "+table); err != nil {
+				t.Errorf("table %s: %v", table, err)
+			}
+		}
+	})
+}
+
+func TestLoadFixtures(t *testing.T) {
+	const fixtures = `[
+		{"table": "Artist", "rows": [{"ArtistId": 1001, "Name": "Example Artist"}]},
+		{"table": "Album", "rows": [{"AlbumId": 1001, "Title": "Example Album", "ArtistId": 1001}]}
+	]`
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		n, err := database.LoadFixtures(ctx, db, strings.NewReader(fixtures))
+		if err != nil || n != 2 {
+			t.Fatalf("LoadFixtures = %d, %v; want 2 rows", n, err)
+		}
+		if n, err := database.LoadFixtures(ctx, db, strings.NewReader(fixtures)); err != nil || n != 0 {
+			t.Fatalf("LoadFixtures again = %d, %v; want 0 rows", n, err)
+		}
+
+		// identities continue after the explicit keys
+		id, err := db.InsertReturningID(ctx, "ArtistId", "INSERT INTO Artist (Name) VALUES (?)", "Next")
+		if err != nil {
+			t.Fatalf("inserting artist: %v", err)
+		}
+		if id <= 1001 {
+			t.Errorf("new artist id = %d, want more than 1001", id)
+		}
+	})
+}
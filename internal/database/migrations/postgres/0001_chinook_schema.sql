@@ -0,0 +1,128 @@
+-- Chinook catalog plus the API's User and Refresh_Tokens tables.
+-- Identifiers are left unquoted so that the repositories' mixed-case names
+-- (AlbumId, Track, ...) fold to the same lowercase names. "User" is a
+-- reserved word and is the only quoted table.
+
+CREATE TABLE IF NOT EXISTS Artist (
+    ArtistId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    Name VARCHAR(120)
+);
+
+CREATE TABLE IF NOT EXISTS Album (
+    AlbumId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    Title VARCHAR(160) NOT NULL,
+    ArtistId INTEGER NOT NULL REFERENCES Artist (ArtistId)
+);
+
+CREATE TABLE IF NOT EXISTS Employee (
+    EmployeeId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    LastName VARCHAR(20) NOT NULL,
+    FirstName VARCHAR(20) NOT NULL,
+    Title VARCHAR(30),
+    ReportsTo INTEGER REFERENCES Employee (EmployeeId),
+    BirthDate TIMESTAMP,
+    HireDate TIMESTAMP,
+    Address VARCHAR(70),
+    City VARCHAR(40),
+    State VARCHAR(40),
+    Country VARCHAR(40),
+    PostalCode VARCHAR(10),
+    Phone VARCHAR(24),
+    Fax VARCHAR(24),
+    Email VARCHAR(60)
+);
+
+CREATE TABLE IF NOT EXISTS Customer (
+    CustomerId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    FirstName VARCHAR(40) NOT NULL,
+    LastName VARCHAR(20) NOT NULL,
+    Company VARCHAR(80),
+    Address VARCHAR(70),
+    City VARCHAR(40),
+    State VARCHAR(40),
+    Country VARCHAR(40),
+    PostalCode VARCHAR(10),
+    Phone VARCHAR(24),
+    Fax VARCHAR(24),
+    Email VARCHAR(60) NOT NULL,
+    SupportRepId INTEGER REFERENCES Employee (EmployeeId)
+);
+
+CREATE TABLE IF NOT EXISTS Genre (
+    GenreId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    Name VARCHAR(120)
+);
+
+CREATE TABLE IF NOT EXISTS MediaType (
+    MediaTypeId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    Name VARCHAR(120)
+);
+
+CREATE TABLE IF NOT EXISTS Track (
+    TrackId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    Name VARCHAR(200) NOT NULL,
+    AlbumId INTEGER REFERENCES Album (AlbumId),
+    MediaTypeId INTEGER NOT NULL REFERENCES MediaType (MediaTypeId),
+    GenreId INTEGER REFERENCES Genre (GenreId),
+    Composer VARCHAR(220),
+    Milliseconds INTEGER NOT NULL,
+    Bytes INTEGER,
+    UnitPrice NUMERIC(10,2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Invoice (
+    InvoiceId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    CustomerId INTEGER NOT NULL REFERENCES Customer (CustomerId),
+    InvoiceDate TIMESTAMP NOT NULL,
+    BillingAddress VARCHAR(70),
+    BillingCity VARCHAR(40),
+    BillingState VARCHAR(40),
+    BillingCountry VARCHAR(40),
+    BillingPostalCode VARCHAR(10),
+    Total NUMERIC(10,2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS InvoiceLine (
+    InvoiceLineId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    InvoiceId INTEGER NOT NULL REFERENCES Invoice (InvoiceId),
+    TrackId INTEGER NOT NULL REFERENCES Track (TrackId),
+    UnitPrice NUMERIC(10,2) NOT NULL,
+    Quantity INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Playlist (
+    PlaylistId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    Name VARCHAR(120)
+);
+
+CREATE TABLE IF NOT EXISTS PlaylistTrack (
+    PlaylistId INTEGER NOT NULL REFERENCES Playlist (PlaylistId),
+    TrackId INTEGER NOT NULL REFERENCES Track (TrackId),
+    PRIMARY KEY (PlaylistId, TrackId)
+);
+
+CREATE TABLE IF NOT EXISTS "User" (
+    UserId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    Username TEXT NOT NULL UNIQUE,
+    Email TEXT NOT NULL UNIQUE,
+    Password TEXT NOT NULL,
+    Role TEXT,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS Refresh_Tokens (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    username TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS IFK_AlbumArtistId ON Album (ArtistId);
+CREATE INDEX IF NOT EXISTS IFK_CustomerSupportRepId ON Customer (SupportRepId);
+CREATE INDEX IF NOT EXISTS IFK_InvoiceCustomerId ON Invoice (CustomerId);
+CREATE INDEX IF NOT EXISTS IFK_InvoiceLineInvoiceId ON InvoiceLine (InvoiceId);
+CREATE INDEX IF NOT EXISTS IFK_InvoiceLineTrackId ON InvoiceLine (TrackId);
+CREATE INDEX IF NOT EXISTS IFK_PlaylistTrackTrackId ON PlaylistTrack (TrackId);
+CREATE INDEX IF NOT EXISTS IFK_TrackAlbumId ON Track (AlbumId);
+CREATE INDEX IF NOT EXISTS IFK_TrackGenreId ON Track (GenreId);
+CREATE INDEX IF NOT EXISTS IFK_TrackMediaTypeId ON Track (MediaTypeId);
@@ -0,0 +1,25 @@
+-- Per-area change counters. Triggers bump a counter on every write to the
+-- tables behind it, so caches can tell whether they are stale with a single
+-- primary-key lookup, whichever process made the change.
+
+CREATE TABLE IF NOT EXISTS DataVersion (
+    Name TEXT PRIMARY KEY,
+    Version BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO DataVersion (Name, Version) VALUES ('invoice', 0) ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION bump_invoice_version() RETURNS trigger AS $$
+BEGIN
+    UPDATE DataVersion SET Version = Version + 1 WHERE Name = 'invoice';
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS DataVersion_Invoice ON Invoice;
+CREATE TRIGGER DataVersion_Invoice AFTER INSERT OR UPDATE OR DELETE ON Invoice
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_invoice_version();
+
+DROP TRIGGER IF EXISTS DataVersion_InvoiceLine ON InvoiceLine;
+CREATE TRIGGER DataVersion_InvoiceLine AFTER INSERT OR UPDATE OR DELETE ON InvoiceLine
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_invoice_version();
@@ -0,0 +1,4 @@
+-- Links API users to the employee they act as, so reports can be limited to
+-- that employee's part of the organization.
+
+ALTER TABLE "User" ADD COLUMN IF NOT EXISTS EmployeeId INTEGER REFERENCES Employee (EmployeeId);
@@ -0,0 +1,17 @@
+-- Responses to POST requests sent with an Idempotency-Key header, replayed
+-- when the client retries with the same key. Status is NULL while the first
+-- request is still being handled. Times are Unix seconds.
+
+CREATE TABLE IF NOT EXISTS IdempotencyKey (
+    Scope TEXT NOT NULL,
+    RequestKey TEXT NOT NULL,
+    Fingerprint TEXT NOT NULL,
+    Status INTEGER,
+    ContentType TEXT,
+    Body BYTEA,
+    CreatedAt BIGINT NOT NULL,
+    ExpiresAt BIGINT NOT NULL,
+    PRIMARY KEY (Scope, RequestKey)
+);
+
+CREATE INDEX IF NOT EXISTS IdempotencyKey_ExpiresAt ON IdempotencyKey (ExpiresAt);
@@ -0,0 +1,28 @@
+-- Background jobs run by the in-process worker pool. Status moves from
+-- queued to running and ends as succeeded, failed or canceled; a failed
+-- attempt that will be retried goes back to queued with RunAt pushed out.
+-- Owner is the username that submitted the job, empty without auth. Times
+-- are Unix seconds.
+
+CREATE TABLE IF NOT EXISTS Job (
+    JobId INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    Type TEXT NOT NULL,
+    Owner TEXT NOT NULL,
+    Params TEXT NOT NULL,
+    Status TEXT NOT NULL,
+    Done INTEGER NOT NULL DEFAULT 0,
+    Total INTEGER,
+    Attempts INTEGER NOT NULL DEFAULT 0,
+    MaxAttempts INTEGER NOT NULL,
+    ErrorCode TEXT,
+    ErrorDetail TEXT,
+    ResultType TEXT,
+    ResultName TEXT,
+    Result BYTEA,
+    CreatedAt BIGINT NOT NULL,
+    RunAt BIGINT NOT NULL,
+    StartedAt BIGINT,
+    FinishedAt BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS Job_Status_RunAt ON Job (Status, RunAt);
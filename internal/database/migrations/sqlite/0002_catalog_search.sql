@@ -0,0 +1,69 @@
+-- Full-text index over artist names, album titles, track names and composers.
+-- Each catalog row maps to one index row whose rowid is derived from its key
+-- (Id * 3 + 0 for artists, + 1 for albums, + 2 for tracks) so the triggers
+-- below can update and delete entries without scanning the index.
+
+CREATE VIRTUAL TABLE IF NOT EXISTS CatalogSearch USING fts5(
+    kind UNINDEXED,
+    ref_id UNINDEXED,
+    name,
+    composer,
+    tokenize = 'unicode61 remove_diacritics 2',
+    prefix = '2 3'
+);
+
+DELETE FROM CatalogSearch;
+
+INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+SELECT ArtistId * 3, 'artist', ArtistId, Name, NULL FROM Artist;
+
+INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+SELECT AlbumId * 3 + 1, 'album', AlbumId, Title, NULL FROM Album;
+
+INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+SELECT TrackId * 3 + 2, 'track', TrackId, Name, Composer FROM Track;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Artist_ai AFTER INSERT ON Artist BEGIN
+    INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+    VALUES (new.ArtistId * 3, 'artist', new.ArtistId, new.Name, NULL);
+END;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Artist_au AFTER UPDATE OF ArtistId, Name ON Artist BEGIN
+    DELETE FROM CatalogSearch WHERE rowid = old.ArtistId * 3;
+    INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+    VALUES (new.ArtistId * 3, 'artist', new.ArtistId, new.Name, NULL);
+END;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Artist_ad AFTER DELETE ON Artist BEGIN
+    DELETE FROM CatalogSearch WHERE rowid = old.ArtistId * 3;
+END;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Album_ai AFTER INSERT ON Album BEGIN
+    INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+    VALUES (new.AlbumId * 3 + 1, 'album', new.AlbumId, new.Title, NULL);
+END;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Album_au AFTER UPDATE OF AlbumId, Title ON Album BEGIN
+    DELETE FROM CatalogSearch WHERE rowid = old.AlbumId * 3 + 1;
+    INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+    VALUES (new.AlbumId * 3 + 1, 'album', new.AlbumId, new.Title, NULL);
+END;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Album_ad AFTER DELETE ON Album BEGIN
+    DELETE FROM CatalogSearch WHERE rowid = old.AlbumId * 3 + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Track_ai AFTER INSERT ON Track BEGIN
+    INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+    VALUES (new.TrackId * 3 + 2, 'track', new.TrackId, new.Name, new.Composer);
+END;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Track_au AFTER UPDATE OF TrackId, Name, Composer ON Track BEGIN
+    DELETE FROM CatalogSearch WHERE rowid = old.TrackId * 3 + 2;
+    INSERT INTO CatalogSearch (rowid, kind, ref_id, name, composer)
+    VALUES (new.TrackId * 3 + 2, 'track', new.TrackId, new.Name, new.Composer);
+END;
+
+CREATE TRIGGER IF NOT EXISTS CatalogSearch_Track_ad AFTER DELETE ON Track BEGIN
+    DELETE FROM CatalogSearch WHERE rowid = old.TrackId * 3 + 2;
+END;
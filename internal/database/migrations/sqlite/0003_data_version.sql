@@ -0,0 +1,34 @@
+-- Per-area change counters. Triggers bump a counter on every write to the
+-- tables behind it, so caches can tell whether they are stale with a single
+-- primary-key lookup, whichever process made the change.
+
+CREATE TABLE IF NOT EXISTS DataVersion (
+    Name TEXT PRIMARY KEY,
+    Version INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT OR IGNORE INTO DataVersion (Name, Version) VALUES ('invoice', 0);
+
+CREATE TRIGGER IF NOT EXISTS DataVersion_Invoice_ai AFTER INSERT ON Invoice BEGIN
+    UPDATE DataVersion SET Version = Version + 1 WHERE Name = 'invoice';
+END;
+
+CREATE TRIGGER IF NOT EXISTS DataVersion_Invoice_au AFTER UPDATE ON Invoice BEGIN
+    UPDATE DataVersion SET Version = Version + 1 WHERE Name = 'invoice';
+END;
+
+CREATE TRIGGER IF NOT EXISTS DataVersion_Invoice_ad AFTER DELETE ON Invoice BEGIN
+    UPDATE DataVersion SET Version = Version + 1 WHERE Name = 'invoice';
+END;
+
+CREATE TRIGGER IF NOT EXISTS DataVersion_InvoiceLine_ai AFTER INSERT ON InvoiceLine BEGIN
+    UPDATE DataVersion SET Version = Version + 1 WHERE Name = 'invoice';
+END;
+
+CREATE TRIGGER IF NOT EXISTS DataVersion_InvoiceLine_au AFTER UPDATE ON InvoiceLine BEGIN
+    UPDATE DataVersion SET Version = Version + 1 WHERE Name = 'invoice';
+END;
+
+CREATE TRIGGER IF NOT EXISTS DataVersion_InvoiceLine_ad AFTER DELETE ON InvoiceLine BEGIN
+    UPDATE DataVersion SET Version = Version + 1 WHERE Name = 'invoice';
+END;
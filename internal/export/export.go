@@ -0,0 +1,314 @@
+// Package export writes collections as CSV, NDJSON or XLSX one row at a
+// time, so that a table can be sent without holding it in memory. Columns
+// are the JSON fields of the row type, in declaration order, under their
+// JSON names; fields holding related resources are left out.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Format is an export format, named as in the ?format= parameter.
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+	XLSX   Format = "xlsx"
+)
+
+// Formats lists the export formats in the order they are preferred when an
+// Accept header ranks them equally.
+var Formats = []Format{CSV, NDJSON, XLSX}
+
+// ContentType is the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case NDJSON:
+		return "application/x-ndjson"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return ""
+}
+
+// Streams reports whether rows reach the output as they are written. XLSX
+// files are zip archives that excelize assembles on Close, so nothing is
+// written before then.
+func (f Format) Streams() bool {
+	return f != XLSX
+}
+
+// ParseFormat returns the format named name.
+func ParseFormat(name string) (Format, bool) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// ForMediaType returns the format whose media type is mediaType, ignoring
+// parameters such as charset.
+func ForMediaType(mediaType string) (Format, bool) {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, f := range Formats {
+		ct, _, _ := strings.Cut(f.ContentType(), ";")
+		if ct == mediaType {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Writer writes values of type T as rows of one export.
+type Writer[T any] struct {
+	columns []column
+	rows    rowWriter
+}
+
+type rowWriter interface {
+	header(names []string) error
+	row(v any, cells []any) error
+	flush() error
+	close() error
+}
+
+// column is an exported field of the row type.
+type column struct {
+	name  string
+	index []int
+}
+
+// New starts an export of T values to w. sheet names the worksheet of an
+// XLSX file.
+func New[T any](format Format, w io.Writer, sheet string) (*Writer[T], error) {
+	var rows rowWriter
+	switch format {
+	case CSV:
+		rows = &csvWriter{w: csv.NewWriter(w)}
+	case NDJSON:
+		rows = &ndjsonWriter{enc: json.NewEncoder(w)}
+	case XLSX:
+		x, err := newXLSXWriter(w, sheet)
+		if err != nil {
+			return nil, err
+		}
+		rows = x
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+	columns := columnsOf(reflect.TypeFor[T]())
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	if err := rows.header(names); err != nil {
+		return nil, err
+	}
+	return &Writer[T]{columns: columns, rows: rows}, nil
+}
+
+// Write adds v as the next row.
+func (w *Writer[T]) Write(v T) error {
+	value := reflect.ValueOf(v)
+	cells := make([]any, len(w.columns))
+	for i, c := range w.columns {
+		field := value.FieldByIndex(c.index)
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+		cells[i] = field.Interface()
+	}
+	return w.rows.row(v, cells)
+}
+
+// Flush sends the rows written so far on to the underlying writer.
+func (w *Writer[T]) Flush() error {
+	return w.rows.flush()
+}
+
+// Close finishes the export. Rows of an XLSX file are only written to the
+// underlying writer here.
+func (w *Writer[T]) Close() error {
+	return w.rows.close()
+}
+
+// Discard abandons an export that failed, releasing what Close would have
+// without writing anything more.
+func (w *Writer[T]) Discard() {
+	if d, ok := w.rows.(interface{ discard() }); ok {
+		d.discard()
+	}
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// columnsOf lists the JSON fields of t that hold a single value. Structs
+// other than times, slices and maps are related resources and are skipped.
+func columnsOf(t reflect.Type) []column {
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch ft.Kind() {
+		case reflect.Struct:
+			if ft != timeType {
+				continue
+			}
+		case reflect.Slice, reflect.Array, reflect.Map, reflect.Interface:
+			continue
+		}
+		columns = append(columns, column{name: name, index: field.Index})
+	}
+	return columns
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) header(names []string) error {
+	return c.w.Write(names)
+}
+
+func (c *csvWriter) row(_ any, cells []any) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = formatCell(cell)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) close() error {
+	return c.flush()
+}
+
+// formatCell writes a cell value as text: numbers in full precision and
+// times in RFC 3339, which spreadsheets recognize. Text that a spreadsheet
+// would run as a formula is prefixed with a quote.
+func formatCell(cell any) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(cell)
+}
+
+// ndjsonWriter writes each row as its JSON document on one line, exactly as
+// the JSON list shows it.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) header([]string) error { return nil }
+
+func (n *ndjsonWriter) row(v any, _ []any) error {
+	return n.enc.Encode(v)
+}
+
+func (n *ndjsonWriter) flush() error { return nil }
+
+func (n *ndjsonWriter) close() error { return nil }
+
+// xlsxWriter streams rows into a worksheet with excelize, which keeps large
+// sheets in a temporary file rather than in memory.
+type xlsxWriter struct {
+	w     io.Writer
+	file  *excelize.File
+	sheet *excelize.StreamWriter
+	next  int
+}
+
+func newXLSXWriter(w io.Writer, sheet string) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", sheet); err != nil {
+		file.Close()
+		return nil, err
+	}
+	sw, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// keep the header row in view while scrolling
+	if err := sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxWriter{w: w, file: file, sheet: sw, next: 1}, nil
+}
+
+func (x *xlsxWriter) header(names []string) error {
+	cells := make([]any, len(names))
+	for i, name := range names {
+		cells[i] = name
+	}
+	return x.row(nil, cells)
+}
+
+func (x *xlsxWriter) row(_ any, cells []any) error {
+	cell, err := excelize.CoordinatesToCellName(1, x.next)
+	if err != nil {
+		return err
+	}
+	x.next++
+	return x.sheet.SetRow(cell, cells)
+}
+
+func (x *xlsxWriter) flush() error { return nil }
+
+func (x *xlsxWriter) discard() {
+	x.file.Close()
+}
+
+func (x *xlsxWriter) close() error {
+	defer x.file.Close()
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.w)
+}
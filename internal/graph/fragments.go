@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"strings"
+
+	"chinook-api/internal/apperr"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// maxFragmentDepth caps how deeply fragment spreads may nest: a fragment
+// spreading one that spreads another is two levels deep.
+const maxFragmentDepth = 20
+
+var errInvalidQuery = apperr.BadRequest("invalid_query", "query is invalid")
+
+// checkFragments rejects fragments that spread themselves, directly or
+// through others, and fragment spreads nested deeper than maxFragmentDepth.
+// graphql-go's validation follows spreads without a guard, so it must not
+// see such a document: a cycle recurses until the stack overflows, which
+// kills the process.
+func checkFragments(doc *ast.Document) error {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[def.Name.Value] = def
+		}
+	}
+
+	// depths holds the spread depth of each fragment checked so far
+	depths := map[string]int{}
+	var path []string
+	var visit func(name string) (int, error)
+	visit = func(name string) (int, error) {
+		if depth, done := depths[name]; done {
+			return depth, nil
+		}
+		for i, on := range path {
+			if on == name {
+				return 0, errInvalidQuery.Withf("fragment %q spreads itself via %s", name, strings.Join(append(path[i:], name), " -> "))
+			}
+		}
+		if len(path) >= maxFragmentDepth {
+			return 0, errInvalidQuery.Withf("fragment spreads are nested more than %d deep", maxFragmentDepth)
+		}
+
+		path = append(path, name)
+		depth := 0
+		for _, spread := range spreads(fragments[name].SelectionSet, nil) {
+			if _, ok := fragments[spread]; !ok {
+				// validation reports unknown fragments
+				continue
+			}
+			d, err := visit(spread)
+			if err != nil {
+				return 0, err
+			}
+			depth = max(depth, d)
+		}
+		path = path[:len(path)-1]
+
+		depths[name] = depth + 1
+		return depth + 1, nil
+	}
+
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			if _, err := visit(def.Name.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// spreads appends the names of the fragments spread anywhere in set, without
+// following them, to names.
+func spreads(set *ast.SelectionSet, names []string) []string {
+	if set == nil {
+		return names
+	}
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			names = spreads(sel.SelectionSet, names)
+		case *ast.InlineFragment:
+			names = spreads(sel.SelectionSet, names)
+		case *ast.FragmentSpread:
+			names = append(names, sel.Name.Value)
+		}
+	}
+	return names
+}
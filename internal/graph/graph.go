@@ -0,0 +1,62 @@
+// Package graph runs GraphQL requests with graphql-go: it checks queries
+// against Limits before they run and provides the Loader that resolvers use
+// to batch their lookups. The schema itself, and the resolvers over the
+// repositories, are built by the HTTP handler.
+package graph
+
+import (
+	"context"
+
+	"chinook-api/internal/models"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Execute runs req against schema. A query that does not parse, has cyclic
+// fragments, is invalid against the schema or exceeds limits is not run; Execute then returns its
+// errors and false.
+func Execute(ctx context.Context, schema graphql.Schema, req models.GraphQLRequest, limits Limits) (*graphql.Result, bool) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, false
+	}
+	if err := checkFragments(doc); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, false
+	}
+	if result := graphql.ValidateDocument(&schema, doc, nil); !result.IsValid {
+		return &graphql.Result{Errors: result.Errors}, false
+	}
+	if err := limits.check(&schema, doc, req.OperationName, req.Variables); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, false
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	}), true
+}
+
+// Cause returns the error a resolver returned, or that Execute rejected the
+// query with, from the error graphql-go reports for it.
+func Cause(err error) error {
+	for {
+		var next error
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			next = e.OriginalError()
+		case *gqlerrors.Error:
+			next = e.OriginalError
+		}
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
@@ -0,0 +1,210 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"chinook-api/internal/apperr"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// assumedListSize is how many items a list without a limit argument, such
+// as an album's tracks, is counted as holding.
+const assumedListSize = 10
+
+// maxCounted caps every count, so huge limits cannot overflow the
+// complexity into a small number.
+const maxCounted = 1 << 31
+
+// Limits bounds the queries a client may run. Both are checked before a
+// query runs, on the operation that would run.
+//
+// Depth is how deeply fields are nested, fragments included. Complexity
+// counts one for every field, with the fields below a list counted once per
+// item: the value of the limit argument of paginated fields, or
+// assumedListSize for other lists. Introspection fields count towards
+// neither, so tools like GraphiQL can always load the schema.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+var (
+	errTooDeep    = apperr.BadRequest("query_too_deep", "query is too deep")
+	errTooComplex = apperr.BadRequest("query_too_complex", "query is too complex")
+)
+
+// check measures the operation of doc named operationName, or its only
+// operation, and reports whether it is within the limits.
+func (l Limits) check(schema *graphql.Schema, doc *ast.Document, operationName string, variables map[string]any) error {
+	m := measure{
+		schema:    schema,
+		variables: variables,
+		fragments: map[string]*ast.FragmentDefinition{},
+		spreading: map[string]bool{},
+	}
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			m.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				op = def
+			}
+		}
+	}
+	if op == nil {
+		// execution reports the missing operation
+		return nil
+	}
+
+	var root *graphql.Object
+	switch op.Operation {
+	case ast.OperationTypeQuery:
+		root = schema.QueryType()
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	}
+	complexity := m.selections(op.SelectionSet, root, 0, 0)
+	if l.MaxDepth > 0 && m.depth > l.MaxDepth {
+		return errTooDeep.Withf("query is %d levels deep; at most %d are allowed", m.depth, l.MaxDepth)
+	}
+	if l.MaxComplexity > 0 && complexity > l.MaxComplexity {
+		return errTooComplex.Withf("query has a complexity of %d; at most %d is allowed", complexity, l.MaxComplexity)
+	}
+	return nil
+}
+
+// measure walks the selections of an operation, tracking the deepest field
+// and adding up the complexity.
+type measure struct {
+	schema    *graphql.Schema
+	variables map[string]any
+	fragments map[string]*ast.FragmentDefinition
+	// spreading holds the fragments being measured, so that a fragment
+	// spreading itself is not followed again
+	spreading map[string]bool
+	depth     int
+}
+
+// selections returns the complexity of set, whose fields are on parent and
+// at depth. page is the limit of the paginated field set belongs to, if any;
+// the first list below it holds that many items.
+func (m *measure) selections(set *ast.SelectionSet, parent *graphql.Object, depth, page int) int {
+	if set == nil {
+		return 0
+	}
+	complexity := 0
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			complexity += m.field(sel, parent, depth+1, page)
+		case *ast.InlineFragment:
+			complexity += m.selections(sel.SelectionSet, m.condition(sel.TypeCondition, parent), depth, page)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			if frag, ok := m.fragments[name]; ok && !m.spreading[name] {
+				m.spreading[name] = true
+				complexity += m.selections(frag.SelectionSet, m.condition(frag.TypeCondition, parent), depth, page)
+				delete(m.spreading, name)
+			}
+		}
+		complexity = min(complexity, maxCounted)
+	}
+	return complexity
+}
+
+func (m *measure) field(f *ast.Field, parent *graphql.Object, depth, page int) int {
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return 0
+	}
+	m.depth = max(m.depth, depth)
+
+	var def *graphql.FieldDefinition
+	if parent != nil {
+		def = parent.Fields()[f.Name.Value]
+	}
+	if def == nil {
+		return 1 + m.selections(f.SelectionSet, nil, depth, 0)
+	}
+
+	items, childPage := 1, 0
+	limit, paginated := m.limit(f, def)
+	switch {
+	case paginated:
+		childPage = limit
+	case isList(def.Type) && page > 0:
+		items = page
+	case isList(def.Type):
+		items = assumedListSize
+	}
+	child, _ := graphql.GetNamed(def.Type).(*graphql.Object)
+	return min(1+items*m.selections(f.SelectionSet, child, depth, childPage), maxCounted)
+}
+
+// limit returns the value of the limit argument of f, or its default, and
+// whether the field has one.
+func (m *measure) limit(f *ast.Field, def *graphql.FieldDefinition) (int, bool) {
+	var arg *graphql.Argument
+	for _, a := range def.Args {
+		if a.Name() == "limit" {
+			arg = a
+		}
+	}
+	if arg == nil {
+		return 0, false
+	}
+	for _, a := range f.Arguments {
+		if a.Name.Value != "limit" {
+			continue
+		}
+		switch v := a.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				return min(max(n, 1), maxCounted), true
+			}
+		case *ast.Variable:
+			if n, ok := intValue(m.variables[v.Name.Value]); ok {
+				return min(max(n, 1), maxCounted), true
+			}
+		}
+	}
+	if n, ok := intValue(arg.DefaultValue); ok {
+		return min(max(n, 1), maxCounted), true
+	}
+	return assumedListSize, true
+}
+
+// condition returns the type a fragment applies to.
+func (m *measure) condition(named *ast.Named, parent *graphql.Object) *graphql.Object {
+	if named == nil {
+		return parent
+	}
+	t, _ := m.schema.Type(named.Name.Value).(*graphql.Object)
+	return t
+}
+
+func isList(t graphql.Type) bool {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		t = nonNull.OfType
+	}
+	_, ok := t.(*graphql.List)
+	return ok
+}
+
+func intValue(v any) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(min(v, maxCounted)), true
+	case fmt.Stringer:
+		n, err := strconv.Atoi(v.String())
+		return n, err == nil
+	}
+	return 0, false
+}
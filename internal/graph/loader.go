@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+	"sync"
+)
+
+// Loader batches the lookups of one request by key. Every key loaded before
+// the first value is needed is fetched with a single call, and values are
+// kept for the life of the loader, so a loader must not outlive its request.
+type Loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	values  map[K]V
+	errs    map[K]error
+}
+
+// NewLoader returns a loader that fetches batches of keys with fetch. Keys
+// missing from the map fetch returns load as the zero value.
+func NewLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, values: map[K]V{}, errs: map[K]error{}}
+}
+
+// Load queues key and returns a function that yields its value. Resolvers
+// return it wrapped as a thunk: graphql-go calls every resolver of one level
+// of the query before it calls their thunks, so the first thunk fetches the
+// keys of the whole level at once.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	if _, done := l.values[key]; !done && l.errs[key] == nil {
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if v, done := l.values[key]; done {
+			return v, nil
+		}
+		if err := l.errs[key]; err != nil {
+			var zero V
+			return zero, err
+		}
+		l.flush(ctx)
+		if err := l.errs[key]; err != nil {
+			var zero V
+			return zero, err
+		}
+		return l.values[key], nil
+	}
+}
+
+// flush fetches every pending key. The caller holds l.mu.
+func (l *Loader[K, V]) flush(ctx context.Context) {
+	keys := l.pending
+	l.pending = nil
+	found, err := l.fetch(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+			continue
+		}
+		l.values[key] = found[key]
+	}
+}
@@ -1,17 +1,21 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 
+	"chinook-api/internal/apperr"
 	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AlbumHandler struct {
-	Repo *repositories.AlbumRepository
+	Repo     *repositories.AlbumRepository
+	Artists  *repositories.ArtistRepository
+	Tracks   *repositories.TrackRepository
+	Includes *repositories.Includer
 }
 
 // @Summary Get all albums
@@ -19,12 +23,23 @@ type AlbumHandler struct {
 // @Tags albums
 // @Produce json
 // @Security BearerAuth
+// @Param include query string false "Comma-separated relations to embed: artist, tracks, tracks.genre, tracks.media_type"
 // @Success 200 {array} models.Album
+// @Failure 400 {object} models.Problem
 // @Router /api/v1/albums [get]
 func (h *AlbumHandler) GetAll(c *gin.Context) {
+	include, ok := parseInclude(c, "album")
+	if !ok {
+		return
+	}
 	albums, err := h.Repo.GetAllAlbums(c.Request.Context())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
+	}
+	if err := h.Includes.Albums(c.Request.Context(), albums, include); err != nil {
+		includeFailed(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, albums)
@@ -36,29 +51,59 @@ func (h *AlbumHandler) GetAll(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Album ID"
+// @Param include query string false "Comma-separated relations to embed: artist, tracks, tracks.genre, tracks.media_type"
+// @Param If-None-Match header string false "ETag of a cached copy; 304 when it is still current"
 // @Success 200 {object} models.Album
-// @Failure 404 {object} map[string]string
+// @Success 304 "Not modified"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/albums/{id} [get]
 func (h *AlbumHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	album, err := h.Repo.GetAlbumByID(c.Request.Context(), utils.ParseInt(id))
+	include, ok := parseInclude(c, "album")
+	if !ok {
+		return
+	}
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	album, err := h.Repo.GetAlbumByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
+	}
+	albums := []models.Album{album}
+	if err := h.Includes.Albums(c.Request.Context(), albums, include); err != nil {
+		includeFailed(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, album)
+	c.JSON(http.StatusOK, albums[0])
 }
 
+// @Summary Create an album
+// @Description Creates an album by an existing artist
+// @Tags albums
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param album body models.AlbumInput true "Album to create"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Success 201 {object} models.Album
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.Problem "The Idempotency-Key was used for a different request"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/albums [post]
 func (h *AlbumHandler) Create(c *gin.Context) {
-	var album models.Album
-	if err := c.ShouldBindJSON(&album); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var in models.AlbumInput
+	if !bindValid(c, &in) || !h.checkArtist(c, in.ArtistID) {
 		return
 	}
 
+	album := in.Album(0)
 	id, err := h.Repo.CreateAlbum(c.Request.Context(), album)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
@@ -67,28 +112,189 @@ func (h *AlbumHandler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, album)
 }
 
+// @Summary Create, update and delete albums in bulk
+// @Description Applies up to limits.max_bulk_operations operations. In atomic mode (the default) all are applied or none are; in best_effort mode each succeeds or fails on its own. Each operation's data is validated like the single create and update body.
+// @Tags albums
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param operations body models.BulkRequest[models.AlbumInput] true "Operations to apply"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Success 200 {object} models.BulkResponse "Every operation succeeded"
+// @Success 207 {object} models.BulkResponse "Some operations failed in best_effort mode"
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.BulkFailure "An operation of an atomic batch failed and nothing was applied"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/albums/bulk [post]
+func (h *AlbumHandler) Bulk(c *gin.Context) {
+	bulkWrite(c, models.AlbumInput.Album, h.Repo.BulkAlbums)
+}
+
+// @Summary Update an album
+// @Description Replaces an album's title and artist
+// @Tags albums
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Album ID"
+// @Param album body models.AlbumInput true "Album data to update"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Album
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/albums/{id} [put]
 func (h *AlbumHandler) Update(c *gin.Context) {
-	id := utils.ParseInt(c.Param("id"))
-	var album models.Album
-	if err := c.ShouldBindJSON(&album); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("album", id)()
+	current, err := h.Repo.GetAlbumByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+	var in models.AlbumInput
+	if !bindValid(c, &in) {
 		return
 	}
-	album.ID = id
+	h.save(c, id, in)
+}
+
+// @Summary Patch an album
+// @Description Partially updates an album with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), chosen by Content-Type. The patch is applied to the album in the form of the update body, and the result is validated like a full update before it is saved. The artist_id must name an existing artist.
+// @Tags albums
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Album ID"
+// @Param patch body models.AlbumInput true "Merge patch with the fields to change, or an array of JSON Patch operations"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Album
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "A test operation did not match"
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 415 {object} models.Problem
+// @Failure 422 {object} models.Problem "An operation's path does not exist"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/albums/{id} [patch]
+func (h *AlbumHandler) Patch(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("album", id)()
+	album, err := h.Repo.GetAlbumByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, album) {
+		return
+	}
+	in, ok := patchBody(c, album.Input())
+	if !ok {
+		return
+	}
+	h.save(c, id, in)
+}
+
+// save checks the artist of the validated update body, writes album id and
+// responds with the album.
+func (h *AlbumHandler) save(c *gin.Context, id int, in models.AlbumInput) {
+	if !h.checkArtist(c, in.ArtistID) {
+		return
+	}
+	album := in.Album(id)
 	if err := h.Repo.UpdateAlbum(c.Request.Context(), album); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, album)
+	respondResource(c, http.StatusOK, album)
+}
+
+// checkArtist reports whether the artist_id of a request body names an
+// existing artist, writing a 400 itself when it does not.
+func (h *AlbumHandler) checkArtist(c *gin.Context, id int) bool {
+	if err := artistExists(c.Request.Context(), h.Artists, id); err != nil {
+		abortWithError(c, err)
+		return false
+	}
+	return true
+}
+
+// artistExists returns an error on artist_id unless artist id exists.
+func artistExists(ctx context.Context, artists *repositories.ArtistRepository, id int) error {
+	_, err := artists.GetArtistByID(ctx, id)
+	if apperr.IsNotFound(err) {
+		return apperr.Field("artist_id", "artist not found")
+	}
+	return err
 }
 
 func (h *AlbumHandler) Delete(c *gin.Context) {
-	id := c.Param("id")
-	err := h.Repo.DeleteAlbum(c.Request.Context(), utils.ParseInt(id))
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("album", id)()
+	current, err := h.Repo.GetAlbumByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+	if err := h.Repo.DeleteAlbum(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
 		return
 	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Album deleted successfully"})
 }
+
+// @Summary Get an album's tracks
+// @Description Returns a paginated list of the album's tracks
+// @Tags albums
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Album ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Param include query string false "Comma-separated relations to embed: album, album.artist, album.tracks, genre, media_type"
+// @Success 200 {object} models.Page[models.Track]
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/albums/{id}/tracks [get]
+func (h *AlbumHandler) GetTracks(c *gin.Context) {
+	include, ok := parseInclude(c, "track")
+	if !ok {
+		return
+	}
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetAlbumByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	limit, offset := parsePagination(c)
+	tracks, total, err := h.Tracks.GetTracksByAlbumID(c.Request.Context(), id, limit, offset)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if err := h.Includes.Tracks(c.Request.Context(), tracks, include); err != nil {
+		includeFailed(c, err)
+		return
+	}
+	respondPage(c, tracks, total, limit, offset)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/repositories"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSeriesTop    = 5
+	defaultBreakdownTop = 10
+	maxAnalyticsTop     = 50
+)
+
+type AnalyticsHandler struct {
+	Repo *repositories.AnalyticsRepository
+}
+
+// @Summary Sales over time
+// @Description Revenue, units sold and invoice counts per day, week (starting Monday), month, quarter or year, as chart-ready series aligned with labels. Periods without sales are zero. With dimension set, returns one series for each of the top values by revenue. Results are cached until the next invoice write; the X-Cache header reports HIT or MISS.
+// @Tags analytics
+// @Produce json
+// @Security BearerAuth
+// @Param period query string false "day, week, month, quarter or year (default month)"
+// @Param dimension query string false "artist, album, track, genre, media_type or country (default a single total series)"
+// @Param from query string false "First invoice date included (YYYY-MM-DD)"
+// @Param to query string false "Last invoice date included (YYYY-MM-DD)"
+// @Param country query string false "Comma-separated billing countries"
+// @Param top query int false "Number of series when dimension is set (default 5, max 50)"
+// @Success 200 {object} models.SalesTimeSeries
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/analytics/sales [get]
+func (h *AnalyticsHandler) Sales(c *gin.Context) {
+	filter, err := parseSalesFilter(c, defaultSeriesTop)
+	if err == nil {
+		filter.Period, err = parseSalesOption("period", c.DefaultQuery("period", "month"), repositories.SalesPeriods)
+	}
+	if err == nil && c.Query("dimension") != "" {
+		filter.Dimension, err = parseSalesOption("dimension", c.Query("dimension"), repositories.SalesDimensions)
+	}
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	series, hit, err := h.Repo.SalesTimeSeries(c.Request.Context(), filter)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	setCacheHeader(c, hit)
+	c.JSON(http.StatusOK, series)
+}
+
+// @Summary Sales by dimension
+// @Description Ranks artists, albums, tracks, genres, media types or billing countries by revenue, with units sold, invoice counts and each value's share of the total revenue in the filtered range. Results are cached until the next invoice write; the X-Cache header reports HIT or MISS.
+// @Tags analytics
+// @Produce json
+// @Security BearerAuth
+// @Param dimension query string true "artist, album, track, genre, media_type or country"
+// @Param from query string false "First invoice date included (YYYY-MM-DD)"
+// @Param to query string false "Last invoice date included (YYYY-MM-DD)"
+// @Param country query string false "Comma-separated billing countries"
+// @Param top query int false "Number of rows (default 10, max 50)"
+// @Success 200 {object} models.SalesBreakdown
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/analytics/breakdown [get]
+func (h *AnalyticsHandler) Breakdown(c *gin.Context) {
+	filter, err := parseSalesFilter(c, defaultBreakdownTop)
+	if err == nil {
+		filter.Dimension, err = parseSalesOption("dimension", c.Query("dimension"), repositories.SalesDimensions)
+	}
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	breakdown, hit, err := h.Repo.SalesBreakdown(c.Request.Context(), filter)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	setCacheHeader(c, hit)
+	c.JSON(http.StatusOK, breakdown)
+}
+
+// parseSalesFilter reads the date range, country and top parameters shared
+// by the analytics endpoints.
+func parseSalesFilter(c *gin.Context, defaultTop int) (repositories.SalesFilter, error) {
+	f, err := parseSalesRange(c.Request.URL.Query())
+	if err != nil {
+		return f, err
+	}
+	for _, country := range strings.Split(c.Query("country"), ",") {
+		if country = strings.TrimSpace(country); country != "" && !slices.Contains(f.Countries, country) {
+			f.Countries = append(f.Countries, country)
+		}
+	}
+
+	f.Top = defaultTop
+	if raw := c.Query("top"); raw != "" {
+		top, err := strconv.Atoi(raw)
+		if err != nil || top <= 0 {
+			return f, apperr.Field("top", "must be a positive integer")
+		}
+		f.Top = min(top, maxAnalyticsTop)
+	}
+	return f, nil
+}
+
+// parseSalesRange reads the inclusive from and to dates.
+func parseSalesRange(q url.Values) (repositories.SalesFilter, error) {
+	var f repositories.SalesFilter
+	var err error
+	if f.From, err = parseSalesDate("from", q.Get("from")); err != nil {
+		return f, err
+	}
+	if f.To, err = parseSalesDate("to", q.Get("to")); err != nil {
+		return f, err
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From) {
+		return f, apperr.Field("to", "must not be before from")
+	}
+	return f, nil
+}
+
+func parseSalesDate(name, raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, apperr.Field(name, "must be a date formatted as YYYY-MM-DD")
+	}
+	return t, nil
+}
+
+func parseSalesOption(name, raw string, allowed []string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	if !slices.Contains(allowed, value) {
+		return "", apperr.Field(name, "must be one of "+strings.Join(allowed, ", "))
+	}
+	return value, nil
+}
+
+func setCacheHeader(c *gin.Context, hit bool) {
+	if hit {
+		c.Header("X-Cache", "HIT")
+	} else {
+		c.Header("X-Cache", "MISS")
+	}
+}
@@ -3,17 +3,19 @@ package handlers
 import (
 	"net/http"
 
+	"chinook-api/internal/apperr"
 	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
-
-	"strconv"
+	"chinook-api/internal/search"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ArtistHandler struct {
-	Repo *repositories.ArtistRepository
+	Repo   *repositories.ArtistRepository
+	Fuzzy  *search.FuzzyIndex
+	Albums   *repositories.AlbumRepository
+	Includes *repositories.Includer
 }
 
 // @Summary Get all artists (paginated)
@@ -23,27 +25,18 @@ type ArtistHandler struct {
 // @Security BearerAuth
 // @Param limit query int false "Limit"
 // @Param offset query int false "Offset"
-// @Param name query string false "Name to search for"
+// @Param name query string false "Name to search for; when nothing matches, the response carries suggestions"
 // @Success 200 {object} models.PaginatedArtistsResponse
 // @Router /api/v1/artists [get]
 func (h *ArtistHandler) GetAll(c *gin.Context) {
     name := c.Query("name")
-    limitStr := c.DefaultQuery("limit", "50")
-    offsetStr := c.DefaultQuery("offset", "0")
-    limit, err := strconv.Atoi(limitStr)
-    if err != nil || limit <= 0 {
-        limit = 50
-    }
-    offset, err := strconv.Atoi(offsetStr)
-    if err != nil || offset < 0 {
-        offset = 0
-    }
+    limit, offset := parsePagination(c)
 
     if name != "" {
         // If name query param is present, use search
         artists, err := h.Repo.SearchArtistsByName(c.Request.Context(), name)
         if err != nil {
-            c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+            abortWithError(c, err)
             return
         }
         total := len(artists)
@@ -60,19 +53,23 @@ func (h *ArtistHandler) GetAll(c *gin.Context) {
         } else {
             paged = []models.Artist{}
         }
-        c.JSON(http.StatusOK, gin.H{
+        resp := gin.H{
             "data":    paged,
             "total":   total,
             "limit":   limit,
             "offset":  offset,
             "hasMore": hasMore,
-        })
+        }
+        if total == 0 {
+            resp["suggestions"] = h.Fuzzy.Suggest(name, []string{search.Artist}, suggestionLimit)
+        }
+        c.JSON(http.StatusOK, resp)
         return
     }
 
     artists, total, err := h.Repo.GetArtistsPaginated(c.Request.Context(), limit, offset)
     if err != nil {
-        c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        abortWithError(c, err)
         return
     }
 
@@ -106,14 +103,19 @@ func (h *ArtistHandler) GetAll(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Artist ID"
+// @Param If-None-Match header string false "ETag of a cached copy; 304 when it is still current"
 // @Success 200 {object} models.Artist
-// @Failure 404 {object} map[string]string
+// @Success 304 "Not modified"
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/artists/{id} [get]
 func (h *ArtistHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	artist, err := h.Repo.GetArtistByID(c.Request.Context(), utils.ParseInt(id))
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	artist, err := h.Repo.GetArtistByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, artist)
@@ -125,21 +127,24 @@ func (h *ArtistHandler) GetOne(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param artist body models.Artist true "Artist to create"
+// @Param artist body models.ArtistInput true "Artist to create"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
 // @Success 201 {object} models.Artist
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.Problem "The Idempotency-Key was used for a different request"
+// @Failure 500 {object} models.Problem
 // @Router /api/v1/artists [post]
 func (h *ArtistHandler) Create(c *gin.Context) {
-	var artist models.Artist
-
-	if err := c.ShouldBindJSON(&artist); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	var in models.ArtistInput
+	if !bindValid(c, &in) {
 		return
 	}
+	artist := in.Artist(0)
 	id, err := h.Repo.CreateArtist(c.Request.Context(), artist)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
 	}
 
 	artist.ID = int(id)
@@ -147,6 +152,25 @@ func (h *ArtistHandler) Create(c *gin.Context) {
 	c.JSON(http.StatusCreated, artist)
 }
 
+// @Summary Create, update and delete artists in bulk
+// @Description Applies up to limits.max_bulk_operations operations. In atomic mode (the default) all are applied or none are; in best_effort mode each succeeds or fails on its own. Each operation's data is validated like the single create and update body.
+// @Tags artists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param operations body models.BulkRequest[models.ArtistInput] true "Operations to apply"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Success 200 {object} models.BulkResponse "Every operation succeeded"
+// @Success 207 {object} models.BulkResponse "Some operations failed in best_effort mode"
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.BulkFailure "An operation of an atomic batch failed and nothing was applied"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/artists/bulk [post]
+func (h *ArtistHandler) Bulk(c *gin.Context) {
+	bulkWrite(c, models.ArtistInput.Artist, h.Repo.BulkArtists)
+}
+
 // @Summary Update an artist
 // @Description Updates an existing artist by ID
 // @Tags artists
@@ -154,24 +178,83 @@ func (h *ArtistHandler) Create(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Artist ID"
-// @Param artist body models.Artist true "Artist data to update"
+// @Param artist body models.ArtistInput true "Artist data to update"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
 // @Success 200 {object} models.Artist
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 500 {object} models.Problem
 // @Router /api/v1/artists/{id} [put]
 func (h *ArtistHandler) Update(c *gin.Context) {
-	id := utils.ParseInt(c.Param("id"))
-	var artist models.Artist
-	if err := c.ShouldBindJSON(&artist); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("artist", id)()
+	current, err := h.Repo.GetArtistByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+	var in models.ArtistInput
+	if !bindValid(c, &in) {
+		return
+	}
+	h.save(c, id, in)
+}
+
+// @Summary Patch an artist
+// @Description Partially updates an artist with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), chosen by Content-Type. The patch is applied to the artist in the form of the update body, and the result is validated like a full update before it is saved.
+// @Tags artists
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Artist ID"
+// @Param patch body models.ArtistInput true "Merge patch with the fields to change, or an array of JSON Patch operations"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Artist
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "A test operation did not match"
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 415 {object} models.Problem
+// @Failure 422 {object} models.Problem "An operation's path does not exist"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/artists/{id} [patch]
+func (h *ArtistHandler) Patch(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("artist", id)()
+	artist, err := h.Repo.GetArtistByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, artist) {
 		return
 	}
-	artist.ID = id
+	in, ok := patchBody(c, artist.Input())
+	if !ok {
+		return
+	}
+	h.save(c, id, in)
+}
+
+// save writes the validated update body of artist id and responds with the
+// artist.
+func (h *ArtistHandler) save(c *gin.Context, id int, in models.ArtistInput) {
+	artist := in.Artist(id)
 	if err := h.Repo.UpdateArtist(c.Request.Context(), artist); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, artist)
+	respondResource(c, http.StatusOK, artist)
 }
 
 // @Summary Delete an artist
@@ -180,19 +263,29 @@ func (h *ArtistHandler) Update(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Artist ID"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
 // @Success 200 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "The artist still has albums"
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 500 {object} models.Problem
 // @Router /api/v1/artists/{id} [delete]
 func (h *ArtistHandler) Delete(c *gin.Context) {
-	id := utils.ParseInt(c.Param("id"))
-	// Check if artist exists before deleting
-	_, err := h.Repo.GetArtistByID(c.Request.Context(), id)
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("artist", id)()
+	current, err := h.Repo.GetArtistByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "artist not found"})
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, current) {
 		return
 	}
 	if err := h.Repo.DeleteArtist(c.Request.Context(), id); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "successfully deleted"})
@@ -205,22 +298,67 @@ func (h *ArtistHandler) Delete(c *gin.Context) {
 // @Security BearerAuth
 // @Param name query string true "Artist name to search for"
 // @Success 200 {array} models.Artist
-// @Failure 400 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.NoMatchResponse "No match; similarly named artists are suggested"
 // @Router /api/v1/artists/search [get]
 func (h *ArtistHandler) SearchByName(c *gin.Context) {
 	name := c.Query("name")
 	if name == "" {
-		c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{Error: "name query parameter is required"})
+		abortWithError(c, apperr.Field("name", "is required"))
 		return
 	}
 	artists, err := h.Repo.SearchArtistsByName(c.Request.Context(), name)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	if len(artists) == 0 {
-		c.AbortWithStatusJSON(http.StatusNotFound, models.ErrorResponse{Error: "no artists found"})
+		c.Abort()
+		problem := newProblem(c, apperr.NotFound("no_match", "no artists found"))
+		renderProblem(c, problem.Status, models.NoMatchResponse{
+			Problem:     problem,
+			Suggestions: h.Fuzzy.Suggest(name, []string{search.Artist}, suggestionLimit),
+		})
 		return
 	}
 	c.JSON(http.StatusOK, artists)
 }
+
+// @Summary Get an artist's albums
+// @Description Returns a paginated list of the artist's albums
+// @Tags artists
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Artist ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Param include query string false "Comma-separated relations to embed: artist, tracks, tracks.genre, tracks.media_type"
+// @Success 200 {object} models.Page[models.Album]
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/artists/{id}/albums [get]
+func (h *ArtistHandler) GetAlbums(c *gin.Context) {
+	include, ok := parseInclude(c, "album")
+	if !ok {
+		return
+	}
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetArtistByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	limit, offset := parsePagination(c)
+	albums, total, err := h.Albums.GetAlbumsByArtistID(c.Request.Context(), id, limit, offset)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if err := h.Includes.Albums(c.Request.Context(), albums, include); err != nil {
+		includeFailed(c, err)
+		return
+	}
+	respondPage(c, albums, total, limit, offset)
+}
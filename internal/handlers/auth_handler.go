@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"chinook-api/internal/apperr"
 	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
 	"chinook-api/internal/utils"
@@ -14,58 +15,63 @@ import (
 
 var validate = validator.New()
 
+var (
+	errUnauthorized       = apperr.Unauthorized("unauthorized", "authentication required")
+	errInvalidCredentials = apperr.Unauthorized("invalid_credentials", "invalid username or password")
+	errForbidden          = apperr.Forbidden("forbidden", "you do not have access to this resource")
+)
+
 type AuthHandler struct {
 	UserRepo         *repositories.UserRepository
 	RefreshTokenRepo *repositories.RefreshTokenRepository
+	RefreshTokenTTL  time.Duration
 }
 
 // @Summary User login
-// @Description Authenticates a user and returns a JWT token
+// @Description Authenticates a user and returns a JWT token. Idempotency-Key is ignored: the response carries tokens, which are never stored for replay, and a login can simply be repeated.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param credentials body models.LoginRequest true "User credentials"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /api/v1/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
-		return
-	}
-	if err := validate.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindValid(c, &req) {
 		return
 	}
 
 	user, err := h.UserRepo.GetUserByUsername(c.Request.Context(), req.Username)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username"})
+		if apperr.IsNotFound(err) {
+			err = errInvalidCredentials
+		}
+		abortWithError(c, err)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
+		abortWithError(c, errInvalidCredentials)
 		return
 	}
 
 	token, err := utils.GenerateJWT(user.Username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+		abortWithError(c, err)
 		return
 	}
 
 	refreshToken, err := utils.GenerateRefreshToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate refresh token"})
+		abortWithError(c, err)
 		return
 	}
-	expiresAt := time.Now().Add(7 * 24 * time.Hour) // 7 days
+	expiresAt := time.Now().Add(h.RefreshTokenTTL)
 	if err := h.RefreshTokenRepo.Save(c.Request.Context(), refreshToken, user.Username, expiresAt); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save refresh token"})
+		abortWithError(c, err)
 		return
 	}
 
@@ -76,30 +82,24 @@ func (h *AuthHandler) Login(c *gin.Context) {
 }
 
 // @Summary User signup
-// @Description Registers a new user
+// @Description Registers a new user. Idempotency-Key is ignored, since there is no user yet to scope the key to; a retry of a signup that went through gets 409 user_exists.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param user body models.SignupRequest true "User signup data"
 // @Success 201 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /api/v1/auth/signup [post]
 func (h *AuthHandler) Signup(c *gin.Context) {
 	var req models.SignupRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
-		return
-	}
-
-	if err := validate.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindValid(c, &req) {
 		return
 	}
 
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not hash password"})
+		abortWithError(c, err)
 		return
 	}
 
@@ -111,7 +111,7 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 
 	id, err := h.UserRepo.CreateUser(c.Request.Context(), user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
@@ -121,28 +121,23 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 }
 
 // @Summary Refresh access token
-// @Description Returns a new JWT token given a valid refresh token
+// @Description Returns a new JWT token given a valid refresh token. The refresh token is rotated, so it works once. Idempotency-Key is ignored: the response carries tokens, which are never stored for replay; if the response is lost, log in again.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param refresh body models.RefreshTokenRequest true "Refresh token"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} models.Problem
 // @Router /api/v1/auth/refresh [post]
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	var req models.RefreshTokenRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
-		return
-	}
-	if err := validate.Struct(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindValid(c, &req) {
 		return
 	}
 	rt, err := h.RefreshTokenRepo.Get(c.Request.Context(), req.RefreshToken)
 	if err != nil || rt.ExpiresAt.Before(time.Now()) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+		abortWithError(c, apperr.Unauthorized("invalid_refresh_token", "invalid or expired refresh token"))
 		return
 	}
 	// Optionally, delete the old refresh token to rotate
@@ -150,15 +145,15 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 
 	token, err := utils.GenerateJWT(rt.Username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+		abortWithError(c, err)
 		return
 	}
 	newRefreshToken, err := utils.GenerateRefreshToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate refresh token"})
+		abortWithError(c, err)
 		return
 	}
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
+	expiresAt := time.Now().Add(h.RefreshTokenTTL)
 	_ = h.RefreshTokenRepo.Save(c.Request.Context(), newRefreshToken, rt.Username, expiresAt)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -172,17 +167,20 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 // @Tags auth
 // @Produce json
 // @Success 200 {object} models.Me
-// @Failure 401 {object} map[string]string
+// @Failure 401 {object} models.Problem
 // @Router /api/v1/auth/me [get]
 func (h *AuthHandler) Me(c *gin.Context) {
 	username, exists := c.Get("username")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		abortWithError(c, errUnauthorized)
 		return
 	}
 	user, err := h.UserRepo.GetUserByUsername(c.Request.Context(), username.(string))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		if apperr.IsNotFound(err) {
+			err = errUnauthorized
+		}
+		abortWithError(c, err)
 		return
 	}
 	user.Authenticated = true
@@ -195,23 +193,44 @@ func (h *AuthHandler) Me(c *gin.Context) {
 // @Tags auth
 // @Produce json
 // @Success 200 {object} map[string]string
-// @Failure 401 {object} map[string]string
+// @Failure 401 {object} models.Problem
 // @Router /api/v1/auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
 	_, exists := c.Get("username")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		abortWithError(c, errUnauthorized)
 		return
 	}
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "missing token"})
+		abortWithError(c, apperr.BadRequest("missing_token", "missing token"))
 		return
 	}
 	tokenString := authHeader[len("Bearer "):]
 	if err := h.RefreshTokenRepo.Delete(c.Request.Context(), tokenString); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete refresh token"})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "successfully logged out"})
 }
+
+// RequireRole rejects requests whose authenticated user does not have role.
+func (h *AuthHandler) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, exists := c.Get("username")
+		if !exists {
+			abortWithError(c, errUnauthorized)
+			return
+		}
+		user, err := h.UserRepo.GetUserByUsername(c.Request.Context(), username.(string))
+		if err != nil && !apperr.IsNotFound(err) {
+			abortWithError(c, err)
+			return
+		}
+		if err != nil || user.Role != role {
+			abortWithError(c, errForbidden)
+			return
+		}
+		c.Next()
+	}
+}
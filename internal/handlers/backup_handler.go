@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"chinook-api/internal/backup"
+	"chinook-api/internal/database"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BackupHandler struct {
+	Manager *backup.Manager
+}
+
+// @Summary Create a database backup
+// @Description Writes a consistent snapshot of the SQLite database with VACUUM INTO
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Success 201 {object} models.Backup
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.Problem "The Idempotency-Key was used for a different request"
+// @Failure 501 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/admin/backups [post]
+func (h *BackupHandler) Create(c *gin.Context) {
+	b, err := h.Manager.Create(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, database.ErrUnsupported) {
+			err = database.ErrUnsupported.Withf("backups are only supported on SQLite")
+		}
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, b)
+}
+
+// @Summary List database backups
+// @Description Returns the stored backups, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Backup
+// @Failure 403 {object} models.Problem
+// @Router /api/v1/admin/backups [get]
+func (h *BackupHandler) List(c *gin.Context) {
+	backups, err := h.Manager.List()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, backups)
+}
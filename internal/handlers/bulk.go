@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BulkLimit is the most operations accepted in one bulk request. It is set
+// from configuration at startup.
+var BulkLimit = 1000
+
+const (
+	bulkAtomic     = "atomic"
+	bulkBestEffort = "best_effort"
+)
+
+// bulkWrite handles a bulk request for one resource. Each operation's data
+// is decoded and validated exactly like the body of the single create or
+// update endpoint, then converted with toModel and applied by run. Invalid
+// operations fail on their own in best_effort mode; in atomic mode, the
+// default, any invalid or failed operation means nothing is written.
+//
+// The response lists every operation's outcome in request order: 200 when
+// all succeeded, 207 when some failed in best_effort mode, and a 422 problem
+// with the same results when an atomic batch was rejected.
+func bulkWrite[In, M any](
+	c *gin.Context,
+	toModel func(in In, id int) M,
+	run func(ctx context.Context, atomic bool, ops []repositories.BulkOp[M]) ([]repositories.BulkResult, error),
+) {
+	var req models.BulkRequest[json.RawMessage]
+	if !bindValid(c, &req) {
+		return
+	}
+	if len(req.Operations) > BulkLimit {
+		abortWithError(c, apperr.Field("operations", fmt.Sprintf("must be at most %d items", BulkLimit)))
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = bulkAtomic
+	}
+	atomic := req.Mode == bulkAtomic
+
+	resp := models.BulkResponse{Mode: req.Mode, Results: make([]models.BulkItemResult, len(req.Operations))}
+	ops := make([]repositories.BulkOp[M], 0, len(req.Operations))
+	indexes := make([]int, 0, len(req.Operations))
+	invalid := false
+	for i, op := range req.Operations {
+		resp.Results[i] = models.BulkItemResult{Index: i, Op: op.Op}
+		parsed, err := parseBulkOp(op, toModel)
+		if err != nil {
+			setBulkResult(&resp.Results[i], 0, err)
+			invalid = true
+			continue
+		}
+		ops = append(ops, parsed)
+		indexes = append(indexes, i)
+	}
+
+	var results []repositories.BulkResult
+	if atomic && invalid {
+		results = make([]repositories.BulkResult, len(ops))
+		for i := range results {
+			results[i].Err = repositories.ErrBulkNotApplied
+		}
+	} else if len(ops) > 0 {
+		var err error
+		results, err = run(c.Request.Context(), atomic, ops)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+	}
+	for i, result := range results {
+		if atomic && result.Err != nil {
+			if _, ok := apperr.As(result.Err); !ok {
+				// the batch failed on the server, not because of its content
+				abortWithError(c, result.Err)
+				return
+			}
+		}
+		setBulkResult(&resp.Results[indexes[i]], result.ID, result.Err)
+	}
+
+	for _, result := range resp.Results {
+		if result.Error == nil {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+	switch {
+	case resp.Failed == 0:
+		c.JSON(http.StatusOK, resp)
+	case atomic:
+		failure := models.BulkFailure{Problem: newProblem(c, errBulkFailed), BulkResponse: resp}
+		c.Error(errBulkFailed)
+		c.Abort()
+		renderProblem(c, failure.Status, failure)
+	default:
+		c.JSON(http.StatusMultiStatus, resp)
+	}
+}
+
+var errBulkFailed = apperr.New(apperr.KindUnprocessable, "bulk_failed", "an operation of the atomic batch failed, so none were applied; see results")
+
+// parseBulkOp checks the op and id of an operation and decodes its data.
+func parseBulkOp[In, M any](op models.BulkOperation[json.RawMessage], toModel func(in In, id int) M) (repositories.BulkOp[M], error) {
+	parsed := repositories.BulkOp[M]{Op: op.Op, ID: op.ID}
+	hasData := len(op.Data) > 0 && !bytes.Equal(op.Data, []byte("null"))
+	switch op.Op {
+	case repositories.BulkCreate:
+		if op.ID != 0 {
+			return parsed, apperr.Field("id", "must be omitted for create")
+		}
+	case repositories.BulkUpdate, repositories.BulkDelete:
+		if op.ID <= 0 {
+			return parsed, apperr.Field("id", "must be a positive integer")
+		}
+	default:
+		return parsed, apperr.Field("op", "must be one of create update delete")
+	}
+	if op.Op == repositories.BulkDelete {
+		if hasData {
+			return parsed, apperr.Field("data", "must be omitted for delete")
+		}
+		return parsed, nil
+	}
+	if !hasData {
+		return parsed, apperr.Field("data", "is required")
+	}
+	var in In
+	if err := decodeStrict(bytes.NewReader(op.Data), &in); err != nil {
+		return parsed, err
+	}
+	if err := validateStruct(&in); err != nil {
+		return parsed, err
+	}
+	parsed.Data = toModel(in, op.ID)
+	return parsed, nil
+}
+
+// setBulkResult records the outcome of an operation: the status its single
+// endpoint would have answered with and the id, or the error.
+func setBulkResult(result *models.BulkItemResult, id int, err error) {
+	if err == nil {
+		result.ID = id
+		result.Status = http.StatusOK
+		if result.Op == repositories.BulkCreate {
+			result.Status = http.StatusCreated
+		}
+		return
+	}
+	if e, ok := apperr.As(err); ok && e.Kind != apperr.KindInternal {
+		result.Status = e.Kind.Status()
+		result.Error = &models.BulkItemError{Code: e.Code, Detail: e.Message, Fields: e.Fields}
+		return
+	}
+	result.Status = http.StatusInternalServerError
+	result.Error = &models.BulkItemError{Code: "internal_error", Detail: "an internal error occurred"}
+}
@@ -1,29 +1,53 @@
 package handlers
 
 import (
+	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type CustomerHandler struct {
-	Repo *repositories.CustomerRepository
+	Repo      *repositories.CustomerRepository
+	Invoices  *repositories.InvoiceRepository
+	Employees *repositories.EmployeeRepository
+	Includes  *repositories.Includer
 }
 
 // @Summary Get all customers
-// @Description Returns a list of all customers
+// @Description Returns all customers, or a page with limit and offset, filtered by equality on first_name, last_name, company, city, state, country, postal_code, email or support_rep_id, e.g. ?country=Canada. As CSV, NDJSON or XLSX the rows are streamed as they are read.
 // @Tags customers
 // @Produce json
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 // @Security BearerAuth
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Param format query string false "Export format instead of JSON; also chosen by an Accept header of text/csv, application/x-ndjson or the XLSX media type" Enums(json, csv, ndjson, xlsx)
+// @Param sort query string false "Comma-separated fields to sort by, each optionally prefixed with - for descending, e.g. country,last_name"
 // @Success 200 {array} models.Customer
-// @Failure 404 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
 // @Router /api/v1/customers [get]
 func (h *CustomerHandler) GetAll(c *gin.Context) {
-	customers, err := h.Repo.GetAllCustomers(c.Request.Context())
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	q, ok := parseListQuery(c, "customer")
+	if !ok {
+		return
+	}
+	if format != "" {
+		streamExport(c, format, "customers", func(fn func(models.Customer) error) error {
+			return h.Repo.EachCustomer(c.Request.Context(), q, fn)
+		})
+		return
+	}
+	customers, err := h.Repo.GetAllCustomers(c.Request.Context(), q)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, customers)
@@ -35,15 +59,211 @@ func (h *CustomerHandler) GetAll(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Customer ID"
+// @Param If-None-Match header string false "ETag of a cached copy; 304 when it is still current"
 // @Success 200 {object} models.Customer
-// @Failure 404 {object} models.ErrorResponse
+// @Success 304 "Not modified"
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/customers/{id} [get]
 func (h *CustomerHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	customer, err := h.Repo.GetCustomerByID(c.Request.Context(), utils.ParseInt(id))
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	customer, err := h.Repo.GetCustomerByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, customer)
 }
+
+// @Summary Get a customer's invoices
+// @Description Returns a paginated list of the customer's invoices, oldest first
+// @Tags customers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Customer ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Param include query string false "Comma-separated relations to embed: customer, lines, lines.track, lines.track.album"
+// @Success 200 {object} models.Page[models.Invoice]
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/customers/{id}/invoices [get]
+func (h *CustomerHandler) GetInvoices(c *gin.Context) {
+	include, ok := parseInclude(c, "invoice")
+	if !ok {
+		return
+	}
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetCustomerByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	limit, offset := parsePagination(c)
+	invoices, total, err := h.Invoices.GetInvoicesByCustomerID(c.Request.Context(), id, limit, offset)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if err := h.Includes.Invoices(c.Request.Context(), invoices, include); err != nil {
+		includeFailed(c, err)
+		return
+	}
+	respondPage(c, invoices, total, limit, offset)
+}
+
+// @Summary Create a customer
+// @Description Creates a customer. Country, postal code, phone, fax and email are validated, and support_rep_id must name an existing employee.
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param customer body models.CustomerInput true "Customer to create"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Success 201 {object} models.Customer
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.Problem "The Idempotency-Key was used for a different request"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/customers [post]
+func (h *CustomerHandler) Create(c *gin.Context) {
+	var in models.CustomerInput
+	if !bindValid(c, &in) || !checkEmployeeRef(c, h.Employees, "support_rep_id", in.SupportRepId) {
+		return
+	}
+	customer := in.Customer(0)
+	id, err := h.Repo.CreateCustomer(c.Request.Context(), customer)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	customer.CustomerId = int(id)
+	c.JSON(http.StatusCreated, customer)
+}
+
+// @Summary Update a customer
+// @Description Replaces a customer. Country, postal code, phone, fax and email are validated, and support_rep_id must name an existing employee.
+// @Tags customers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Customer ID"
+// @Param customer body models.CustomerInput true "Customer data"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Customer
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/customers/{id} [put]
+func (h *CustomerHandler) Update(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("customer", id)()
+	current, err := h.Repo.GetCustomerByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+	var in models.CustomerInput
+	if !bindValid(c, &in) {
+		return
+	}
+	h.save(c, id, in)
+}
+
+// @Summary Patch a customer
+// @Description Partially updates a customer with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), chosen by Content-Type. The patch is applied to the customer in the form of the update body, and the result is validated like a full update before it is saved. Removing support_rep_id unassigns the customer.
+// @Tags customers
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Customer ID"
+// @Param patch body models.CustomerInput true "Merge patch with the fields to change, or an array of JSON Patch operations"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Customer
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "A test operation did not match"
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 415 {object} models.Problem
+// @Failure 422 {object} models.Problem "An operation's path does not exist"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/customers/{id} [patch]
+func (h *CustomerHandler) Patch(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("customer", id)()
+	customer, err := h.Repo.GetCustomerByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, customer) {
+		return
+	}
+	in, ok := patchBody(c, customer.Input())
+	if !ok {
+		return
+	}
+	h.save(c, id, in)
+}
+
+// save checks the support rep of the validated update body, writes customer
+// id and responds with the customer.
+func (h *CustomerHandler) save(c *gin.Context, id int, in models.CustomerInput) {
+	if !checkEmployeeRef(c, h.Employees, "support_rep_id", in.SupportRepId) {
+		return
+	}
+	customer := in.Customer(id)
+	if err := h.Repo.UpdateCustomer(c.Request.Context(), customer); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	respondResource(c, http.StatusOK, customer)
+}
+
+// @Summary Delete a customer
+// @Description Deletes a customer. Customers with invoices are kept for the sales history and cannot be deleted.
+// @Tags customers
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Customer ID"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/customers/{id} [delete]
+func (h *CustomerHandler) Delete(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("customer", id)()
+	current, err := h.Repo.GetCustomerByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+	if err := h.Repo.DeleteCustomer(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "successfully deleted"})
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type EmployeeHandler struct {
-	Repo *repositories.EmployeeRepository
+	Repo      *repositories.EmployeeRepository
+	Customers *repositories.CustomerRepository
 }
 
 // @Summary Get all employees
@@ -22,7 +25,7 @@ type EmployeeHandler struct {
 func (h *EmployeeHandler) GetAll(c *gin.Context) {
 	employees, err := h.Repo.GetAllEmployees(c.Request.Context())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, employees)
@@ -34,14 +37,353 @@ func (h *EmployeeHandler) GetAll(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Employee ID"
+// @Param If-None-Match header string false "ETag of a cached copy; 304 when it is still current"
 // @Success 200 {object} models.Employee
+// @Success 304 "Not modified"
 // @Router /api/v1/employees/{id} [get]
 func (h *EmployeeHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	employee, err := h.Repo.GetEmployeeByID(c.Request.Context(), utils.ParseInt(id))
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	employee, err := h.Repo.GetEmployeeByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, employee)
 }
+
+// @Summary Get an employee's customers
+// @Description Returns a paginated list of the customers the employee supports
+// @Tags employees
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Employee ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.Page[models.Customer]
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees/{id}/customers [get]
+func (h *EmployeeHandler) GetCustomers(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetEmployeeByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	limit, offset := parsePagination(c)
+	customers, total, err := h.Customers.GetCustomersBySupportRepID(c.Request.Context(), id, limit, offset)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	respondPage(c, customers, total, limit, offset)
+}
+
+// @Summary Get the org chart
+// @Description Returns the management hierarchy as a tree from the employees who report to nobody. Employees caught in a ReportsTo cycle, or reporting into one, are listed under unreachable and the cycles themselves under cycles.
+// @Tags employees
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.OrgChart
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees/tree [get]
+func (h *EmployeeHandler) GetTree(c *gin.Context) {
+	chart, err := h.Repo.GetOrgChart(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, chart)
+}
+
+// @Summary Get an employee's reports
+// @Description Returns the employee's direct reports, or everyone below them when transitive is true, nearest first with their depth below the employee
+// @Tags employees
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Employee ID"
+// @Param transitive query bool false "Include indirect reports"
+// @Success 200 {object} models.OrgMembers
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees/{id}/reports [get]
+func (h *EmployeeHandler) GetReports(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetEmployeeByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	transitive := false
+	if raw := c.Query("transitive"); raw != "" {
+		var err error
+		if transitive, err = strconv.ParseBool(raw); err != nil {
+			abortWithError(c, apperr.Field("transitive", "must be true or false"))
+			return
+		}
+	}
+	reports, err := h.Repo.GetReports(c.Request.Context(), id, transitive)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// @Summary Get an employee's management chain
+// @Description Returns the employee's managers from their direct manager up to the top of the hierarchy
+// @Tags employees
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Employee ID"
+// @Success 200 {object} models.OrgMembers
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees/{id}/chain [get]
+func (h *EmployeeHandler) GetChain(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetEmployeeByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	chain, err := h.Repo.GetChain(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, chain)
+}
+
+// @Summary Create an employee
+// @Description Creates an employee. Country, postal code, phone, fax and email are validated, and reports_to must name an existing employee.
+// @Tags employees
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param employee body models.EmployeeInput true "Employee to create"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Success 201 {object} models.Employee
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.Problem "The Idempotency-Key was used for a different request"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees [post]
+func (h *EmployeeHandler) Create(c *gin.Context) {
+	var in models.EmployeeInput
+	if !bindValid(c, &in) {
+		return
+	}
+	if !checkEmployeeRef(c, h.Repo, "reports_to", in.ReportsTo) {
+		return
+	}
+	employee := in.Employee(0)
+	id, err := h.Repo.CreateEmployee(c.Request.Context(), employee)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	employee.EmployeeId = int(id)
+	c.JSON(http.StatusCreated, employee)
+}
+
+// @Summary Update an employee
+// @Description Replaces an employee. A change of manager that would make the employee report to themselves, directly or through others, is rejected.
+// @Tags employees
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Employee ID"
+// @Param employee body models.EmployeeInput true "Employee data"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Employee
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees/{id} [put]
+func (h *EmployeeHandler) Update(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("employee", id)()
+	current, err := h.Repo.GetEmployeeByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+	var in models.EmployeeInput
+	if !bindValid(c, &in) {
+		return
+	}
+	h.save(c, id, in)
+}
+
+// @Summary Patch an employee
+// @Description Partially updates an employee with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), chosen by Content-Type. The patch is applied to the employee in the form of the update body, and the result is validated like a full update before it is saved. A change of manager that would create a reporting cycle is rejected.
+// @Tags employees
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Employee ID"
+// @Param patch body models.EmployeeInput true "Merge patch with the fields to change, or an array of JSON Patch operations"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Employee
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "A test operation did not match"
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 415 {object} models.Problem
+// @Failure 422 {object} models.Problem "An operation's path does not exist"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees/{id} [patch]
+func (h *EmployeeHandler) Patch(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("employee", id)()
+	employee, err := h.Repo.GetEmployeeByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, employee) {
+		return
+	}
+	in, ok := patchBody(c, employee.Input())
+	if !ok {
+		return
+	}
+	h.save(c, id, in)
+}
+
+// save checks that the manager of the validated update body exists, writes
+// employee id, which rejects a manager who would close a cycle, and
+// responds with the employee.
+func (h *EmployeeHandler) save(c *gin.Context, id int, in models.EmployeeInput) {
+	ctx := c.Request.Context()
+	if !checkEmployeeRef(c, h.Repo, "reports_to", in.ReportsTo) {
+		return
+	}
+	employee := in.Employee(id)
+	if err := h.Repo.UpdateEmployee(ctx, employee); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	respondResource(c, http.StatusOK, employee)
+}
+
+// @Summary Delete an employee
+// @Description Deletes an employee and unlinks any user accounts from them. Employees who still support customers or have direct reports cannot be deleted; reassign them first.
+// @Tags employees
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Employee ID"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees/{id} [delete]
+func (h *EmployeeHandler) Delete(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("employee", id)()
+	current, err := h.Repo.GetEmployeeByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, current) {
+		return
+	}
+	if err := h.Repo.DeleteEmployee(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "successfully deleted"})
+}
+
+// @Summary Reassign an employee's customers
+// @Description Moves customers from this support rep to another in one transaction. Without customer_ids, all of the rep's customers are moved; otherwise every listed customer must be supported by this rep or none are moved.
+// @Tags employees
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Employee ID of the current support rep"
+// @Param request body models.ReassignCustomersRequest true "Target rep and optional customer ids"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Success 200 {object} models.ReassignCustomersResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem "The Idempotency-Key was used for a different request"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/employees/{id}/reassign-customers [post]
+func (h *EmployeeHandler) ReassignCustomers(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetEmployeeByID(ctx, id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	var req models.ReassignCustomersRequest
+	if !bindValid(c, &req) {
+		return
+	}
+	if req.ToEmployeeID == id {
+		abortWithError(c, apperr.Field("to_employee_id", "must differ from the current support rep"))
+		return
+	}
+	if !checkEmployeeRef(c, h.Repo, "to_employee_id", &req.ToEmployeeID) {
+		return
+	}
+	moved, err := h.Customers.ReassignCustomers(ctx, id, req.ToEmployeeID, req.CustomerIDs)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ReassignCustomersResponse{
+		FromEmployeeID: id,
+		ToEmployeeID:   req.ToEmployeeID,
+		Reassigned:     len(moved),
+		CustomerIDs:    moved,
+	})
+}
+
+// checkEmployeeRef writes a 400 for field and returns false when id names no
+// employee. A nil id is valid.
+func checkEmployeeRef(c *gin.Context, employees *repositories.EmployeeRepository, field string, id *int) bool {
+	if id == nil {
+		return true
+	}
+	if _, err := employees.GetEmployeeByID(c.Request.Context(), *id); err != nil {
+		if apperr.IsNotFound(err) {
+			err = apperr.Field(field, "employee not found")
+		}
+		abortWithError(c, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"bytes"
+	"chinook-api/internal/apperr"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETags are content hashes: the first 16 bytes of the SHA-256 of the JSON
+// body. A single resource has a strong ETag, which is the hash of exactly
+// what a plain GET /:id returns for it, so writes can compare If-Match against the
+// row they are about to change without a version column. Collections get
+// weak ETags, since they only promise an equivalent listing.
+
+var errPreconditionFailed = apperr.New(apperr.KindPreconditionFailed, "precondition_failed", "the resource has changed since it was read; fetch it again and retry")
+
+func etagOf(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// resourceETag is the strong ETag of v as GET /:id renders it.
+func resourceETag(v any) (string, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return etagOf(body, false), nil
+}
+
+// matchETag reports whether etag is in the If-Match or If-None-Match header
+// value list. Weak comparison ignores the W/ prefix; strong comparison, used
+// for If-Match, never matches a weak tag.
+func matchETag(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+			if candidate == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch enforces the If-Match header of a PUT, PATCH or DELETE
+// against current, the resource as GET /:id returns it. It writes the 412
+// itself, with the current ETag, and returns false when the client's copy is
+// stale. Requests without If-Match always pass.
+func checkIfMatch(c *gin.Context, current any) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+	etag, err := resourceETag(current)
+	if err != nil {
+		abortWithError(c, err)
+		return false
+	}
+	if !matchETag(header, etag, false) {
+		c.Header("ETag", etag)
+		abortWithError(c, errPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// respondResource writes v as the new state of a single resource, with its
+// ETag so the client can make its next conditional write.
+func respondResource(c *gin.Context, status int, v any) {
+	if etag, err := resourceETag(v); err == nil {
+		c.Header("ETag", etag)
+	}
+	c.JSON(status, v)
+}
+
+// resourceLocks serialize writes to the same resource, so that no other
+// write can land between the If-Match check and the update. Locks are
+// striped by a hash of the resource, which bounds their number.
+var resourceLocks [64]sync.Mutex
+
+// lockResource locks the resource of the given kind and id and returns the
+// unlock function.
+func lockResource(kind string, id int) func() {
+	h := fnv.New32a()
+	h.Write([]byte(kind))
+	h.Write([]byte{byte(id), byte(id >> 8), byte(id >> 16), byte(id >> 24)})
+	mu := &resourceLocks[h.Sum32()%uint32(len(resourceLocks))]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// ETags buffers successful GET responses to add an ETag, strong for single
+// resources (routes ending in /:id, without include) and weak for
+// everything else, and
+// answers a matching If-None-Match with 304 Not Modified. Handlers that
+// flush, such as streaming exports, are passed through untouched.
+func ETags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		w := &etagWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.passthrough {
+			return
+		}
+		if !w.committed {
+			if w.statusSet {
+				w.ResponseWriter.WriteHeader(w.status)
+			}
+			return
+		}
+		if w.status == http.StatusOK && w.body.Len() > 0 && w.Header().Get("ETag") == "" {
+			// A strong tag must equal the resourceETag that If-Match is checked
+			// against, which only the bare resource does; with related rows
+			// embedded the body is a different representation.
+			strong := strings.HasSuffix(c.FullPath(), "/:id") && c.Query("include") == ""
+			etag := etagOf(w.body.Bytes(), !strong)
+			w.Header().Set("ETag", etag)
+			if inm := c.GetHeader("If-None-Match"); inm != "" && matchETag(inm, etag, true) {
+				w.Header().Del("Content-Type")
+				w.Header().Del("Content-Length")
+				w.ResponseWriter.WriteHeader(http.StatusNotModified)
+				w.ResponseWriter.WriteHeaderNow()
+				return
+			}
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// etagWriter holds back the status and body until the handler is done.
+type etagWriter struct {
+	gin.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	statusSet   bool
+	committed   bool
+	passthrough bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !w.committed {
+		w.status = code
+		w.statusSet = true
+	}
+}
+
+func (w *etagWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.committed = true
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	w.committed = true
+	return w.body.Write(b)
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush switches to passthrough: what was buffered is sent and everything
+// after goes straight to the client.
+func (w *etagWriter) Flush() {
+	if !w.passthrough {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *etagWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *etagWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	if !w.committed {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *etagWriter) Written() bool {
+	return w.passthrough || w.committed
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"chinook-api/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func etagRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	album := models.Album{ID: 1, Title: "For Those About To Rock We Salute You", ArtistID: 1}
+	r := gin.New()
+	r.Use(ETags())
+	r.GET("/albums", func(c *gin.Context) { c.JSON(http.StatusOK, []models.Album{album}) })
+	r.GET("/albums/:id", func(c *gin.Context) {
+		if c.Query("include") != "" {
+			c.JSON(http.StatusOK, gin.H{"album": album, "artist": gin.H{"name": "AC/DC"}})
+			return
+		}
+		c.JSON(http.StatusOK, album)
+	})
+	r.PATCH("/albums/:id", func(c *gin.Context) {
+		if checkIfMatch(c, album) {
+			respondResource(c, http.StatusOK, album)
+		}
+	})
+	return r
+}
+
+func serve(r *gin.Engine, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestETagStrength(t *testing.T) {
+	r := etagRouter()
+	tests := []struct {
+		target string
+		weak   bool
+	}{
+		{"/albums/1", false},
+		{"/albums/1?include=artist", true},
+		{"/albums", true},
+	}
+	for _, tt := range tests {
+		etag := serve(r, http.MethodGet, tt.target, nil).Header().Get("ETag")
+		if etag == "" || strings.HasPrefix(etag, "W/") != tt.weak {
+			t.Errorf("GET %s: ETag %q, want weak %v", tt.target, etag, tt.weak)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	r := etagRouter()
+	plain := serve(r, http.MethodGet, "/albums/1", nil).Header().Get("ETag")
+	included := serve(r, http.MethodGet, "/albums/1?include=artist", nil).Header().Get("ETag")
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    int
+	}{
+		{"plain GET", plain, http.StatusOK},
+		{"GET with include", included, http.StatusPreconditionFailed},
+		{"any", "*", http.StatusOK},
+		{"stale", `"0123456789abcdef0123456789abcdef"`, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		w := serve(r, http.MethodPatch, "/albums/1", http.Header{"If-Match": {tt.ifMatch}})
+		if w.Code != tt.want {
+			t.Errorf("%s: PATCH with If-Match %s = %d, want %d", tt.name, tt.ifMatch, w.Code, tt.want)
+		}
+		if w.Code == http.StatusPreconditionFailed && w.Header().Get("ETag") != plain {
+			t.Errorf("%s: 412 carried ETag %q, want the current %q", tt.name, w.Header().Get("ETag"), plain)
+		}
+	}
+
+	if w := serve(r, http.MethodGet, "/albums/1", http.Header{"If-None-Match": {plain}}); w.Code != http.StatusNotModified {
+		t.Errorf("GET with a matching If-None-Match = %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/export"
+	"chinook-api/internal/repositories"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// exportFlushRows is how many rows of a streaming export are sent at once.
+const exportFlushRows = 100
+
+// parseListQuery reads the filter, sort, limit and offset parameters of a
+// list request for resource. The result is not paginated unless limit or
+// offset is given. On an invalid value it answers 400 itself and returns
+// false.
+func parseListQuery(c *gin.Context, resource string) (repositories.ListQuery, bool) {
+	q, err := listQuery(resource, c.Request.URL.Query())
+	if err != nil {
+		abortWithError(c, err)
+		return q, false
+	}
+	return q, true
+}
+
+func listQuery(resource string, values url.Values) (repositories.ListQuery, error) {
+	q, err := repositories.ParseListQuery(resource, values)
+	if err != nil {
+		return q, err
+	}
+	if n, err := strconv.Atoi(values.Get("limit")); err == nil && n > 0 {
+		q.Limit = n
+	}
+	if n, err := strconv.Atoi(values.Get("offset")); err == nil && n > 0 {
+		q.Offset = n
+	}
+	return q, nil
+}
+
+// exportFormat returns the format a list request asks for, from the format
+// query parameter or else the Accept header, or "" for JSON. Exports are
+// flat, so they cannot be combined with include. On an invalid request it
+// answers 400 itself and returns false.
+func exportFormat(c *gin.Context) (export.Format, bool) {
+	var format export.Format
+	if name := c.Query("format"); name != "" && name != "json" {
+		f, ok := export.ParseFormat(name)
+		if !ok {
+			abortWithError(c, apperr.Field("format", "must be one of json csv ndjson xlsx"))
+			return "", false
+		}
+		format = f
+	} else if name == "" {
+		format = acceptedFormat(c.GetHeader("Accept"))
+	}
+	if format != "" && c.Query("include") != "" {
+		abortWithError(c, apperr.Field("include", "cannot be used with "+string(format)+" exports"))
+		return "", false
+	}
+	return format, true
+}
+
+// acceptedFormat returns the export format the Accept header prefers over
+// JSON, or "" when it prefers JSON or names no export format. Wildcards
+// count for JSON, so browsers and clients that accept anything keep getting
+// JSON.
+func acceptedFormat(accept string) export.Format {
+	var best export.Format
+	bestQ, jsonQ := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+			continue
+		}
+		if f, ok := export.ForMediaType(mediaType); ok && q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	if bestQ > jsonQ {
+		return best
+	}
+	return ""
+}
+
+// streamExport writes the rows that each yields as a file download named
+// after name, flushing them to the client as they are read. A failure
+// before anything was sent gets the usual problem response; after that the
+// connection is cut, so the client sees a failed download instead of a
+// file that looks complete but is short.
+func streamExport[T any](c *gin.Context, format export.Format, name string, each func(fn func(T) error) error) {
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+name+"."+string(format)+`"`)
+	w, err := export.New[T](format, c.Writer, name)
+	if err != nil {
+		exportFailed(c, err)
+		return
+	}
+
+	rows := 0
+	err = each(func(v T) error {
+		if err := w.Write(v); err != nil {
+			return err
+		}
+		rows++
+		if format.Streams() && rows%exportFlushRows == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		w.Discard()
+		exportFailed(c, err)
+		return
+	}
+	// send the headers now, so that a file assembled on Close goes
+	// straight to the client
+	c.Writer.Flush()
+	if err := w.Close(); err != nil {
+		exportFailed(c, err)
+		return
+	}
+	c.Writer.Flush()
+}
+
+func exportFailed(c *gin.Context, err error) {
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Disposition")
+		abortWithError(c, err)
+		return
+	}
+	c.Error(err)
+	c.Abort()
+	log.Error().Err(err).Str("path", c.Request.URL.Path).Msg("export failed after it started; closing the connection")
+	if conn, _, err := c.Writer.Hijack(); err == nil {
+		conn.Close()
+	}
+}
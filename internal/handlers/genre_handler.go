@@ -1,15 +1,17 @@
 package handlers
 
 import (
+	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type GenreHandler struct {
-	Repo *repositories.GenreRepository
+	Repo     *repositories.GenreRepository
+	Tracks   *repositories.TrackRepository
+	Includes *repositories.Includer
 }
 
 // @Summary Get all genres
@@ -22,7 +24,7 @@ type GenreHandler struct {
 func (h *GenreHandler) GetAll(c *gin.Context) {
 	genres, err := h.Repo.GetAllGenres(c.Request.Context())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, genres)
@@ -37,11 +39,53 @@ func (h *GenreHandler) GetAll(c *gin.Context) {
 // @Success 200 {object} models.Genre
 // @Router /api/v1/genres/{id} [get]
 func (h *GenreHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	genre, err := h.Repo.GetGenreByID(c.Request.Context(), utils.ParseInt(id))
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	genre, err := h.Repo.GetGenreByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, genre)
 }
+
+// @Summary Get a genre's tracks
+// @Description Returns a paginated list of the tracks in the genre
+// @Tags genres
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Genre ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Param include query string false "Comma-separated relations to embed: album, album.artist, album.tracks, genre, media_type"
+// @Success 200 {object} models.Page[models.Track]
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/genres/{id}/tracks [get]
+func (h *GenreHandler) GetTracks(c *gin.Context) {
+	include, ok := parseInclude(c, "track")
+	if !ok {
+		return
+	}
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetGenreByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	limit, offset := parsePagination(c)
+	tracks, total, err := h.Tracks.GetTracksByGenreID(c.Request.Context(), id, limit, offset)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if err := h.Includes.Tracks(c.Request.Context(), tracks, include); err != nil {
+		includeFailed(c, err)
+		return
+	}
+	respondPage[models.Track](c, tracks, total, limit, offset)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/graph"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/rs/zerolog/log"
+)
+
+// GraphQLHandler serves the GraphQL API over the same repositories as the
+// REST endpoints. Call BuildSchema once PageLimits are set, before serving.
+type GraphQLHandler struct {
+	Artists    *repositories.ArtistRepository
+	Albums     *repositories.AlbumRepository
+	Tracks     *repositories.TrackRepository
+	Genres     *repositories.GenreRepository
+	MediaTypes *repositories.MediaTypeRepository
+	Playlists  *repositories.PlaylistRepository
+	Customers  *repositories.CustomerRepository
+	Invoices   *repositories.InvoiceRepository
+	Employees  *repositories.EmployeeRepository
+	Batch      *repositories.Batcher
+	Limits     graph.Limits
+
+	schema graphql.Schema
+}
+
+// BuildSchema builds the schema the handler serves.
+func (h *GraphQLHandler) BuildSchema() error {
+	schema, err := h.buildSchema()
+	h.schema = schema
+	return err
+}
+
+// @Summary Run a GraphQL query or mutation
+// @Description Runs a GraphQL operation over artists, albums, tracks, genres, media types, playlists, customers, invoices and employees. Queries deeper than graphql.max_depth or more complex than graphql.max_complexity are rejected before they run. Errors are reported in the errors array with the problem code in extensions.code; the status is 400 only when the query did not run at all.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.GraphQLRequest true "GraphQL query, operation name and variables"
+// @Success 200 {object} models.GraphQLResponse
+// @Failure 400 {object} models.GraphQLResponse "The query does not parse, is invalid or exceeds the limits"
+// @Router /api/v1/graphql [post]
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req models.GraphQLRequest
+	if !bindValid(c, &req) {
+		return
+	}
+	ctx := context.WithValue(c.Request.Context(), graphqlLoadersKey{}, newGraphQLLoaders(h.Batch))
+	result, ran := graph.Execute(ctx, h.schema, req, h.Limits)
+
+	resp := models.GraphQLResponse{Data: result.Data}
+	for _, fe := range result.Errors {
+		resp.Errors = append(resp.Errors, graphqlError(c, fe))
+	}
+	status := http.StatusOK
+	if !ran {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, resp)
+}
+
+// graphqlError converts an error of a GraphQL result the way newProblem
+// converts errors to problems: apperr errors keep their code and message,
+// errors in the query itself get invalid_query, and anything else is logged
+// and reported as a bare internal error.
+func graphqlError(c *gin.Context, fe gqlerrors.FormattedError) models.GraphQLError {
+	out := models.GraphQLError{Message: fe.Message, Path: fe.Path}
+	for _, loc := range fe.Locations {
+		out.Locations = append(out.Locations, models.GraphQLLocation{Line: loc.Line, Column: loc.Column})
+	}
+
+	cause := graph.Cause(fe)
+	if e, ok := apperr.As(cause); ok && e.Kind != apperr.KindInternal {
+		out.Message = e.Message
+		out.Extensions = map[string]any{"code": e.Code}
+		if len(e.Fields) > 0 {
+			out.Extensions["fields"] = e.Fields
+		}
+		return out
+	}
+	if fe.Path == nil {
+		// rejected by the parser or validation, before any resolver ran
+		out.Extensions = map[string]any{"code": "invalid_query"}
+		return out
+	}
+
+	requestID := c.GetString("request_id")
+	log.Error().Err(cause).Str("request_id", requestID).Str("path", c.Request.URL.Path).Interface("field", fe.Path).Msg("internal error")
+	out.Message = "an internal error occurred"
+	out.Extensions = map[string]any{"code": "internal_error", "request_id": requestID}
+	return out
+}
+
+// @Summary GraphiQL
+// @Description Serves the GraphiQL IDE for the GraphQL endpoint. Only available outside production.
+// @Tags graphql
+// @Produce html
+// @Success 200 {string} string "GraphiQL page"
+// @Router /api/v1/graphql [get]
+func (h *GraphQLHandler) GraphiQL(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(graphiQLPage))
+}
+
+// graphiQLPage loads GraphiQL from a CDN and points it at the page's own
+// URL, which serves POST /graphql.
+const graphiQLPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Chinook GraphiQL</title>
+  <style>body { margin: 0; height: 100vh; } #graphiql { height: 100vh; }</style>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+</head>
+<body>
+  <div id="graphiql">Loading...</div>
+  <script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+  <script>
+    const fetcher = GraphiQL.createFetcher({ url: window.location.pathname });
+    ReactDOM.createRoot(document.getElementById('graphiql')).render(
+      React.createElement(GraphiQL, { fetcher: fetcher }),
+    );
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"chinook-api/internal/graph"
+	"chinook-api/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func graphqlRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	// the queries below are rejected or answered before any resolver needs
+	// a repository
+	h := &GraphQLHandler{Limits: graph.Limits{MaxDepth: 10, MaxComplexity: 20000}}
+	if err := h.BuildSchema(); err != nil {
+		t.Fatalf("BuildSchema: %v", err)
+	}
+	r := gin.New()
+	r.POST("/graphql", h.Query)
+	return r
+}
+
+func postGraphQL(t *testing.T, r *gin.Engine, query string) (int, models.GraphQLResponse) {
+	t.Helper()
+	body, _ := json.Marshal(models.GraphQLRequest{Query: query})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+	var resp models.GraphQLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+func TestGraphQLRejectsFragmentCycles(t *testing.T) {
+	r := graphqlRouter(t)
+
+	var chain strings.Builder
+	chain.WriteString("query { ...F0 }\n")
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&chain, "fragment F%d on Query { ...F%d }\n", i, i+1)
+	}
+	chain.WriteString("fragment F30 on Query { __typename }\n")
+
+	tests := map[string]string{
+		"self":         "query { ...F } fragment F on Query { ...F }",
+		"two":          "query { ...F } fragment F on Query { ...G } fragment G on Query { ...F }",
+		"nested field": "query { ...F } fragment F on Query { artists { data { ...A } } } fragment A on Artist { albums { artist { ...A } } }",
+		"unused":       "query { __typename } fragment F on Query { ...G } fragment G on Query { ...F }",
+		"too deep":     chain.String(),
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, resp := postGraphQL(t, r, query)
+			if status != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", status)
+			}
+			if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "invalid_query" {
+				t.Fatalf("errors = %+v, want one invalid_query", resp.Errors)
+			}
+		})
+	}
+}
+
+func TestGraphQLAllowsRepeatedFragments(t *testing.T) {
+	r := graphqlRouter(t)
+	status, resp := postGraphQL(t, r, "query { ...F ...G } fragment F on Query { ...T } fragment G on Query { ...T } fragment T on Query { __typename }")
+	if status != http.StatusOK || len(resp.Errors) > 0 {
+		t.Fatalf("status = %d, errors = %+v, want 200 without errors", status, resp.Errors)
+	}
+}
@@ -0,0 +1,856 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/graph"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+	"chinook-api/internal/utils"
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// graphqlLoaders batch the lookups of one GraphQL request, so that e.g. the
+// artists of a page of albums are fetched with one query rather than one
+// per album.
+type graphqlLoaders struct {
+	batch *repositories.Batcher
+
+	artists            *graph.Loader[int, *models.Artist]
+	albums             *graph.Loader[int, *models.Album]
+	albumsByArtist     *graph.Loader[int, []models.Album]
+	tracks             *graph.Loader[int, *models.Track]
+	tracksByAlbum      *graph.Loader[int, []models.Track]
+	genres             *graph.Loader[int, *models.Genre]
+	mediaTypes         *graph.Loader[int, *models.MediaType]
+	customers          *graph.Loader[int, *models.Customer]
+	customersByRep     *graph.Loader[int, []models.Customer]
+	employees          *graph.Loader[int, *models.Employee]
+	invoices           *graph.Loader[int, *models.Invoice]
+	invoicesByCustomer *graph.Loader[int, []models.Invoice]
+	linesByInvoice     *graph.Loader[int, []models.InvoiceLine]
+}
+
+func newGraphQLLoaders(batch *repositories.Batcher) *graphqlLoaders {
+	return &graphqlLoaders{
+		batch:              batch,
+		artists:            graph.NewLoader(batch.Artists),
+		albums:             graph.NewLoader(batch.Albums),
+		albumsByArtist:     graph.NewLoader(batch.AlbumsByArtist),
+		tracks:             graph.NewLoader(batch.Tracks),
+		tracksByAlbum:      graph.NewLoader(batch.TracksByAlbum),
+		genres:             graph.NewLoader(batch.Genres),
+		mediaTypes:         graph.NewLoader(batch.MediaTypes),
+		customers:          graph.NewLoader(batch.Customers),
+		customersByRep:     graph.NewLoader(batch.CustomersBySupportRep),
+		employees:          graph.NewLoader(batch.Employees),
+		invoices:           graph.NewLoader(batch.Invoices),
+		invoicesByCustomer: graph.NewLoader(batch.InvoicesByCustomer),
+		linesByInvoice:     graph.NewLoader(batch.InvoiceLinesByInvoice),
+	}
+}
+
+// reset drops everything loaded so far. Mutations call it after a write so
+// that the fields selected on their result see the change.
+func (l *graphqlLoaders) reset() {
+	*l = *newGraphQLLoaders(l.batch)
+}
+
+type graphqlLoadersKey struct{}
+
+func loadersFrom(ctx context.Context) *graphqlLoaders {
+	return ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+}
+
+// loadOne loads the row with the given id as a thunk yielding it, or null
+// when it does not exist.
+func loadOne[V any](ctx context.Context, l *graph.Loader[int, *V], id int) func() (any, error) {
+	load := l.Load(ctx, id)
+	return func() (any, error) {
+		v, err := load()
+		if err != nil || v == nil {
+			return nil, err
+		}
+		return *v, nil
+	}
+}
+
+// loadOptional is loadOne for nullable references.
+func loadOptional[V any](ctx context.Context, l *graph.Loader[int, *V], id *int) (any, error) {
+	if id == nil {
+		return nil, nil
+	}
+	return loadOne(ctx, l, *id), nil
+}
+
+// loadMany loads the rows under the given parent id as a thunk.
+func loadMany[V any](ctx context.Context, l *graph.Loader[int, []V], id int) func() (any, error) {
+	load := l.Load(ctx, id)
+	return func() (any, error) {
+		v, err := load()
+		if v == nil {
+			v = []V{}
+		}
+		return v, err
+	}
+}
+
+// resolve adapts a resolver over the parent object, of type T, of a field.
+func resolve[T any](fn func(ctx context.Context, src T) (any, error)) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return fn(p.Context, p.Source.(T))
+	}
+}
+
+// value resolves a field to fn of its parent object.
+func value[T any](fn func(src T) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return fn(p.Source.(T)), nil
+	}
+}
+
+// graphqlPage is one page of a paginated list, in the same shape as the
+// paginated REST responses.
+type graphqlPage struct {
+	Data    any
+	Total   int
+	Limit   int
+	Offset  int
+	HasMore bool
+}
+
+func newGraphQLPage[T any](data []T, total, limit, offset int) graphqlPage {
+	if data == nil {
+		data = []T{}
+	}
+	return graphqlPage{Data: data, Total: total, Limit: limit, Offset: offset, HasMore: offset+len(data) < total}
+}
+
+func pageType(name string, item *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name:        name,
+		Description: "One page of " + item.Name() + " items.",
+		Fields: graphql.Fields{
+			"data":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(item)))},
+			"total":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"limit":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"offset":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"hasMore": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		},
+	})
+}
+
+// pageArgs are the arguments of paginated fields. The limit defaults to
+// PageLimits.Default, which the complexity limit relies on.
+func pageArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: PageLimits.Default, Description: "Page size, at most the configured maximum page size"},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+// pagination reads the page arguments the way parsePagination reads the
+// query string.
+func pagination(p graphql.ResolveParams) (limit, offset int) {
+	limit, _ = p.Args["limit"].(int)
+	if limit <= 0 {
+		limit = PageLimits.Default
+	}
+	limit = min(limit, PageLimits.Max)
+	offset, _ = p.Args["offset"].(int)
+	return limit, max(offset, 0)
+}
+
+// graphqlListQuery builds the list query for resource from the sort argument,
+// which takes the same comma-separated fields as ?sort= on the REST list.
+func graphqlListQuery(p graphql.ResolveParams, resource string) (repositories.ListQuery, error) {
+	values := url.Values{}
+	if sort, ok := p.Args["sort"].(string); ok {
+		values.Set("sort", sort)
+	}
+	q, err := repositories.ParseListQuery(resource, values)
+	q.Limit, q.Offset = pagination(p)
+	return q, err
+}
+
+// unpaged returns q without its page, for counting every match: the Count
+// methods count within the page.
+func unpaged(q repositories.ListQuery) repositories.ListQuery {
+	q.Limit, q.Offset = 0, 0
+	return q
+}
+
+var sortArg = graphql.FieldConfigArgument{
+	"sort": &graphql.ArgumentConfig{Type: graphql.String, Description: "Comma-separated snake_case fields, each prefixed with - for descending order, as in ?sort= on the REST list"},
+}
+
+var idArg = graphql.FieldConfigArgument{
+	"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+}
+
+// inputError reports the fields of a validation error the way they are
+// named in GraphQL, e.g. media_type_id as input.mediaTypeId.
+func inputError(err error) error {
+	e, ok := apperr.As(err)
+	if !ok || len(e.Fields) == 0 {
+		return err
+	}
+	fields := make(map[string]string, len(e.Fields))
+	for name, msg := range e.Fields {
+		fields["input."+camelCase(name)] = msg
+	}
+	return apperr.Validation(fields)
+}
+
+// camelCase converts a JSON field name, snake_case or PascalCase, to the
+// camelCase of its GraphQL field.
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		if i == 0 {
+			parts[i] = strings.ToLower(part[:1]) + part[1:]
+		} else {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// orNull returns nil for a not found error, so that lookups of a missing
+// id resolve to null as they do through the loaders.
+func orNull(v any, err error) (any, error) {
+	if apperr.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// buildSchema builds the GraphQL schema over the handler's repositories. Fields
+// of the object types resolve by name onto the model structs unless they
+// are given a resolver.
+func (h *GraphQLHandler) buildSchema() (graphql.Schema, error) {
+	nonNullInt := graphql.NewNonNull(graphql.Int)
+	nonNullString := graphql.NewNonNull(graphql.String)
+	nonNullFloat := graphql.NewNonNull(graphql.Float)
+
+	genreType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Genre",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: nonNullInt, Resolve: value(func(g models.Genre) any { return g.GenreId })},
+			"name": &graphql.Field{Type: nonNullString},
+		},
+	})
+	mediaTypeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MediaType",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: nonNullInt, Resolve: value(func(m models.MediaType) any { return m.MediaTypeId })},
+			"name": &graphql.Field{Type: graphql.String},
+		},
+	})
+	playlistType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Playlist",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: nonNullInt, Resolve: value(func(p models.Playlist) any { return p.PlaylistId })},
+			"name": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	var artistType, albumType, trackType, customerType, employeeType, invoiceType, invoiceLineType *graphql.Object
+	artistType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Artist",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":   &graphql.Field{Type: nonNullInt},
+				"name": &graphql.Field{Type: nonNullString},
+				"albums": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(albumType))),
+					Resolve: resolve(func(ctx context.Context, a models.Artist) (any, error) {
+						return loadMany(ctx, loadersFrom(ctx).albumsByArtist, a.ID), nil
+					}),
+				},
+			}
+		}),
+	})
+	albumType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Album",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":    &graphql.Field{Type: nonNullInt},
+				"title": &graphql.Field{Type: nonNullString},
+				"artist": &graphql.Field{
+					Type: artistType,
+					Resolve: resolve(func(ctx context.Context, a models.Album) (any, error) {
+						return loadOne(ctx, loadersFrom(ctx).artists, a.ArtistID), nil
+					}),
+				},
+				"tracks": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(trackType))),
+					Resolve: resolve(func(ctx context.Context, a models.Album) (any, error) {
+						return loadMany(ctx, loadersFrom(ctx).tracksByAlbum, a.ID), nil
+					}),
+				},
+			}
+		}),
+	})
+	trackType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Track",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":           &graphql.Field{Type: nonNullInt, Resolve: value(func(t models.Track) any { return t.TrackId })},
+				"name":         &graphql.Field{Type: nonNullString},
+				"composer":     &graphql.Field{Type: graphql.String},
+				"milliseconds": &graphql.Field{Type: nonNullInt},
+				"bytes":        &graphql.Field{Type: graphql.Int},
+				"unitPrice":    &graphql.Field{Type: nonNullFloat},
+				"album": &graphql.Field{
+					Type: albumType,
+					Resolve: resolve(func(ctx context.Context, t models.Track) (any, error) {
+						return loadOptional(ctx, loadersFrom(ctx).albums, t.AlbumId)
+					}),
+				},
+				"genre": &graphql.Field{
+					Type: genreType,
+					Resolve: resolve(func(ctx context.Context, t models.Track) (any, error) {
+						return loadOptional(ctx, loadersFrom(ctx).genres, t.GenreId)
+					}),
+				},
+				"mediaType": &graphql.Field{
+					Type: mediaTypeType,
+					Resolve: resolve(func(ctx context.Context, t models.Track) (any, error) {
+						return loadOne(ctx, loadersFrom(ctx).mediaTypes, t.MediaTypeId), nil
+					}),
+				},
+			}
+		}),
+	})
+	employeeType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Employee",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":         &graphql.Field{Type: nonNullInt, Resolve: value(func(e models.Employee) any { return e.EmployeeId })},
+				"firstName":  &graphql.Field{Type: nonNullString},
+				"lastName":   &graphql.Field{Type: nonNullString},
+				"title":      &graphql.Field{Type: graphql.String},
+				"birthDate":  &graphql.Field{Type: graphql.String, Description: "YYYY-MM-DD", Resolve: value(func(e models.Employee) any { return dateString(e.BirthDate) })},
+				"hireDate":   &graphql.Field{Type: graphql.String, Description: "YYYY-MM-DD", Resolve: value(func(e models.Employee) any { return dateString(e.HireDate) })},
+				"address":    &graphql.Field{Type: graphql.String},
+				"city":       &graphql.Field{Type: graphql.String},
+				"state":      &graphql.Field{Type: graphql.String},
+				"country":    &graphql.Field{Type: graphql.String},
+				"postalCode": &graphql.Field{Type: graphql.String},
+				"phone":      &graphql.Field{Type: graphql.String},
+				"fax":        &graphql.Field{Type: graphql.String},
+				"email":      &graphql.Field{Type: graphql.String},
+				"reportsTo": &graphql.Field{
+					Type: employeeType,
+					Resolve: resolve(func(ctx context.Context, e models.Employee) (any, error) {
+						return loadOptional(ctx, loadersFrom(ctx).employees, e.ReportsTo)
+					}),
+				},
+				"customers": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(customerType))),
+					Description: "Customers the employee is the support rep of",
+					Resolve: resolve(func(ctx context.Context, e models.Employee) (any, error) {
+						return loadMany(ctx, loadersFrom(ctx).customersByRep, e.EmployeeId), nil
+					}),
+				},
+			}
+		}),
+	})
+	customerType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Customer",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":         &graphql.Field{Type: nonNullInt, Resolve: value(func(c models.Customer) any { return c.CustomerId })},
+				"firstName":  &graphql.Field{Type: nonNullString},
+				"lastName":   &graphql.Field{Type: nonNullString},
+				"company":    &graphql.Field{Type: graphql.String},
+				"address":    &graphql.Field{Type: graphql.String},
+				"city":       &graphql.Field{Type: graphql.String},
+				"state":      &graphql.Field{Type: graphql.String},
+				"country":    &graphql.Field{Type: graphql.String},
+				"postalCode": &graphql.Field{Type: graphql.String},
+				"phone":      &graphql.Field{Type: graphql.String},
+				"fax":        &graphql.Field{Type: graphql.String},
+				"email":      &graphql.Field{Type: nonNullString},
+				"supportRep": &graphql.Field{
+					Type: employeeType,
+					Resolve: resolve(func(ctx context.Context, c models.Customer) (any, error) {
+						return loadOptional(ctx, loadersFrom(ctx).employees, c.SupportRepId)
+					}),
+				},
+				"invoices": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(invoiceType))),
+					Resolve: resolve(func(ctx context.Context, c models.Customer) (any, error) {
+						return loadMany(ctx, loadersFrom(ctx).invoicesByCustomer, c.CustomerId), nil
+					}),
+				},
+			}
+		}),
+	})
+	invoiceType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Invoice",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":                &graphql.Field{Type: nonNullInt, Resolve: value(func(inv models.Invoice) any { return inv.InvoiceId })},
+				"invoiceDate":       &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+				"billingAddress":    &graphql.Field{Type: graphql.String},
+				"billingCity":       &graphql.Field{Type: graphql.String},
+				"billingState":      &graphql.Field{Type: graphql.String},
+				"billingCountry":    &graphql.Field{Type: graphql.String},
+				"billingPostalCode": &graphql.Field{Type: graphql.String},
+				"total":             &graphql.Field{Type: nonNullFloat},
+				"customer": &graphql.Field{
+					Type: customerType,
+					Resolve: resolve(func(ctx context.Context, inv models.Invoice) (any, error) {
+						return loadOne(ctx, loadersFrom(ctx).customers, inv.CustomerId), nil
+					}),
+				},
+				"lines": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(invoiceLineType))),
+					Resolve: resolve(func(ctx context.Context, inv models.Invoice) (any, error) {
+						return loadMany(ctx, loadersFrom(ctx).linesByInvoice, inv.InvoiceId), nil
+					}),
+				},
+			}
+		}),
+	})
+	invoiceLineType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "InvoiceLine",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        &graphql.Field{Type: nonNullInt, Resolve: value(func(l models.InvoiceLine) any { return l.InvoiceLineId })},
+				"unitPrice": &graphql.Field{Type: nonNullFloat},
+				"quantity":  &graphql.Field{Type: nonNullInt},
+				"track": &graphql.Field{
+					Type: trackType,
+					Resolve: resolve(func(ctx context.Context, l models.InvoiceLine) (any, error) {
+						return loadOne(ctx, loadersFrom(ctx).tracks, l.TrackId), nil
+					}),
+				},
+				"invoice": &graphql.Field{
+					Type: invoiceType,
+					Resolve: resolve(func(ctx context.Context, l models.InvoiceLine) (any, error) {
+						return loadOne(ctx, loadersFrom(ctx).invoices, l.InvoiceId), nil
+					}),
+				},
+			}
+		}),
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"artist": &graphql.Field{
+				Type: artistType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadOne(p.Context, loadersFrom(p.Context).artists, p.Args["id"].(int)), nil
+				},
+			},
+			"artists": &graphql.Field{
+				Type: graphql.NewNonNull(pageType("ArtistPage", artistType)),
+				Args: pageArgs(nil),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					limit, offset := pagination(p)
+					artists, total, err := h.Artists.GetArtistsPaginated(p.Context, limit, offset)
+					return newGraphQLPage(artists, total, limit, offset), err
+				},
+			},
+			"album": &graphql.Field{
+				Type: albumType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadOne(p.Context, loadersFrom(p.Context).albums, p.Args["id"].(int)), nil
+				},
+			},
+			"albums": &graphql.Field{
+				Type: graphql.NewNonNull(pageType("AlbumPage", albumType)),
+				Args: pageArgs(nil),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					limit, offset := pagination(p)
+					albums, err := h.Albums.GetAllAlbums(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					total := len(albums)
+					albums = albums[min(offset, total):min(offset+limit, total)]
+					return newGraphQLPage(albums, total, limit, offset), nil
+				},
+			},
+			"track": &graphql.Field{
+				Type: trackType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadOne(p.Context, loadersFrom(p.Context).tracks, p.Args["id"].(int)), nil
+				},
+			},
+			"tracks": &graphql.Field{
+				Type: graphql.NewNonNull(pageType("TrackPage", trackType)),
+				Args: pageArgs(sortArg),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					q, err := graphqlListQuery(p, "track")
+					if err != nil {
+						return nil, err
+					}
+					tracks, err := h.Tracks.GetTracks(p.Context, q)
+					if err != nil {
+						return nil, err
+					}
+					total, err := h.Tracks.CountTracks(p.Context, unpaged(q))
+					return newGraphQLPage(tracks, total, q.Limit, q.Offset), err
+				},
+			},
+			"genre": &graphql.Field{
+				Type: genreType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadOne(p.Context, loadersFrom(p.Context).genres, p.Args["id"].(int)), nil
+				},
+			},
+			"genres": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(genreType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return h.Genres.GetAllGenres(p.Context)
+				},
+			},
+			"mediaType": &graphql.Field{
+				Type: mediaTypeType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadOne(p.Context, loadersFrom(p.Context).mediaTypes, p.Args["id"].(int)), nil
+				},
+			},
+			"mediaTypes": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(mediaTypeType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return h.MediaTypes.GetAllMediaTypes(p.Context)
+				},
+			},
+			"playlist": &graphql.Field{
+				Type: playlistType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return orNull(h.Playlists.GetPlaylistByID(p.Context, p.Args["id"].(int)))
+				},
+			},
+			"playlists": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(playlistType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return h.Playlists.GetAllPlaylists(p.Context)
+				},
+			},
+			"customer": &graphql.Field{
+				Type: customerType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadOne(p.Context, loadersFrom(p.Context).customers, p.Args["id"].(int)), nil
+				},
+			},
+			"customers": &graphql.Field{
+				Type: graphql.NewNonNull(pageType("CustomerPage", customerType)),
+				Args: pageArgs(sortArg),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					q, err := graphqlListQuery(p, "customer")
+					if err != nil {
+						return nil, err
+					}
+					customers, err := h.Customers.GetAllCustomers(p.Context, q)
+					if err != nil {
+						return nil, err
+					}
+					total, err := h.Customers.CountCustomers(p.Context, unpaged(q))
+					return newGraphQLPage(customers, total, q.Limit, q.Offset), err
+				},
+			},
+			"invoice": &graphql.Field{
+				Type: invoiceType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadOne(p.Context, loadersFrom(p.Context).invoices, p.Args["id"].(int)), nil
+				},
+			},
+			"invoices": &graphql.Field{
+				Type: graphql.NewNonNull(pageType("InvoicePage", invoiceType)),
+				Args: pageArgs(sortArg),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					q, err := graphqlListQuery(p, "invoice")
+					if err != nil {
+						return nil, err
+					}
+					invoices, err := h.Invoices.GetAllInvoices(p.Context, q)
+					if err != nil {
+						return nil, err
+					}
+					total, err := h.Invoices.CountInvoices(p.Context, unpaged(q))
+					return newGraphQLPage(invoices, total, q.Limit, q.Offset), err
+				},
+			},
+			"employee": &graphql.Field{
+				Type: employeeType,
+				Args: idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loadOne(p.Context, loadersFrom(p.Context).employees, p.Args["id"].(int)), nil
+				},
+			},
+			"employees": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(employeeType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return h.Employees.GetAllEmployees(p.Context)
+				},
+			},
+		},
+	})
+
+	artistInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ArtistInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name": &graphql.InputObjectFieldConfig{Type: nonNullString},
+		},
+	})
+	albumInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "AlbumInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":    &graphql.InputObjectFieldConfig{Type: nonNullString},
+			"artistId": &graphql.InputObjectFieldConfig{Type: nonNullInt},
+		},
+	})
+	trackPatch := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "TrackPatch",
+		Description: "Fields to change on a track; fields left out keep their current values.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"albumId":      &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"mediaTypeId":  &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"genreId":      &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"composer":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"milliseconds": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"bytes":        &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"unitPrice":    &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createArtist": &graphql.Field{
+				Type: graphql.NewNonNull(artistType),
+				Args: graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(artistInput)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					in := artistInputArg(p)
+					if err := validateStruct(&in); err != nil {
+						return nil, inputError(err)
+					}
+					artist := in.Artist(0)
+					id, err := h.Artists.CreateArtist(p.Context, artist)
+					if err != nil {
+						return nil, err
+					}
+					artist.ID = int(id)
+					loadersFrom(p.Context).reset()
+					return artist, nil
+				},
+			},
+			"updateArtist": &graphql.Field{
+				Type: graphql.NewNonNull(artistType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: nonNullInt},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(artistInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Args["id"].(int)
+					defer lockResource("artist", id)()
+					if _, err := h.Artists.GetArtistByID(p.Context, id); err != nil {
+						return nil, err
+					}
+					in := artistInputArg(p)
+					if err := validateStruct(&in); err != nil {
+						return nil, inputError(err)
+					}
+					artist := in.Artist(id)
+					if err := h.Artists.UpdateArtist(p.Context, artist); err != nil {
+						return nil, err
+					}
+					loadersFrom(p.Context).reset()
+					return artist, nil
+				},
+			},
+			"deleteArtist": &graphql.Field{
+				Type:        nonNullInt,
+				Description: "Deletes an artist and returns its id.",
+				Args:        idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Args["id"].(int)
+					defer lockResource("artist", id)()
+					if _, err := h.Artists.GetArtistByID(p.Context, id); err != nil {
+						return nil, err
+					}
+					if err := h.Artists.DeleteArtist(p.Context, id); err != nil {
+						return nil, err
+					}
+					loadersFrom(p.Context).reset()
+					return id, nil
+				},
+			},
+			"createAlbum": &graphql.Field{
+				Type: graphql.NewNonNull(albumType),
+				Args: graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(albumInput)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					in := albumInputArg(p)
+					if err := validateStruct(&in); err != nil {
+						return nil, inputError(err)
+					}
+					if err := artistExists(p.Context, h.Artists, in.ArtistID); err != nil {
+						return nil, inputError(err)
+					}
+					album := in.Album(0)
+					id, err := h.Albums.CreateAlbum(p.Context, album)
+					if err != nil {
+						return nil, err
+					}
+					album.ID = int(id)
+					loadersFrom(p.Context).reset()
+					return album, nil
+				},
+			},
+			"updateAlbum": &graphql.Field{
+				Type: graphql.NewNonNull(albumType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: nonNullInt},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(albumInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Args["id"].(int)
+					defer lockResource("album", id)()
+					if _, err := h.Albums.GetAlbumByID(p.Context, id); err != nil {
+						return nil, err
+					}
+					in := albumInputArg(p)
+					if err := validateStruct(&in); err != nil {
+						return nil, inputError(err)
+					}
+					if err := artistExists(p.Context, h.Artists, in.ArtistID); err != nil {
+						return nil, inputError(err)
+					}
+					album := in.Album(id)
+					if err := h.Albums.UpdateAlbum(p.Context, album); err != nil {
+						return nil, err
+					}
+					loadersFrom(p.Context).reset()
+					return album, nil
+				},
+			},
+			"deleteAlbum": &graphql.Field{
+				Type:        nonNullInt,
+				Description: "Deletes an album and returns its id.",
+				Args:        idArg,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Args["id"].(int)
+					defer lockResource("album", id)()
+					if _, err := h.Albums.GetAlbumByID(p.Context, id); err != nil {
+						return nil, err
+					}
+					if err := h.Albums.DeleteAlbum(p.Context, id); err != nil {
+						return nil, err
+					}
+					loadersFrom(p.Context).reset()
+					return id, nil
+				},
+			},
+			"updateTrack": &graphql.Field{
+				Type: graphql.NewNonNull(trackType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: nonNullInt},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(trackPatch)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id := p.Args["id"].(int)
+					defer lockResource("track", id)()
+					track, err := h.Tracks.GetTrackByID(p.Context, id)
+					if err != nil {
+						return nil, err
+					}
+					in := trackPatchArg(p, track.Input())
+					if err := validateStruct(&in); err != nil {
+						return nil, inputError(err)
+					}
+					if err := trackRefs(p.Context, h.Albums, h.Genres, h.MediaTypes, in); err != nil {
+						return nil, inputError(err)
+					}
+					track = in.Track(id)
+					if err := h.Tracks.UpdateTrack(p.Context, track); err != nil {
+						return nil, err
+					}
+					loadersFrom(p.Context).reset()
+					return track, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func artistInputArg(p graphql.ResolveParams) models.ArtistInput {
+	in := p.Args["input"].(map[string]any)
+	name, _ := in["name"].(string)
+	return models.ArtistInput{Name: name}
+}
+
+func albumInputArg(p graphql.ResolveParams) models.AlbumInput {
+	in := p.Args["input"].(map[string]any)
+	title, _ := in["title"].(string)
+	artistID, _ := in["artistId"].(int)
+	return models.AlbumInput{Title: title, ArtistID: artistID}
+}
+
+// trackPatchArg applies the TrackPatch argument to the current track body.
+func trackPatchArg(p graphql.ResolveParams, in models.TrackInput) models.TrackInput {
+	patch := p.Args["input"].(map[string]any)
+	if v, ok := patch["name"].(string); ok {
+		in.Name = v
+	}
+	if v, ok := patch["albumId"].(int); ok {
+		in.AlbumId = &v
+	}
+	if v, ok := patch["mediaTypeId"].(int); ok {
+		in.MediaTypeId = v
+	}
+	if v, ok := patch["genreId"].(int); ok {
+		in.GenreId = &v
+	}
+	if v, ok := patch["composer"].(string); ok {
+		in.Composer = &v
+	}
+	if v, ok := patch["milliseconds"].(int); ok {
+		in.Milliseconds = v
+	}
+	if v, ok := patch["bytes"].(int); ok {
+		in.Bytes = &v
+	}
+	if v, ok := patch["unitPrice"].(float64); ok {
+		in.UnitPrice = v
+	}
+	return in
+}
+
+func dateString(d *utils.DateOnly) any {
+	if d == nil {
+		return nil
+	}
+	return d.Format(time.DateOnly)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/repositories"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+var errInvalidIdempotencyKey = apperr.BadRequest("invalid_idempotency_key", "Idempotency-Key must be 1 to 255 printable ASCII characters")
+
+// Idempotency makes POST requests sent with an Idempotency-Key header safe
+// to retry. The first request with a key is handled normally and its
+// response is stored for ttl; a retry with the same key and the same method,
+// URL and body gets the stored response back, marked with an
+// Idempotent-Replayed header, without running the handler again. Keys are
+// scoped to the authenticated user, so it must run after authentication.
+//
+// Reusing a key for a different request is a 422, and a retry that arrives
+// while the first request is still running is a 409 with Retry-After.
+// Server errors are not stored, so the client can retry them with the same
+// key.
+func Idempotency(repo *repositories.IdempotencyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			abortWithError(c, errInvalidIdempotencyKey)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				err = errBodyTooLarge(tooLarge)
+			}
+			abortWithError(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var scope string
+		if username, ok := c.Get("username"); ok {
+			scope, _ = username.(string)
+		}
+		sum := sha256.New()
+		sum.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+		sum.Write(body)
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+		// the outcome must be recorded even when the client has hung up,
+		// since that is exactly when it will retry
+		ctx := context.WithoutCancel(c.Request.Context())
+		stored, err := repo.Begin(ctx, scope, key, fingerprint, ttl)
+		if err != nil {
+			if errors.Is(err, repositories.ErrIdempotencyInFlight) {
+				c.Header("Retry-After", "1")
+			}
+			abortWithError(c, err)
+			return
+		}
+		if stored != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.Status, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		completed := false
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if !completed {
+				if err := repo.Release(ctx, scope, key); err != nil {
+					log.Error().Err(err).Str("key", key).Msg("idempotency key left claimed until it expires")
+				}
+			}
+		}()
+
+		c.Next()
+
+		if !w.Written() || w.Status() >= http.StatusInternalServerError {
+			return
+		}
+		completed = repo.Complete(ctx, scope, key, repositories.StoredResponse{
+			Status:      w.Status(),
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+		}) == nil
+	}
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > 255 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// recordingWriter keeps a copy of the response body as it is written.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
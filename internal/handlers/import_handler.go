@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/catalog"
+	"chinook-api/internal/models"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportHandler struct {
+	Importer *catalog.Importer
+}
+
+var (
+	errImportMediaType = apperr.New(apperr.KindUnsupportedMedia, "unsupported_import_type", "import bodies must be text/csv or application/json")
+	errImportFailed    = apperr.New(apperr.KindUnprocessable, "import_failed", "some rows are invalid or conflict, so nothing was imported; see results")
+)
+
+// @Summary Import tracks into the catalog
+// @Description Imports a label delivery with one track per row, as CSV with a header or as a JSON array of objects. The columns are artist, album, track, genre, media_type and price (or unit_price), and optionally composer, milliseconds and bytes.
+// @Description Artists, albums, genres and media types are matched by name ignoring case, and created when none matches. A track that already exists on its album is a conflict, handled by on_conflict: skip leaves it, update overwrites it with the row, and fail (the default) reports the row as an error.
+// @Description The import runs in one transaction and is applied only when every row succeeded. With dry_run=true nothing is written and the report shows what would change.
+// @Tags admin
+// @Accept text/csv,json
+// @Produce json
+// @Security BearerAuth
+// @Param on_conflict query string false "What to do with tracks that already exist" Enums(skip, update, fail) default(fail)
+// @Param dry_run query bool false "Report what the import would do without writing anything"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Param file body string true "CSV file or JSON array of rows"
+// @Success 200 {object} models.ImportReport
+// @Failure 400 {object} models.Problem "The file cannot be read, e.g. a missing column"
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 413 {object} models.Problem
+// @Failure 415 {object} models.Problem
+// @Failure 422 {object} models.ImportFailure "Some rows failed, so nothing was imported"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/admin/imports [post]
+func (h *ImportHandler) Create(c *gin.Context) {
+	opts := catalog.Options{OnConflict: c.DefaultQuery("on_conflict", catalog.Fail)}
+	if raw := c.Query("dry_run"); raw != "" {
+		var err error
+		if opts.DryRun, err = strconv.ParseBool(raw); err != nil {
+			abortWithError(c, apperr.Field("dry_run", "must be true or false"))
+			return
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		opts.Format = catalog.CSV
+	case "application/json":
+		opts.Format = catalog.JSON
+	default:
+		abortWithError(c, errImportMediaType)
+		return
+	}
+
+	report, err := h.Importer.Import(c.Request.Context(), c.Request.Body, opts)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = errBodyTooLarge(tooLarge)
+		}
+		abortWithError(c, err)
+		return
+	}
+	if report.Failed > 0 {
+		failure := models.ImportFailure{Problem: newProblem(c, errImportFailed), ImportReport: report}
+		c.Error(errImportFailed)
+		c.Abort()
+		renderProblem(c, failure.Status, failure)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"chinook-api/internal/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseInclude reads the include query parameter for resource. On an
+// invalid value it answers 400 itself and returns false.
+func parseInclude(c *gin.Context, resource string) (repositories.IncludeTree, bool) {
+	tree, err := repositories.ParseInclude(resource, c.Query("include"))
+	if err != nil {
+		abortWithError(c, err)
+		return nil, false
+	}
+	return tree, true
+}
+
+// includeFailed answers 500 when embedding related rows failed.
+func includeFailed(c *gin.Context, err error) {
+	abortWithError(c, err)
+}
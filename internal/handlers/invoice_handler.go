@@ -1,51 +1,122 @@
 package handlers
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type InvoiceHandler struct {
-	Repo *repositories.InvoiceRepository
+	Repo     *repositories.InvoiceRepository
+	Includes *repositories.Includer
 }
 
 // @Summary Get all invoices
-// @Description Returns a list of all invoices
+// @Description Returns all invoices, or a page with limit and offset, filtered by equality on customer_id, billing_city, billing_state, billing_country or billing_postal_code, e.g. ?billing_country=USA. As CSV, NDJSON or XLSX the rows are streamed as they are read.
 // @Tags invoices
 // @Produce json
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 // @Security BearerAuth
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Param format query string false "Export format instead of JSON; also chosen by an Accept header of text/csv, application/x-ndjson or the XLSX media type" Enums(json, csv, ndjson, xlsx)
+// @Param sort query string false "Comma-separated fields to sort by, each optionally prefixed with - for descending, e.g. -total,invoice_date"
+// @Param include query string false "Comma-separated relations to embed: customer, lines, lines.track, lines.track.album"
 // @Success 200 {array} models.Invoice
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /api/v1/invoices [get]
 func (h *InvoiceHandler) GetAll(c *gin.Context) {
-	invoices, err := h.Repo.GetAllInvoices(c.Request.Context())
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	q, ok := parseListQuery(c, "invoice")
+	if !ok {
+		return
+	}
+	if format != "" {
+		streamExport(c, format, "invoices", func(fn func(models.Invoice) error) error {
+			return h.Repo.EachInvoice(c.Request.Context(), q, fn)
+		})
+		return
+	}
+	include, ok := parseInclude(c, "invoice")
+	if !ok {
+		return
+	}
+	invoices, err := h.Repo.GetAllInvoices(c.Request.Context(), q)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
+	}
+	if err := h.Includes.Invoices(c.Request.Context(), invoices, include); err != nil {
+		includeFailed(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, invoices)
 }
 
 // @Summary Get invoice by ID
-// @Description Returns a single invoice by ID
+// @Description Returns a single invoice by ID. With detail=full it returns a models.InvoiceDetail instead: the customer's name and email, the billing address, every line joined with its track, album and artist and its subtotal, and whether the subtotals add up to the invoice total.
 // @Tags invoices
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Invoice ID"
-// @Success 200 {object} models.Invoice
-// @Failure 404 {object} models.ErrorResponse
+// @Param include query string false "Comma-separated relations to embed: customer, lines, lines.track, lines.track.album"
+// @Param detail query string false "Set to full for the joined view with customer, billing address, line details and a total check" Enums(full)
+// @Success 200 {object} models.Invoice "An InvoiceDetail when detail=full"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/invoices/{id} [get]
 func (h *InvoiceHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	invoice, err := h.Repo.GetInvoiceByID(c.Request.Context(), utils.ParseInt(id))
+	switch c.Query("detail") {
+	case "":
+	case "full":
+		h.getDetail(c)
+		return
+	default:
+		abortWithError(c, apperr.Field("detail", "must be \"full\""))
+		return
+	}
+	include, ok := parseInclude(c, "invoice")
+	if !ok {
+		return
+	}
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	invoice, err := h.Repo.GetInvoiceByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	invoices := []models.Invoice{invoice}
+	if err := h.Includes.Invoices(c.Request.Context(), invoices, include); err != nil {
+		includeFailed(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, invoices[0])
+}
+
+// getDetail serves GET /invoices/:id?detail=full.
+func (h *InvoiceHandler) getDetail(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	detail, err := h.Repo.GetInvoiceDetail(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, invoice)
+	c.JSON(http.StatusOK, detail)
 }
 
 // @Summary Get invoice lines by invoice ID
@@ -54,14 +125,27 @@ func (h *InvoiceHandler) GetOne(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Invoice ID"
+// @Param include query string false "Comma-separated relations to embed: track, track.album, track.album.artist, invoice, invoice.customer"
 // @Success 200 {array} models.InvoiceLine
-// @Failure 404 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/invoices/{id}/lines [get]
 func (h *InvoiceHandler) GetInvoiceLines(c *gin.Context) {
-    id := utils.ParseInt(c.Param("id"))
+    include, ok := parseInclude(c, "invoice_line")
+    if !ok {
+        return
+    }
+    id, ok := pathID(c)
+    if !ok {
+        return
+    }
     lines, err := h.Repo.GetInvoiceLinesByInvoiceID(c.Request.Context(), id)
     if err != nil {
-        c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+        abortWithError(c, err)
+        return
+    }
+    if err := h.Includes.InvoiceLines(c.Request.Context(), lines, include); err != nil {
+        includeFailed(c, err)
         return
     }
     c.JSON(http.StatusOK, lines)
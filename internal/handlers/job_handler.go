@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"bytes"
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/catalog"
+	"chinook-api/internal/jobs"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Job types accepted by POST /jobs.
+const (
+	jobExport = "export"
+	jobImport = "import"
+	jobReport = "report"
+)
+
+// JobHandler queues exports, imports and reports as background jobs and
+// serves their status and results. Jobs are visible to the user who
+// submitted them and to admins; without auth, everyone sees every job.
+type JobHandler struct {
+	Queue *jobs.Queue
+	Repo  *repositories.JobRepository
+	Users *repositories.UserRepository
+
+	Invoices  *repositories.InvoiceRepository
+	Customers *repositories.CustomerRepository
+	Tracks    *repositories.TrackRepository
+	Importer  *catalog.Importer
+	Reports   *ReportHandler
+	// AdminImports restricts import jobs to admins, like /admin/imports.
+	AdminImports bool
+}
+
+// RegisterRunners registers the runner of every job type with the queue.
+func (h *JobHandler) RegisterRunners() {
+	h.Queue.Register(jobExport, h.runExport)
+	h.Queue.Register(jobImport, h.runImport)
+	h.Queue.Register(jobReport, h.runReport)
+}
+
+// @Summary Queue a background job
+// @Description Queues an export, import or report to run in the background and returns at once. Poll GET /jobs/{id} for progress and fetch the file from GET /jobs/{id}/result when it has succeeded.
+// @Description params by type: export takes models.ExportJobParams, import models.ImportJobParams (admin role required in production) and report models.ReportJobParams. They are checked when the job is queued, so invalid params are a 400 here rather than a failed job.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Param job body models.JobRequest true "Job type and params"
+// @Success 202 {object} models.Job
+// @Header 202 {string} Location "URL of the job"
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 413 {object} models.Problem
+// @Failure 422 {object} models.Problem "The Idempotency-Key was used for a different request"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/jobs [post]
+func (h *JobHandler) Create(c *gin.Context) {
+	var req models.JobRequest
+	if !bindValid(c, &req) {
+		return
+	}
+	var params any
+	var err error
+	switch req.Type {
+	case jobExport:
+		params, err = h.prepareExport(req.Params)
+	case jobImport:
+		params, err = h.prepareImport(c, req.Params)
+	case jobReport:
+		params, err = h.prepareReport(c, req.Params)
+	default:
+		err = apperr.Field("type", "must be one of export import report")
+	}
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	job, err := h.Queue.Enqueue(c.Request.Context(), req.Type, username(c), params)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("Location", c.Request.URL.Path+"/"+strconv.Itoa(job.ID))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// @Summary Get a background job
+// @Description Returns the job's status, progress, attempts and the error of its last failed attempt
+// @Tags jobs
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.Job
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/jobs/{id} [get]
+func (h *JobHandler) Get(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Download the result of a background job
+// @Description Returns the file the job produced: the export in its format, or the import or report as JSON. A failed import also has its report as the result.
+// @Tags jobs
+// @Produce json,text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "The job has not produced a result"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/jobs/{id}/result [get]
+func (h *JobHandler) Result(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+	result, err := h.Repo.GetJobResult(c.Request.Context(), job.ID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrJobNoResult) {
+			err = repositories.ErrJobNoResult.Withf("job %d is %s and has no result", job.ID, job.Status)
+		}
+		abortWithError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+result.Filename+`"`)
+	c.Data(http.StatusOK, result.ContentType, result.Body)
+}
+
+// @Summary Cancel a background job
+// @Description Cancels a queued job at once. A running job is told to stop and becomes canceled shortly after, so the response may still show it running.
+// @Tags jobs
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Job ID"
+// @Success 202 {object} models.Job
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "The job has already finished"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/jobs/{id}/cancel [post]
+func (h *JobHandler) Cancel(c *gin.Context) {
+	job, ok := h.job(c)
+	if !ok {
+		return
+	}
+	job, err := h.Queue.Cancel(c.Request.Context(), job.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// job loads the job named by the id parameter. Jobs of other users are
+// reported as not found unless the caller is an admin.
+func (h *JobHandler) job(c *gin.Context) (models.Job, bool) {
+	id, ok := pathID(c)
+	if !ok {
+		return models.Job{}, false
+	}
+	job, err := h.Repo.GetJob(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return job, false
+	}
+	if name := username(c); name != "" && name != job.Owner {
+		user, err := h.Users.GetUserByUsername(c.Request.Context(), name)
+		if err != nil && !apperr.IsNotFound(err) {
+			abortWithError(c, err)
+			return job, false
+		}
+		if err != nil || user.Role != "admin" {
+			abortWithError(c, repositories.ErrJobNotFound)
+			return job, false
+		}
+	}
+	return job, true
+}
+
+// username is the authenticated caller, or "" without auth.
+func username(c *gin.Context) string {
+	if v, exists := c.Get("username"); exists {
+		name, _ := v.(string)
+		return name
+	}
+	return ""
+}
+
+// decodeJobParams decodes and validates the params of a job request into v,
+// reporting errors under params.
+func decodeJobParams(raw json.RawMessage, v any) error {
+	if err := decodeStrict(bytes.NewReader(raw), v); err != nil {
+		return prefixFields("params", err)
+	}
+	return prefixFields("params", validateStruct(v))
+}
+
+// prefixFields nests the field names of a validation error under prefix.
+func prefixFields(prefix string, err error) error {
+	e, ok := apperr.As(err)
+	if !ok || len(e.Fields) == 0 {
+		return err
+	}
+	fields := make(map[string]string, len(e.Fields))
+	for name, msg := range e.Fields {
+		fields[fmt.Sprintf("%s.%s", prefix, name)] = msg
+	}
+	return apperr.Validation(fields)
+}
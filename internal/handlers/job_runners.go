@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"bytes"
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/catalog"
+	"chinook-api/internal/export"
+	"chinook-api/internal/jobs"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listResources maps the resources of export jobs to the names ListQuery
+// knows them by.
+var listResources = map[string]string{
+	"invoices":  "invoice",
+	"customers": "customer",
+	"tracks":    "track",
+}
+
+// prepareExport checks the params of an export job.
+func (h *JobHandler) prepareExport(raw json.RawMessage) (any, error) {
+	var params models.ExportJobParams
+	if err := decodeJobParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if _, err := listQuery(listResources[params.Resource], queryValues(params.Query)); err != nil {
+		return nil, prefixFields("params.query", err)
+	}
+	return params, nil
+}
+
+func (h *JobHandler) runExport(ctx context.Context, raw json.RawMessage, p *jobs.Progress) (*repositories.JobResult, error) {
+	var params models.ExportJobParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, fmt.Errorf("decoding export params: %w", err)
+	}
+	q, err := listQuery(listResources[params.Resource], queryValues(params.Query))
+	if err != nil {
+		return nil, err
+	}
+	format, ok := export.ParseFormat(params.Format)
+	if !ok {
+		return nil, apperr.Field("format", "must be one of csv ndjson xlsx")
+	}
+
+	var buf bytes.Buffer
+	switch params.Resource {
+	case "invoices":
+		count := func() (int, error) { return h.Invoices.CountInvoices(ctx, q) }
+		err = writeExport(&buf, format, params.Resource, p, count, func(fn func(models.Invoice) error) error {
+			return h.Invoices.EachInvoice(ctx, q, fn)
+		})
+	case "customers":
+		count := func() (int, error) { return h.Customers.CountCustomers(ctx, q) }
+		err = writeExport(&buf, format, params.Resource, p, count, func(fn func(models.Customer) error) error {
+			return h.Customers.EachCustomer(ctx, q, fn)
+		})
+	case "tracks":
+		count := func() (int, error) { return h.Tracks.CountTracks(ctx, q) }
+		err = writeExport(&buf, format, params.Resource, p, count, func(fn func(models.Track) error) error {
+			return h.Tracks.EachTrack(ctx, q, fn)
+		})
+	default:
+		err = apperr.Field("resource", "must be one of invoices customers tracks")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &repositories.JobResult{
+		ContentType: format.ContentType(),
+		Filename:    params.Resource + "." + string(format),
+		Body:        buf.Bytes(),
+	}, nil
+}
+
+// writeExport writes the rows that each yields to w, counting them as
+// progress towards the total that count returns.
+func writeExport[T any](w *bytes.Buffer, format export.Format, sheet string, p *jobs.Progress, count func() (int, error), each func(fn func(T) error) error) error {
+	total, err := count()
+	if err != nil {
+		return err
+	}
+	p.SetTotal(total)
+	ew, err := export.New[T](format, w, sheet)
+	if err != nil {
+		return err
+	}
+	err = each(func(v T) error {
+		if err := ew.Write(v); err != nil {
+			return err
+		}
+		p.Add(1)
+		return nil
+	})
+	if err != nil {
+		ew.Discard()
+		return err
+	}
+	return ew.Close()
+}
+
+// prepareImport checks the params of an import job and that the caller may
+// import.
+func (h *JobHandler) prepareImport(c *gin.Context, raw json.RawMessage) (any, error) {
+	var params models.ImportJobParams
+	if err := decodeJobParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.OnConflict == "" {
+		params.OnConflict = catalog.Fail
+	}
+	if h.AdminImports {
+		name := username(c)
+		if name == "" {
+			return nil, errUnauthorized
+		}
+		user, err := h.Users.GetUserByUsername(c.Request.Context(), name)
+		if err != nil && !apperr.IsNotFound(err) {
+			return nil, err
+		}
+		if err != nil || user.Role != "admin" {
+			return nil, errForbidden
+		}
+	}
+	return params, nil
+}
+
+func (h *JobHandler) runImport(ctx context.Context, raw json.RawMessage, p *jobs.Progress) (*repositories.JobResult, error) {
+	var params models.ImportJobParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, fmt.Errorf("decoding import params: %w", err)
+	}
+	report, err := h.Importer.Import(ctx, strings.NewReader(params.Data), catalog.Options{
+		Format:     catalog.Format(params.Format),
+		OnConflict: params.OnConflict,
+		DryRun:     params.DryRun,
+		Progress:   func() { p.Add(1) },
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, err := jsonResult("import.json", report)
+	if err != nil {
+		return nil, err
+	}
+	if report.Failed > 0 {
+		// the report is kept so that the rows can be fixed
+		return result, errImportFailed
+	}
+	return result, nil
+}
+
+// reportJob is what a report job stores: the request, and the part of the
+// organization the submitter could see when it was queued.
+type reportJob struct {
+	models.ReportJobParams
+	Root *int `json:"root,omitempty"`
+}
+
+// prepareReport checks the params of a report job and resolves the
+// caller's scope, which the job keeps.
+func (h *JobHandler) prepareReport(c *gin.Context, raw json.RawMessage) (any, error) {
+	var params models.ReportJobParams
+	if err := decodeJobParams(raw, &params); err != nil {
+		return nil, err
+	}
+	q := queryValues(params.Query)
+	if _, err := reportFilter(q); err != nil {
+		return nil, prefixFields("params.query", err)
+	}
+	root, err := h.Reports.scope(c.Request.Context(), username(c), q.Get("manager"))
+	if err != nil {
+		return nil, prefixFields("params.query", err)
+	}
+	return reportJob{ReportJobParams: params, Root: root}, nil
+}
+
+func (h *JobHandler) runReport(ctx context.Context, raw json.RawMessage, p *jobs.Progress) (*repositories.JobResult, error) {
+	var job reportJob
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, fmt.Errorf("decoding report params: %w", err)
+	}
+	filter, err := reportFilter(queryValues(job.Query))
+	if err != nil {
+		return nil, err
+	}
+	p.SetTotal(1)
+	var report any
+	switch job.Report {
+	case "sales-reps":
+		report, err = h.Reports.salesReps(ctx, filter, job.Root)
+	case "managers":
+		report, err = h.Reports.managers(ctx, filter, job.Root)
+	default:
+		err = apperr.Field("report", "must be one of sales-reps managers")
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Add(1)
+	return jsonResult(job.Report+".json", report)
+}
+
+func jsonResult(filename string, v any) (*repositories.JobResult, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return &repositories.JobResult{ContentType: "application/json; charset=utf-8", Filename: filename, Body: body}, nil
+}
+
+// queryValues turns the query map of job params into the query parameters
+// the equivalent GET request would have.
+func queryValues(query map[string]string) url.Values {
+	values := url.Values{}
+	for name, value := range query {
+		values.Set(name, value)
+	}
+	return values
+}
@@ -1,15 +1,17 @@
 package handlers
 
 import (
+	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type MediaTypeHandler struct {
-	Repo *repositories.MediaTypeRepository
+	Repo     *repositories.MediaTypeRepository
+	Tracks   *repositories.TrackRepository
+	Includes *repositories.Includer
 }
 
 // @Summary Get all media types
@@ -18,12 +20,12 @@ type MediaTypeHandler struct {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {array} models.MediaType
-// @Failure 404 {object} models.ErrorResponse
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/media_types [get]
 func (h *MediaTypeHandler) GetAll(c *gin.Context) {
 	mediaTypes, err := h.Repo.GetAllMediaTypes(c.Request.Context())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, mediaTypes)
@@ -36,14 +38,56 @@ func (h *MediaTypeHandler) GetAll(c *gin.Context) {
 // @Security BearerAuth
 // @Param id path int true "Media Type ID"
 // @Success 200 {object} models.MediaType
-// @Failure 404 {object} models.ErrorResponse
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/media_types/{id} [get]
 func (h *MediaTypeHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	mediaType, err := h.Repo.GetMediaTypeByID(c.Request.Context(), utils.ParseInt(id))
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	mediaType, err := h.Repo.GetMediaTypeByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, mediaType)
 }
+
+// @Summary Get a media type's tracks
+// @Description Returns a paginated list of the tracks encoded in the media type
+// @Tags media_types
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Media Type ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Param include query string false "Comma-separated relations to embed: album, album.artist, album.tracks, genre, media_type"
+// @Success 200 {object} models.Page[models.Track]
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/media_types/{id}/tracks [get]
+func (h *MediaTypeHandler) GetTracks(c *gin.Context) {
+	include, ok := parseInclude(c, "track")
+	if !ok {
+		return
+	}
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetMediaTypeByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	limit, offset := parsePagination(c)
+	tracks, total, err := h.Tracks.GetTracksByMediaTypeID(c.Request.Context(), id, limit, offset)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if err := h.Includes.Tracks(c.Request.Context(), tracks, include); err != nil {
+		includeFailed(c, err)
+		return
+	}
+	respondPage[models.Track](c, tracks, total, limit, offset)
+}
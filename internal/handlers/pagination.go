@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"chinook-api/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PageLimits bounds the limit query parameter on list endpoints. It is set
+// from configuration at startup.
+var PageLimits = struct {
+	Default int
+	Max     int
+}{Default: 50, Max: 500}
+
+// parsePagination reads limit and offset from the query string, falling back
+// to the defaults on invalid input and capping limit at PageLimits.Max.
+func parsePagination(c *gin.Context) (limit, offset int) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = PageLimits.Default
+	}
+	if limit > PageLimits.Max {
+		limit = PageLimits.Max
+	}
+	offset, err = strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// respondPage writes one page of a collection in the same shape as the
+// paginated artist list.
+func respondPage[T any](c *gin.Context, data []T, total, limit, offset int) {
+	if data == nil {
+		data = []T{}
+	}
+	c.JSON(http.StatusOK, models.Page[T]{
+		Data:    data,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: offset+limit < total,
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/patch"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// acceptPatch is the Accept-Patch header (RFC 5789) sent with 415 responses.
+const acceptPatch = patch.MergePatchType + ", " + patch.JSONPatchType
+
+var (
+	errPatchMediaType = apperr.New(apperr.KindUnsupportedMedia, "unsupported_patch_type", "PATCH bodies must be %s or %s", patch.MergePatchType, patch.JSONPatchType)
+	errInvalidPatch   = apperr.BadRequest("invalid_patch", "patch document is not valid")
+	errPatchConflict  = apperr.New(apperr.KindUnprocessable, "patch_conflict", "patch cannot be applied to the resource")
+	errPatchTest      = apperr.Conflict("patch_test_failed", "a test operation of the patch did not match")
+)
+
+// patchBody applies the request body, a merge patch or JSON patch chosen by
+// Content-Type, to current, the input form of the resource. The result is
+// decoded and validated exactly like a PUT body, so fields the patch adds
+// must exist and the patched resource must be valid as a whole. It writes
+// the error response itself and returns false on failure.
+func patchBody[T any](c *gin.Context, current T) (T, bool) {
+	var patched T
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = errBodyTooLarge(tooLarge)
+		}
+		abortWithError(c, err)
+		return patched, false
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		abortWithError(c, err)
+		return patched, false
+	}
+
+	switch c.ContentType() {
+	case patch.MergePatchType:
+		doc, err = patch.Merge(doc, body)
+	case patch.JSONPatchType:
+		doc, err = patch.Apply(doc, body)
+	default:
+		c.Header("Accept-Patch", acceptPatch)
+		abortWithError(c, errPatchMediaType)
+		return patched, false
+	}
+	if err != nil {
+		abortWithError(c, patchError(err))
+		return patched, false
+	}
+
+	if err := decodeStrict(bytes.NewReader(doc), &patched); err != nil {
+		abortWithError(c, err)
+		return patched, false
+	}
+	if err := validateStruct(&patched); err != nil {
+		abortWithError(c, err)
+		return patched, false
+	}
+	return patched, true
+}
+
+// patchError converts an error from the patch package into a domain error.
+// Its messages name only the operation and path, so they are safe to show.
+func patchError(err error) error {
+	switch {
+	case errors.Is(err, patch.ErrTestFailed):
+		return errPatchTest.Withf("%s", err.Error())
+	case errors.Is(err, patch.ErrConflict):
+		return errPatchConflict.Withf("%s", err.Error())
+	case errors.Is(err, patch.ErrInvalid):
+		return errInvalidPatch.Withf("%s", err.Error())
+	}
+	return err
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,12 +17,12 @@ type PlaylistHandler struct {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {array} models.Playlist
-// @Failure 404 {object} models.ErrorResponse
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/playlists [get]
 func (h *PlaylistHandler) GetAll(c *gin.Context) {
 	playlists, err := h.Repo.GetAllPlaylists(c.Request.Context())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, playlists)
@@ -35,15 +34,64 @@ func (h *PlaylistHandler) GetAll(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Playlist ID"
+// @Param If-None-Match header string false "ETag of a cached copy; 304 when it is still current"
 // @Success 200 {object} models.Playlist
-// @Failure 404 {object} models.ErrorResponse
+// @Success 304 "Not modified"
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/playlists/{id} [get]
 func (h *PlaylistHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	playlist, err := h.Repo.GetPlaylistByID(c.Request.Context(), utils.ParseInt(id))
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	playlist, err := h.Repo.GetPlaylistByID(c.Request.Context(), id)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, playlist)
 }
+
+// @Summary Patch a playlist
+// @Description Partially updates a playlist with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), chosen by Content-Type. The patch is applied to the playlist in the form of the update body, and the result is validated like a full update before it is saved.
+// @Tags playlists
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Playlist ID"
+// @Param patch body models.PlaylistInput true "Merge patch with the fields to change, or an array of JSON Patch operations"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Playlist
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "A test operation did not match"
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 415 {object} models.Problem
+// @Failure 422 {object} models.Problem "An operation's path does not exist"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/playlists/{id} [patch]
+func (h *PlaylistHandler) Patch(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("playlist", id)()
+	playlist, err := h.Repo.GetPlaylistByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, playlist) {
+		return
+	}
+	in, ok := patchBody(c, playlist.Input())
+	if !ok {
+		return
+	}
+	playlist = in.Playlist(id)
+	if err := h.Repo.UpdatePlaylist(c.Request.Context(), playlist); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	respondResource(c, http.StatusOK, playlist)
+}
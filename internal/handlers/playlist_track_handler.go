@@ -1,16 +1,16 @@
 package handlers
 
 import (
+	"chinook-api/internal/apperr"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 type PlaylistTrackHandler struct {
-	Repo *repositories.PlaylistTrackRepository
+	Repo     *repositories.PlaylistTrackRepository
+	Includes *repositories.Includer
 }
 
 // @Summary Get all tracks in a playlist
@@ -19,26 +19,32 @@ type PlaylistTrackHandler struct {
 // @Produce json
 // @Security BearerAuth
 // @Param playlistId path int true "Playlist ID"
+// @Param include query string false "Comma-separated relations to embed: album, album.artist, album.tracks, genre, media_type"
 // @Success 200 {array} models.Track
-// @Failure 404 {object} models.ErrorResponse
+// @Failure 404 {object} models.Problem
 // @Router /api/v1/playlists/{playlistId}/tracks [get]
 func (h *PlaylistTrackHandler) GetPlaylistTrack(c *gin.Context) {
-	playlistId := utils.ParseInt(c.Param("id"))
-	// print playlistId for debugging
-	fmt.Println("Playlist ID:", playlistId)
-	if playlistId <= 0 {
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist ID"})
+	include, ok := parseInclude(c, "track")
+	if !ok {
+		return
+	}
+	playlistId, ok := pathID(c)
+	if !ok {
 		return
 	}
-
 	tracks, err := h.Repo.GetTracksByPlaylistID(c.Request.Context(), playlistId)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
 	if len(tracks) == 0 {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No tracks found for this playlist"})
+		abortWithError(c, apperr.NotFound("playlist_tracks_not_found", "no tracks found for this playlist"))
+		return
+	}
+
+	if err := h.Includes.Tracks(c.Request.Context(), tracks, include); err != nil {
+		includeFailed(c, err)
 		return
 	}
 
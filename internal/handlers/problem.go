@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/models"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const problemContentType = "application/problem+json"
+
+// abortWithError ends the request with the problem response for err. Domain
+// errors from apperr keep their status, code and message; anything else is
+// logged and reported as a bare internal error.
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+	WriteProblem(c, err)
+}
+
+// WriteProblem writes err as application/problem+json. Only messages of
+// apperr errors reach the client.
+func WriteProblem(c *gin.Context, err error) {
+	problem := newProblem(c, err)
+	renderProblem(c, problem.Status, problem)
+}
+
+func newProblem(c *gin.Context, err error) models.Problem {
+	problem := models.Problem{
+		Type:     "about:blank",
+		Instance: c.Request.URL.Path,
+	}
+	if id, ok := c.Get("request_id"); ok {
+		problem.RequestID, _ = id.(string)
+	}
+	if e, ok := apperr.As(err); ok && e.Kind != apperr.KindInternal {
+		problem.Status = e.Kind.Status()
+		problem.Code = e.Code
+		problem.Detail = e.Message
+		problem.Fields = e.Fields
+	} else {
+		log.Error().Err(err).Str("request_id", problem.RequestID).Str("path", c.Request.URL.Path).Msg("internal error")
+		problem.Status = http.StatusInternalServerError
+		problem.Code = "internal_error"
+		problem.Detail = "an internal error occurred"
+	}
+	problem.Title = http.StatusText(problem.Status)
+	return problem
+}
+
+// renderProblem writes body, a models.Problem or a type embedding one, with
+// the problem+json content type.
+func renderProblem(c *gin.Context, status int, body any) {
+	// set before rendering so gin keeps it instead of application/json
+	c.Header("Content-Type", problemContentType)
+	c.JSON(status, body)
+}
+
+// Recover turns a panic into an internal error problem response.
+func Recover(c *gin.Context, recovered any) {
+	c.Abort()
+	WriteProblem(c, fmt.Errorf("panic: %v", recovered))
+}
+
+// ErrorMiddleware writes the problem response for errors that handlers and
+// middleware attached with c.Error without writing a response themselves.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			WriteProblem(c, c.Errors.Last().Err)
+		}
+	}
+}
+
+// NotFound answers requests for routes that do not exist.
+func NotFound(c *gin.Context) {
+	abortWithError(c, apperr.NotFound("route_not_found", "no route for %s %s", c.Request.Method, c.Request.URL.Path))
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportHandler serves the sales rep and manager reports. Callers only see
+// the part of the organization below the employee their user is linked to;
+// admins, and requests without an identity when auth is disabled, see
+// everyone.
+type ReportHandler struct {
+	Repo      *repositories.ReportRepository
+	Users     *repositories.UserRepository
+	Employees *repositories.EmployeeRepository
+	Plan      models.CommissionPlan
+}
+
+// @Summary Sales by support rep
+// @Description Per support rep and period: customers invoiced, invoice count, revenue, average invoice and commission, plus the number of customers assigned to the rep. Commission is the configured rate on each period's revenue above the threshold. Non-admin users see only reps in the subtree of the employee they are linked to.
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Param period query string false "day, week, month, quarter or year (default month)"
+// @Param from query string false "First invoice date included (YYYY-MM-DD)"
+// @Param to query string false "Last invoice date included (YYYY-MM-DD)"
+// @Param manager query int false "Only reps at or below this employee"
+// @Success 200 {object} models.RepSalesResponse
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/reports/sales-reps [get]
+func (h *ReportHandler) SalesReps(c *gin.Context) {
+	filter, ok := parseReportFilter(c)
+	if !ok {
+		return
+	}
+	root, ok := h.reportScope(c)
+	if !ok {
+		return
+	}
+	resp, err := h.salesReps(c.Request.Context(), filter, root)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Sales by manager
+// @Description Per manager and period, the sales of the manager's own customers and of everyone below them in the ReportsTo hierarchy. Commission is the manager's own rep commission plus the configured override rate on the team's revenue. Non-admin users see only managers in the subtree of the employee they are linked to.
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Param period query string false "day, week, month, quarter or year (default month)"
+// @Param from query string false "First invoice date included (YYYY-MM-DD)"
+// @Param to query string false "Last invoice date included (YYYY-MM-DD)"
+// @Param manager query int false "Only managers at or below this employee"
+// @Success 200 {object} models.ManagerSalesResponse
+// @Failure 400 {object} models.Problem
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/reports/managers [get]
+func (h *ReportHandler) Managers(c *gin.Context) {
+	filter, ok := parseReportFilter(c)
+	if !ok {
+		return
+	}
+	root, ok := h.reportScope(c)
+	if !ok {
+		return
+	}
+	resp, err := h.managers(c.Request.Context(), filter, root)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// salesReps builds the sales rep report for the subtree below root, or for
+// everyone when root is nil.
+func (h *ReportHandler) salesReps(ctx context.Context, filter repositories.SalesFilter, root *int) (models.RepSalesResponse, error) {
+	reps, err := h.Repo.RepSales(ctx, filter, h.Plan, root)
+	if err != nil {
+		return models.RepSalesResponse{}, err
+	}
+	from, to := filter.Dates()
+	return models.RepSalesResponse{
+		Period:     filter.Period,
+		From:       from,
+		To:         to,
+		Commission: h.Plan,
+		Reps:       reps,
+	}, nil
+}
+
+// managers builds the manager report for the subtree below root, or for
+// everyone when root is nil.
+func (h *ReportHandler) managers(ctx context.Context, filter repositories.SalesFilter, root *int) (models.ManagerSalesResponse, error) {
+	managers, err := h.Repo.ManagerSales(ctx, filter, h.Plan, root)
+	if err != nil {
+		return models.ManagerSalesResponse{}, err
+	}
+	from, to := filter.Dates()
+	return models.ManagerSalesResponse{
+		Period:     filter.Period,
+		From:       from,
+		To:         to,
+		Commission: h.Plan,
+		Managers:   managers,
+	}, nil
+}
+
+// reportScope returns the employee whose subtree the caller may see, or nil
+// for everyone, narrowed by the manager parameter. It writes the error
+// response itself and returns false when the caller may not see the report.
+func (h *ReportHandler) reportScope(c *gin.Context) (*int, bool) {
+	root, err := h.scope(c.Request.Context(), username(c), c.Query("manager"))
+	if err != nil {
+		abortWithError(c, err)
+		return nil, false
+	}
+	return root, true
+}
+
+// scope returns the employee whose subtree username may see, or nil for
+// everyone, narrowed by manager when it is set. An empty username is a
+// request without an identity.
+func (h *ReportHandler) scope(ctx context.Context, username, manager string) (*int, error) {
+	var root *int
+	if username != "" {
+		user, err := h.Users.GetUserByUsername(ctx, username)
+		if err != nil {
+			return nil, errForbidden
+		}
+		if user.Role != "admin" {
+			if user.EmployeeID == nil {
+				return nil, apperr.Forbidden("employee_link_required", "reports require a user linked to an employee")
+			}
+			root = user.EmployeeID
+		}
+	}
+
+	if manager == "" {
+		return root, nil
+	}
+	id, err := strconv.Atoi(manager)
+	if err != nil || id <= 0 {
+		return nil, apperr.Field("manager", "must be a positive integer")
+	}
+	if _, err := h.Employees.GetEmployeeByID(ctx, id); err != nil {
+		return nil, err
+	}
+	if root != nil {
+		manages, err := h.Employees.Manages(ctx, *root, id)
+		if err != nil {
+			return nil, err
+		}
+		if !manages {
+			return nil, apperr.Forbidden("outside_scope", "employee is outside your part of the organization")
+		}
+	}
+	return &id, nil
+}
+
+// parseReportFilter reads the period and date range, writing a 400 itself
+// when they are invalid.
+func parseReportFilter(c *gin.Context) (repositories.SalesFilter, bool) {
+	filter, err := reportFilter(c.Request.URL.Query())
+	if err != nil {
+		abortWithError(c, err)
+		return filter, false
+	}
+	return filter, true
+}
+
+func reportFilter(q url.Values) (repositories.SalesFilter, error) {
+	filter, err := parseSalesRange(q)
+	if err != nil {
+		return filter, err
+	}
+	period := q.Get("period")
+	if period == "" {
+		period = "month"
+	}
+	filter.Period, err = parseSalesOption("period", period, repositories.SalesPeriods)
+	return filter, err
+}
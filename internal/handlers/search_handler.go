@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+	"chinook-api/internal/search"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 5
+	maxSearchLimit     = 50
+	// suggestionLimit caps the fuzzy suggestions offered for a failed search.
+	suggestionLimit = 5
+)
+
+type SearchHandler struct {
+	Repo         *repositories.SearchRepository
+	Fuzzy        *search.FuzzyIndex
+	Autocomplete *search.AutocompleteIndex
+}
+
+// @Summary Full-text catalog search
+// @Description Searches artist names, album titles, track names and composers. Every word is matched as a prefix; hits are ranked, grouped by type and returned with <mark>-highlighted names and snippets. When nothing matches, similarly spelled names are returned as suggestions.
+// @Tags search
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search text"
+// @Param types query string false "Comma-separated types to search: artist, album, track (default all)"
+// @Param limit query int false "Maximum hits per type (default 5, max 50)"
+// @Success 200 {object} models.SearchResponse
+// @Failure 400 {object} models.Problem
+// @Failure 501 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		abortWithError(c, apperr.Field("q", "is required"))
+		return
+	}
+	kinds, err := parseSearchTypes(c.Query("types"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	limit := parseSearchLimit(c)
+
+	groups, err := h.Repo.Search(c.Request.Context(), q, kinds, limit)
+	if err != nil {
+		if errors.Is(err, database.ErrUnsupported) {
+			err = database.ErrUnsupported.Withf("full-text search is only supported on SQLite")
+		}
+		abortWithError(c, err)
+		return
+	}
+
+	resp := models.SearchResponse{Query: q}
+	matched := false
+	for kind, group := range groups {
+		matched = matched || group.Total > 0
+		switch kind {
+		case "artist":
+			resp.Artists = &group
+		case "album":
+			resp.Albums = &group
+		case "track":
+			resp.Tracks = &group
+		}
+	}
+	if !matched {
+		resp.Suggestions = h.Fuzzy.Suggest(q, kinds, limit)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Autocomplete catalog names
+// @Description Completes partial artist, album and track names from an in-memory index. A name matches when any of its words starts with q; matches are ranked by units sold.
+// @Tags search
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Text typed so far"
+// @Param types query string false "Comma-separated types to complete: artist, album, track (default all)"
+// @Param limit query int false "Maximum completions per type (default 5, max 50)"
+// @Success 200 {object} models.AutocompleteResponse
+// @Failure 400 {object} models.Problem
+// @Router /api/v1/autocomplete [get]
+func (h *SearchHandler) Complete(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		abortWithError(c, apperr.Field("q", "is required"))
+		return
+	}
+	kinds, err := parseSearchTypes(c.Query("types"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.AutocompleteResponse{
+		Query:   q,
+		Results: h.Autocomplete.Complete(q, kinds, parseSearchLimit(c)),
+	})
+}
+
+// parseSearchLimit reads the per-type limit, falling back to the default on
+// invalid input.
+func parseSearchLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	return min(limit, maxSearchLimit)
+}
+
+// parseSearchTypes reads the types filter, defaulting to every kind.
+func parseSearchTypes(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return repositories.SearchKinds, nil
+	}
+	var kinds []string
+	for _, kind := range strings.Split(raw, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "" || slices.Contains(kinds, kind) {
+			continue
+		}
+		if !slices.Contains(repositories.SearchKinds, kind) {
+			return nil, apperr.Field("types", "unknown type "+strconv.Quote(kind)+"; expected artist, album or track")
+		}
+		kinds = append(kinds, kind)
+	}
+	if len(kinds) == 0 {
+		return repositories.SearchKinds, nil
+	}
+	return kinds, nil
+}
@@ -1,42 +1,67 @@
 package handlers
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
-	"chinook-api/internal/utils"
+	"context"
 	"net/http"
 
-	"strconv"
-
 	"github.com/gin-gonic/gin"
 )
 
 type TrackHandler struct {
-	Repo *repositories.TrackRepository
+	Repo       *repositories.TrackRepository
+	Playlists  *repositories.PlaylistRepository
+	Albums     *repositories.AlbumRepository
+	Genres     *repositories.GenreRepository
+	MediaTypes *repositories.MediaTypeRepository
+	Includes   *repositories.Includer
 }
 
 // @Summary Get all tracks
-// @Description Returns a list of all tracks
+// @Description Returns a page of tracks, filtered by equality on name, album_id, media_type_id, genre_id or composer, e.g. ?genre_id=1. As CSV, NDJSON or XLSX every matching track is streamed, unless limit or offset is given.
 // @Tags tracks
 // @Produce json
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 // @Security BearerAuth
 // @Param limit query int false "Limit"
 // @Param offset query int false "Offset"
+// @Param format query string false "Export format instead of JSON; also chosen by an Accept header of text/csv, application/x-ndjson or the XLSX media type" Enums(json, csv, ndjson, xlsx)
+// @Param sort query string false "Comma-separated fields to sort by, each optionally prefixed with - for descending, e.g. album_id,-milliseconds"
+// @Param include query string false "Comma-separated relations to embed: album, album.artist, album.tracks, genre, media_type"
 // @Success 200 {array} models.Track
+// @Failure 400 {object} models.Problem
 // @Router /api/v1/tracks [get]
 func (h *TrackHandler) GetAll(c *gin.Context) {
-	limitStr := c.DefaultQuery("limit", "50")
-	offsetStr := c.DefaultQuery("offset", "0")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 50
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+	q, ok := parseListQuery(c, "track")
+	if !ok {
+		return
 	}
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil || offset < 0 {
-		offset = 0
+	if format != "" {
+		streamExport(c, format, "tracks", func(fn func(models.Track) error) error {
+			return h.Repo.EachTrack(c.Request.Context(), q, fn)
+		})
+		return
+	}
+	include, ok := parseInclude(c, "track")
+	if !ok {
+		return
 	}
-	tracks, err := h.Repo.GetTracksPaginated(c.Request.Context(), limit, offset)
+	q.Limit, q.Offset = parsePagination(c)
+	tracks, err := h.Repo.GetTracks(c.Request.Context(), q)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
+	}
+	if err := h.Includes.Tracks(c.Request.Context(), tracks, include); err != nil {
+		includeFailed(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, tracks)
@@ -48,14 +73,166 @@ func (h *TrackHandler) GetAll(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Track ID"
+// @Param include query string false "Comma-separated relations to embed: album, album.artist, album.tracks, genre, media_type"
+// @Param If-None-Match header string false "ETag of a cached copy; 304 when it is still current"
 // @Success 200 {object} models.Track
+// @Success 304 "Not modified"
+// @Failure 400 {object} models.Problem
 // @Router /api/v1/tracks/{id} [get]
 func (h *TrackHandler) GetOne(c *gin.Context) {
-	id := c.Param("id")
-	track, err := h.Repo.GetTrackByID(c.Request.Context(), utils.ParseInt(id))
+	include, ok := parseInclude(c, "track")
+	if !ok {
+		return
+	}
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	track, err := h.Repo.GetTrackByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	tracks := []models.Track{track}
+	if err := h.Includes.Tracks(c.Request.Context(), tracks, include); err != nil {
+		includeFailed(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tracks[0])
+}
+
+// @Summary Get the playlists containing a track
+// @Description Returns a paginated list of the playlists that include the track
+// @Tags tracks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Track ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.Page[models.Playlist]
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/tracks/{id}/playlists [get]
+func (h *TrackHandler) GetPlaylists(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if _, err := h.Repo.GetTrackByID(c.Request.Context(), id); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	limit, offset := parsePagination(c)
+	playlists, total, err := h.Playlists.GetPlaylistsByTrackID(c.Request.Context(), id, limit, offset)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, track)
+	respondPage(c, playlists, total, limit, offset)
+}
+
+// @Summary Create, update and delete tracks in bulk
+// @Description Applies up to limits.max_bulk_operations operations. In atomic mode (the default) all are applied or none are; in best_effort mode each succeeds or fails on its own. Each operation's data is validated like the single create and update body.
+// @Tags tracks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param operations body models.BulkRequest[models.TrackInput] true "Operations to apply"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe; repeats within the TTL get the first response"
+// @Success 200 {object} models.BulkResponse "Every operation succeeded"
+// @Success 207 {object} models.BulkResponse "Some operations failed in best_effort mode"
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem "A request with the same Idempotency-Key is still in progress"
+// @Failure 422 {object} models.BulkFailure "An operation of an atomic batch failed and nothing was applied"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/tracks/bulk [post]
+func (h *TrackHandler) Bulk(c *gin.Context) {
+	bulkWrite(c, models.TrackInput.Track, h.Repo.BulkTracks)
+}
+
+// @Summary Patch a track
+// @Description Partially updates a track with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902), chosen by Content-Type. The patch is applied to the track in the form of the update body, and the result is validated like a full update before it is saved. The album_id, genre_id and media_type_id must name existing rows.
+// @Tags tracks
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Track ID"
+// @Param patch body models.TrackInput true "Merge patch with the fields to change, or an array of JSON Patch operations"
+// @Param If-Match header string false "ETag from GET; the write is refused with 412 if the resource has changed since"
+// @Success 200 {object} models.Track
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem "A test operation did not match"
+// @Failure 412 {object} models.Problem "If-Match did not match the current ETag"
+// @Failure 415 {object} models.Problem
+// @Failure 422 {object} models.Problem "An operation's path does not exist"
+// @Failure 500 {object} models.Problem
+// @Router /api/v1/tracks/{id} [patch]
+func (h *TrackHandler) Patch(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	defer lockResource("track", id)()
+	track, err := h.Repo.GetTrackByID(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !checkIfMatch(c, track) {
+		return
+	}
+	in, ok := patchBody(c, track.Input())
+	if !ok || !h.checkRefs(c, in) {
+		return
+	}
+	track = in.Track(id)
+	if err := h.Repo.UpdateTrack(c.Request.Context(), track); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	respondResource(c, http.StatusOK, track)
+}
+
+// checkRefs reports whether the album, genre and media type of a track body
+// exist, writing a 400 naming every missing one itself when they do not.
+func (h *TrackHandler) checkRefs(c *gin.Context, in models.TrackInput) bool {
+	if err := trackRefs(c.Request.Context(), h.Albums, h.Genres, h.MediaTypes, in); err != nil {
+		abortWithError(c, err)
+		return false
+	}
+	return true
+}
+
+// trackRefs returns a validation error naming every missing album, genre and
+// media type of a track body.
+func trackRefs(ctx context.Context, albums *repositories.AlbumRepository, genres *repositories.GenreRepository, mediaTypes *repositories.MediaTypeRepository, in models.TrackInput) error {
+	fields := map[string]string{}
+	check := func(field, what string, err error) error {
+		if apperr.IsNotFound(err) {
+			fields[field] = what + " not found"
+			return nil
+		}
+		return err
+	}
+	if in.AlbumId != nil {
+		_, err := albums.GetAlbumByID(ctx, *in.AlbumId)
+		if err := check("album_id", "album", err); err != nil {
+			return err
+		}
+	}
+	if in.GenreId != nil {
+		_, err := genres.GetGenreByID(ctx, *in.GenreId)
+		if err := check("genre_id", "genre", err); err != nil {
+			return err
+		}
+	}
+	_, err := mediaTypes.GetMediaTypeByID(ctx, in.MediaTypeId)
+	if err := check("media_type_id", "media type", err); err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		return apperr.Validation(fields)
+	}
+	return nil
 }
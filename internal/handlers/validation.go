@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/validation"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	validation.Register(validate)
+}
+
+var errInvalidBody = apperr.BadRequest("invalid_body", "request body is not valid JSON for this endpoint")
+
+// bindValid decodes the JSON body into req and validates it. Unknown
+// fields, values of the wrong type and trailing data are rejected. It writes
+// the 400 response itself, listing each invalid field, and returns false
+// when the body is malformed or invalid.
+func bindValid(c *gin.Context, req any) bool {
+	if err := decodeStrict(c.Request.Body, req); err != nil {
+		abortWithError(c, err)
+		return false
+	}
+	if err := validateStruct(req); err != nil {
+		abortWithError(c, err)
+		return false
+	}
+	return true
+}
+
+// decodeStrict decodes exactly one JSON value from r into v and converts
+// decoding failures into domain errors naming the offending field.
+func decodeStrict(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		if _, err := dec.Token(); err != io.EOF {
+			return errInvalidBody.Withf("request body must contain a single JSON value")
+		}
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		return errInvalidBody.Withf("request body is required")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return errInvalidBody.Withf("request body is not valid JSON")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return apperr.Field(typeErr.Field, "must be "+jsonTypeName(typeErr.Value, typeErr.Type.Kind().String()))
+	case errors.As(err, &tooLarge):
+		return errBodyTooLarge(tooLarge)
+	}
+	// encoding/json has no typed error for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if name, err := strconv.Unquote(field); err == nil {
+			field = name
+		}
+		return apperr.Field(field, "is not a known field")
+	}
+	return errInvalidBody
+}
+
+func errBodyTooLarge(err *http.MaxBytesError) error {
+	return apperr.New(apperr.KindTooLarge, "body_too_large", "request body exceeds %d bytes", err.Limit)
+}
+
+// jsonTypeName describes the Go kind a JSON value should have had.
+func jsonTypeName(got, want string) string {
+	switch want {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		if got == "number" {
+			return "an integer"
+		}
+		return "a number"
+	case "float32", "float64":
+		return "a number"
+	case "string":
+		return "a string"
+	case "bool":
+		return "true or false"
+	case "slice", "array":
+		return "an array"
+	case "struct", "map":
+		return "an object"
+	}
+	return "a " + want
+}
+
+// validateStruct runs the validator and converts its errors to a validation
+// error listing each invalid field.
+func validateStruct(req any) error {
+	err := validate.Struct(req)
+	if err == nil {
+		return nil
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return errInvalidBody
+	}
+	fields := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		fields[fe.Field()] = validation.Message(fe)
+	}
+	return apperr.Validation(fields)
+}
+
+// pathID reads the id path parameter as a positive integer. It writes the
+// 400 response itself and returns false when the parameter is anything else,
+// so /artists/abc is rejected instead of looked up as id 0.
+func pathID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		abortWithError(c, apperr.Field("id", "must be a positive integer"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,388 @@
+// Package jobs runs long exports, imports and reports in the background. Jobs
+// are stored in the database, so they survive a restart: a job that was
+// running when the process stopped is queued again and starts over. A
+// bounded pool of workers takes queued jobs in order; a failed attempt is
+// retried with exponential backoff unless the failure is the job's own
+// fault, such as invalid params.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/models"
+	"chinook-api/internal/repositories"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// pollInterval is how often workers look for jobs that became due, such
+	// as retries, when nothing new was queued.
+	pollInterval = time.Second
+	// progressInterval is how often a running job's progress is written
+	// back.
+	progressInterval = time.Second
+	// purgeInterval is how often finished jobs past the retention are
+	// deleted.
+	purgeInterval = time.Hour
+	// maxBackoff caps the delay between attempts.
+	maxBackoff = time.Hour
+)
+
+// Runner does the work of one type of job with the params it was queued
+// with, reporting progress through p, and returns the file it produced if
+// any. It must stop when ctx is canceled. An apperr error other than an
+// internal one fails the job at once; any other error is retried.
+type Runner func(ctx context.Context, params json.RawMessage, p *Progress) (*repositories.JobResult, error)
+
+// ErrJobFinished is returned when canceling a job that has already ended.
+var ErrJobFinished = apperr.Conflict("job_finished", "the job has already finished")
+
+var (
+	errCanceled = errors.New("job canceled")
+	errShutdown = errors.New("server shutting down")
+)
+
+// Queue runs the jobs stored in Repo on Workers goroutines, making up to
+// MaxAttempts attempts at each, Backoff apart and doubling. Finished jobs
+// are deleted after Retention.
+type Queue struct {
+	Repo        *repositories.JobRepository
+	Workers     int
+	MaxAttempts int
+	Backoff     time.Duration
+	Retention   time.Duration
+
+	runners map[string]Runner
+	wake    chan struct{}
+	stop    context.CancelFunc
+	stopped chan struct{}
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[int]context.CancelCauseFunc
+}
+
+// Register makes run the runner of jobs of type typ. Runners must be
+// registered before Start.
+func (q *Queue) Register(typ string, run Runner) {
+	if q.runners == nil {
+		q.runners = map[string]Runner{}
+	}
+	q.runners[typ] = run
+}
+
+// Enqueue stores a new job of type typ for owner with params, which are
+// encoded as JSON, and wakes a worker for it.
+func (q *Queue) Enqueue(ctx context.Context, typ, owner string, params any) (models.Job, error) {
+	if _, ok := q.runners[typ]; !ok {
+		return models.Job{}, fmt.Errorf("no runner for job type %q", typ)
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return models.Job{}, fmt.Errorf("encoding job params: %w", err)
+	}
+	job, err := q.Repo.CreateJob(ctx, typ, owner, data, q.MaxAttempts)
+	if err != nil {
+		return job, err
+	}
+	log.Info().Int("job_id", job.ID).Str("type", typ).Msg("Job queued")
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Cancel stops job id. A queued job is canceled at once; a running one is
+// told to stop and is marked canceled when its runner returns.
+func (q *Queue) Cancel(ctx context.Context, id int) (models.Job, error) {
+	job, err := q.Repo.GetJob(ctx, id)
+	if err != nil {
+		return job, err
+	}
+	if job.Status == models.JobQueued {
+		canceled, err := q.Repo.CancelQueuedJob(ctx, id)
+		if err != nil {
+			return job, err
+		}
+		if canceled {
+			log.Info().Int("job_id", id).Msg("Job canceled")
+			return q.Repo.GetJob(ctx, id)
+		}
+		// a worker took it in the meantime
+	}
+
+	q.mu.Lock()
+	cancel, running := q.running[id]
+	q.mu.Unlock()
+	if !running {
+		return job, ErrJobFinished
+	}
+	cancel(errCanceled)
+	log.Info().Int("job_id", id).Msg("Job cancellation requested")
+	return q.Repo.GetJob(ctx, id)
+}
+
+// Start queues again the jobs a previous process left running and starts
+// the workers.
+func (q *Queue) Start(ctx context.Context) error {
+	n, err := q.Repo.RequeueJobs(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Info().Int("jobs", n).Msg("Resuming interrupted jobs")
+	}
+
+	q.wake = make(chan struct{}, 1)
+	q.stopped = make(chan struct{})
+	q.running = map[int]context.CancelCauseFunc{}
+	dispatchCtx, stop := context.WithCancel(context.Background())
+	q.stop = stop
+	go q.dispatch(dispatchCtx)
+	return nil
+}
+
+// Shutdown stops taking new jobs and waits for the running ones to finish.
+// When ctx ends first, the running jobs are interrupted and queued again,
+// to start over after the next Start.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stop()
+	<-q.stopped
+
+	drained := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	log.Warn().Int("jobs", len(q.running)).Msg("Interrupting running jobs; they will resume after restart")
+	for _, cancel := range q.running {
+		cancel(errShutdown)
+	}
+	q.mu.Unlock()
+	<-drained
+	return ctx.Err()
+}
+
+// dispatch hands due jobs to workers, at most Workers at a time, until ctx
+// is canceled.
+func (q *Queue) dispatch(ctx context.Context) {
+	defer close(q.stopped)
+	slots := make(chan struct{}, q.Workers)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	var purged time.Time
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		job, params, err := q.Repo.ClaimJob(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to claim a job")
+		}
+		if err == nil && job != nil {
+			q.wg.Add(1)
+			go func() {
+				defer q.wg.Done()
+				defer func() { <-slots }()
+				q.run(*job, params)
+			}()
+			continue
+		}
+		<-slots
+
+		if time.Since(purged) >= purgeInterval {
+			purged = time.Now()
+			if n, err := q.Repo.PurgeJobs(ctx, purged.Add(-q.Retention)); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Msg("Failed to purge finished jobs")
+			} else if n > 0 {
+				log.Info().Int("jobs", n).Msg("Purged finished jobs")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run makes one attempt at job and records the outcome.
+func (q *Queue) run(job models.Job, params []byte) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+		cancel(nil)
+	}()
+
+	logger := log.With().Int("job_id", job.ID).Str("type", job.Type).Int("attempt", job.Attempts).Logger()
+	logger.Info().Msg("Job started")
+	start := time.Now()
+
+	// outcomes are recorded even when the job was interrupted
+	store := context.Background()
+	progress := &Progress{}
+	stopSaving := q.saveProgress(store, job.ID, progress, logger)
+	result, err := q.call(ctx, job.Type, params, progress)
+	stopSaving()
+
+	// A failed write leaves the job running until the next restart, which
+	// requeues it, so it must at least be in the log.
+	var stored error
+	switch cause := context.Cause(ctx); {
+	case err == nil:
+		logger.Info().Dur("took", time.Since(start)).Msg("Job succeeded")
+		stored = q.Repo.FinishJob(store, job.ID, models.JobSucceeded, nil, result)
+	case errors.Is(cause, errShutdown):
+		_, stored = q.Repo.RequeueJobs(store, job.ID)
+	case errors.Is(cause, errCanceled):
+		logger.Info().Msg("Job canceled")
+		stored = q.Repo.FinishJob(store, job.ID, models.JobCanceled, nil, nil)
+	default:
+		jobErr, retry := jobError(err)
+		if retry && job.Attempts < job.MaxAttempts {
+			delay := retryDelay(q.Backoff, job.Attempts)
+			logger.Error().Err(err).Dur("retry_in", delay).Msg("Job attempt failed")
+			stored = q.Repo.RetryJob(store, job.ID, time.Now().Add(delay), jobErr)
+			break
+		}
+		logger.Error().Err(err).Msg("Job failed")
+		stored = q.Repo.FinishJob(store, job.ID, models.JobFailed, &jobErr, result)
+	}
+	if stored != nil {
+		logger.Error().Err(stored).Msg("Failed to record job outcome")
+	}
+}
+
+// retryDelay is base doubled for each attempt after the first, up to
+// maxBackoff. Doubling stops at the cap, so a high max attempts cannot
+// overflow the Duration into a negative, immediate, retry.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// call runs the job's runner, turning a panic into an error.
+func (q *Queue) call(ctx context.Context, typ string, params []byte, p *Progress) (result *repositories.JobResult, err error) {
+	run, ok := q.runners[typ]
+	if !ok {
+		return nil, apperr.New(apperr.KindBadRequest, "unknown_job_type", "no runner for job type %q", typ)
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return run(ctx, params, p)
+}
+
+// jobError describes err for clients like a problem response would, and
+// reports whether another attempt might succeed.
+func jobError(err error) (models.JobError, bool) {
+	if e, ok := apperr.As(err); ok && e.Kind != apperr.KindInternal {
+		return models.JobError{Code: e.Code, Detail: e.Message}, false
+	}
+	return models.JobError{Code: "internal_error", Detail: "the job failed on the server"}, true
+}
+
+// saveProgress writes p back every progressInterval while the job runs,
+// and once more when the returned function is called. Saving happens on its
+// own goroutine, so a runner holding the SQLite write connection, as an
+// import does, is not blocked by it.
+func (q *Queue) saveProgress(ctx context.Context, id int, p *Progress, logger zerolog.Logger) (stop func()) {
+	done := make(chan struct{})
+	saved := make(chan struct{})
+	go func() {
+		defer close(saved)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		var last models.JobProgress
+		for {
+			select {
+			case <-done:
+				if err := q.Repo.SetProgress(ctx, id, p.get()); err != nil {
+					logger.Error().Err(err).Msg("Failed to save job progress")
+				}
+				return
+			case <-ticker.C:
+				if current := p.get(); !sameProgress(current, last) {
+					if err := q.Repo.SetProgress(ctx, id, current); err != nil {
+						// tried again on the next tick
+						logger.Warn().Err(err).Msg("Failed to save job progress")
+						continue
+					}
+					last = current
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-saved
+	}
+}
+
+// Progress counts the work a runner has done.
+type Progress struct {
+	mu       sync.Mutex
+	progress models.JobProgress
+}
+
+// SetTotal sets the amount of work the job has, once it is known.
+func (p *Progress) SetTotal(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Total = &total
+}
+
+// Add counts n more units of work done.
+func (p *Progress) Add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.progress.Done += n
+}
+
+func (p *Progress) get() models.JobProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	progress := p.progress
+	if progress.Total != nil {
+		total := *progress.Total
+		progress.Total = &total
+	}
+	return progress
+}
+
+func sameProgress(a, b models.JobProgress) bool {
+	if a.Done != b.Done || (a.Total == nil) != (b.Total == nil) {
+		return false
+	}
+	return a.Total == nil || *a.Total == *b.Total
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		base    time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{30 * time.Second, 1, 30 * time.Second},
+		{30 * time.Second, 2, time.Minute},
+		{30 * time.Second, 4, 4 * time.Minute},
+		{30 * time.Second, 8, maxBackoff},
+		// would overflow as a plain shift
+		{30 * time.Second, 100, maxBackoff},
+		{time.Second, 1 << 20, maxBackoff},
+		{48 * time.Hour, 3, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.base, tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%v, %d) = %v, want %v", tt.base, tt.attempt, got, tt.want)
+		}
+	}
+}
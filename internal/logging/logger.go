@@ -17,16 +17,22 @@ import (
 
 var Logger zerolog.Logger
 
-// InitLogger sets up zerolog to write to app.log and returns the file for closing.
-func InitLogger(logPath string) (*os.File, error) {
+// InitLogger sets up zerolog to write JSON to logPath and human-readable
+// lines to console at the given level, and returns the file for closing.
+func InitLogger(logPath, level string, console io.Writer) (*os.File, error) {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
 	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		return nil, err
 	}
+	zerolog.SetGlobalLevel(lvl)
 	zerolog.TimeFieldFormat = time.RFC3339
 
 	// ConsoleWriter for human-readable logs in terminal
-	consoleWriter := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	consoleWriter := zerolog.ConsoleWriter{Out: console, TimeFormat: time.RFC3339}
 
 	// MultiWriter: JSON to file, human-readable to terminal
 	multiWriter := io.MultiWriter(logFile, consoleWriter)
@@ -71,9 +77,10 @@ func ZerologMiddleware() gin.HandlerFunc {
 // RequestContextMiddleware injects request_id and username into the context.
 func RequestContextMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Generate request_id
+		// Generate request_id, echoed back so clients can quote it
 		requestID := uuid.New().String()
 		c.Set("request_id", requestID)
+		c.Header("X-Request-ID", requestID)
 
 		// Extract username from JWT if present
 		authHeader := c.GetHeader("Authorization")
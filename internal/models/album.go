@@ -4,4 +4,25 @@ type Album struct {
 	ID       int    `json:"id"`
 	Title    string `json:"title"`
 	ArtistID int    `json:"artist_id"`
+
+	// Embedded on request with ?include=
+	Artist *Artist `json:"artist,omitempty"`
+	Tracks []Track `json:"tracks,omitempty"`
+}
+
+// AlbumInput is the body of album create and update requests.
+type AlbumInput struct {
+	Title    string `json:"title" validate:"required,max=160"`
+	ArtistID int    `json:"artist_id" validate:"required,gt=0"`
+}
+
+// Album returns the input as the album with the given id.
+func (in AlbumInput) Album(id int) Album {
+	return Album{ID: id, Title: in.Title, ArtistID: in.ArtistID}
+}
+
+// Input returns the album in the form of an update body, which PATCH
+// requests are applied to.
+func (a Album) Input() AlbumInput {
+	return AlbumInput{Title: a.Title, ArtistID: a.ArtistID}
 }
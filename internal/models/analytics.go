@@ -0,0 +1,48 @@
+package models
+
+// SalesSeries is one line of a sales chart. Revenue, Units and Invoices are
+// aligned with SalesTimeSeries.Labels, with zeros for periods without sales.
+type SalesSeries struct {
+	Key          string    `json:"key"`
+	Label        string    `json:"label"`
+	Revenue      []float64 `json:"revenue"`
+	Units        []int     `json:"units"`
+	Invoices     []int     `json:"invoices"`
+	TotalRevenue float64   `json:"totalRevenue"`
+}
+
+// SalesTimeSeries is sales over time, either as a single "total" series or
+// as one series per top dimension value. Labels are the period buckets:
+// 2010-01-04 (day, or the Monday starting a week), 2010-01, 2010-Q1 or 2010.
+type SalesTimeSeries struct {
+	Period    string        `json:"period"`
+	Dimension string        `json:"dimension,omitempty"`
+	From      string        `json:"from,omitempty"`
+	To        string        `json:"to,omitempty"`
+	Countries []string      `json:"countries,omitempty"`
+	Labels    []string      `json:"labels"`
+	Series    []SalesSeries `json:"series"`
+}
+
+// SalesBreakdownRow is the sales of one dimension value; Share is its
+// fraction of the revenue of the whole filtered range, between 0 and 1.
+type SalesBreakdownRow struct {
+	Key      string  `json:"key"`
+	Label    string  `json:"label"`
+	Revenue  float64 `json:"revenue"`
+	Units    int     `json:"units"`
+	Invoices int     `json:"invoices"`
+	Share    float64 `json:"share"`
+}
+
+// SalesBreakdown ranks dimension values by revenue.
+type SalesBreakdown struct {
+	Dimension     string              `json:"dimension"`
+	From          string              `json:"from,omitempty"`
+	To            string              `json:"to,omitempty"`
+	Countries     []string            `json:"countries,omitempty"`
+	TotalRevenue  float64             `json:"totalRevenue"`
+	TotalUnits    int                 `json:"totalUnits"`
+	TotalInvoices int                 `json:"totalInvoices"`
+	Rows          []SalesBreakdownRow `json:"rows"`
+}
@@ -5,10 +5,29 @@ type Artist struct {
 	Name string
 }
 
+// ArtistInput is the body of artist create and update requests. Its key
+// matches the Name field of Artist.
+type ArtistInput struct {
+	Name string `json:"Name" validate:"required,max=120"`
+}
+
+// Artist returns the input as the artist with the given id.
+func (in ArtistInput) Artist(id int) Artist {
+	return Artist{ID: id, Name: in.Name}
+}
+
+// Input returns the artist in the form of an update body, which PATCH
+// requests are applied to.
+func (a Artist) Input() ArtistInput {
+	return ArtistInput{Name: a.Name}
+}
+
 type PaginatedArtistsResponse struct {
     Data    []Artist `json:"data"`
     Total   int      `json:"total"`
     Limit   int      `json:"limit"`
     Offset  int      `json:"offset"`
     HasMore bool     `json:"hasMore"`
+    // Suggestions is set on name searches that matched nothing.
+    Suggestions []Suggestion `json:"suggestions,omitempty"`
 }
\ No newline at end of file
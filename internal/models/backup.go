@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Backup is a database snapshot. Name is the file name inside backup.dir;
+// Path, where the server keeps it, is never sent to clients.
+type Backup struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"-"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
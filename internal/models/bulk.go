@@ -0,0 +1,51 @@
+package models
+
+// BulkRequest is the body of the bulk write endpoints. In atomic mode, the
+// default, every operation is applied or none is; in best_effort mode each
+// operation succeeds or fails on its own.
+type BulkRequest[T any] struct {
+	Mode       string             `json:"mode,omitempty" validate:"omitempty,oneof=atomic best_effort" example:"atomic"`
+	Operations []BulkOperation[T] `json:"operations" validate:"required,min=1"`
+}
+
+// BulkOperation is one create, update or delete. Data is the same body as
+// the single create or update endpoint takes; ID names the row to update or
+// delete.
+type BulkOperation[T any] struct {
+	Op   string `json:"op" example:"create"`
+	ID   int    `json:"id,omitempty"`
+	Data T      `json:"data,omitempty"`
+}
+
+// BulkResponse reports the outcome of every operation, in request order.
+type BulkResponse struct {
+	Mode      string           `json:"mode"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// BulkItemResult is the outcome of one operation. Status is the HTTP status
+// the single endpoint would have returned; ID is set on success.
+type BulkItemResult struct {
+	Index  int            `json:"index"`
+	Op     string         `json:"op"`
+	Status int            `json:"status" example:"201"`
+	ID     int            `json:"id,omitempty"`
+	Error  *BulkItemError `json:"error,omitempty"`
+}
+
+// BulkItemError describes why an operation failed, with the same code and
+// fields as a problem response.
+type BulkItemError struct {
+	Code   string            `json:"code" example:"validation_failed"`
+	Detail string            `json:"detail"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// BulkFailure is the problem response of an atomic bulk request in which an
+// operation failed, so that nothing was applied.
+type BulkFailure struct {
+	Problem
+	BulkResponse
+}
@@ -15,3 +15,71 @@ type Customer struct {
 	Email        string  `json:"email"`
 	SupportRepId *int    `json:"support_rep_id,omitempty"`
 }
+
+// CustomerInput is the body of customer create and update requests.
+type CustomerInput struct {
+	FirstName    string  `json:"first_name" validate:"required,max=40"`
+	LastName     string  `json:"last_name" validate:"required,max=20"`
+	Company      *string `json:"company,omitempty" validate:"omitempty,max=80"`
+	Address      *string `json:"address,omitempty" validate:"omitempty,max=70"`
+	City         *string `json:"city,omitempty" validate:"omitempty,max=40"`
+	State        *string `json:"state,omitempty" validate:"omitempty,max=40"`
+	Country      *string `json:"country,omitempty" validate:"omitempty,max=40,country"`
+	PostalCode   *string `json:"postal_code,omitempty" validate:"omitempty,max=10,postcode=Country"`
+	Phone        *string `json:"phone,omitempty" validate:"omitempty,max=24,phone"`
+	Fax          *string `json:"fax,omitempty" validate:"omitempty,max=24,phone"`
+	Email        string  `json:"email" validate:"required,max=60,email"`
+	SupportRepId *int    `json:"support_rep_id,omitempty" validate:"omitempty,gt=0"`
+}
+
+// Customer returns the input as the customer with the given id.
+func (in CustomerInput) Customer(id int) Customer {
+	return Customer{
+		CustomerId:   id,
+		FirstName:    in.FirstName,
+		LastName:     in.LastName,
+		Company:      in.Company,
+		Address:      in.Address,
+		City:         in.City,
+		State:        in.State,
+		Country:      in.Country,
+		PostalCode:   in.PostalCode,
+		Phone:        in.Phone,
+		Fax:          in.Fax,
+		Email:        in.Email,
+		SupportRepId: in.SupportRepId,
+	}
+}
+
+// Input returns the customer in the form of an update body, which PATCH
+// requests are applied to.
+func (cu Customer) Input() CustomerInput {
+	return CustomerInput{
+		FirstName:    cu.FirstName,
+		LastName:     cu.LastName,
+		Company:      cu.Company,
+		Address:      cu.Address,
+		City:         cu.City,
+		State:        cu.State,
+		Country:      cu.Country,
+		PostalCode:   cu.PostalCode,
+		Phone:        cu.Phone,
+		Fax:          cu.Fax,
+		Email:        cu.Email,
+		SupportRepId: cu.SupportRepId,
+	}
+}
+
+// ReassignCustomersRequest moves customers to another support rep. With
+// CustomerIDs empty, every customer of the source rep is moved.
+type ReassignCustomersRequest struct {
+	ToEmployeeID int   `json:"to_employee_id" validate:"required,gt=0"`
+	CustomerIDs  []int `json:"customer_ids,omitempty" validate:"omitempty,max=1000,dive,gt=0"`
+}
+
+type ReassignCustomersResponse struct {
+	FromEmployeeID int   `json:"from_employee_id"`
+	ToEmployeeID   int   `json:"to_employee_id"`
+	Reassigned     int   `json:"reassigned"`
+	CustomerIDs    []int `json:"customer_ids"`
+}
@@ -21,3 +21,101 @@ type Employee struct {
 	Fax        *string         `json:"fax,omitempty"`
 	Email      *string         `json:"email,omitempty"`
 }
+
+// OrgNode is an employee in the org chart with their direct reports.
+type OrgNode struct {
+	EmployeeId int        `json:"employee_id"`
+	LastName   string     `json:"last_name"`
+	FirstName  string     `json:"first_name"`
+	Title      *string    `json:"title,omitempty"`
+	Reports    []*OrgNode `json:"reports"`
+}
+
+// OrgChart is the management hierarchy from its roots, the employees who
+// report to nobody. Employees in a ReportsTo cycle, or reporting into one,
+// cannot be reached from a root: they are listed in Unreachable, and each
+// cycle is listed in Cycles in reporting order starting from its lowest id.
+type OrgChart struct {
+	Roots       []*OrgNode `json:"roots"`
+	Cycles      [][]int    `json:"cycles,omitempty"`
+	Unreachable []int      `json:"unreachable,omitempty"`
+}
+
+// OrgMember is an employee at Depth levels below (reports) or above (chain)
+// the employee a listing is for.
+type OrgMember struct {
+	EmployeeId int     `json:"employee_id"`
+	LastName   string  `json:"last_name"`
+	FirstName  string  `json:"first_name"`
+	Title      *string `json:"title,omitempty"`
+	ReportsTo  *int    `json:"reports_to,omitempty"`
+	Depth      int     `json:"depth"`
+}
+
+// OrgMembers lists an employee's reports or management chain. CycleDetected
+// is set when the walk ran into a ReportsTo cycle and stopped there.
+type OrgMembers struct {
+	EmployeeId    int         `json:"employee_id"`
+	Members       []OrgMember `json:"members"`
+	CycleDetected bool        `json:"cycle_detected"`
+}
+
+// EmployeeInput is the body of employee create and update requests.
+type EmployeeInput struct {
+	LastName   string          `json:"last_name" validate:"required,max=20"`
+	FirstName  string          `json:"first_name" validate:"required,max=20"`
+	Title      *string         `json:"title,omitempty" validate:"omitempty,max=30"`
+	ReportsTo  *int            `json:"reports_to,omitempty" validate:"omitempty,gt=0"`
+	BirthDate  *utils.DateOnly `json:"BirthDate,omitempty"`
+	HireDate   *utils.DateOnly `json:"HireDate,omitempty"`
+	Address    *string         `json:"address,omitempty" validate:"omitempty,max=70"`
+	City       *string         `json:"city,omitempty" validate:"omitempty,max=40"`
+	State      *string         `json:"state,omitempty" validate:"omitempty,max=40"`
+	Country    *string         `json:"country,omitempty" validate:"omitempty,max=40,country"`
+	PostalCode *string         `json:"postal_code,omitempty" validate:"omitempty,max=10,postcode=Country"`
+	Phone      *string         `json:"phone,omitempty" validate:"omitempty,max=24,phone"`
+	Fax        *string         `json:"fax,omitempty" validate:"omitempty,max=24,phone"`
+	Email      *string         `json:"email,omitempty" validate:"omitempty,max=60,email"`
+}
+
+// Employee returns the input as the employee with the given id.
+func (in EmployeeInput) Employee(id int) Employee {
+	return Employee{
+		EmployeeId: id,
+		LastName:   in.LastName,
+		FirstName:  in.FirstName,
+		Title:      in.Title,
+		ReportsTo:  in.ReportsTo,
+		BirthDate:  in.BirthDate,
+		HireDate:   in.HireDate,
+		Address:    in.Address,
+		City:       in.City,
+		State:      in.State,
+		Country:    in.Country,
+		PostalCode: in.PostalCode,
+		Phone:      in.Phone,
+		Fax:        in.Fax,
+		Email:      in.Email,
+	}
+}
+
+// Input returns the employee in the form of an update body, which PATCH
+// requests are applied to.
+func (e Employee) Input() EmployeeInput {
+	return EmployeeInput{
+		LastName:   e.LastName,
+		FirstName:  e.FirstName,
+		Title:      e.Title,
+		ReportsTo:  e.ReportsTo,
+		BirthDate:  e.BirthDate,
+		HireDate:   e.HireDate,
+		Address:    e.Address,
+		City:       e.City,
+		State:      e.State,
+		Country:    e.Country,
+		PostalCode: e.PostalCode,
+		Phone:      e.Phone,
+		Fax:        e.Fax,
+		Email:      e.Email,
+	}
+}
@@ -1,5 +1,15 @@
 package models
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// Problem is an RFC 7807 problem details response, sent as
+// application/problem+json for every error. Code is a stable identifier for
+// the kind of error; Fields lists invalid request fields by their JSON names.
+type Problem struct {
+	Type      string            `json:"type" example:"about:blank"`
+	Title     string            `json:"title" example:"Not Found"`
+	Status    int               `json:"status" example:"404"`
+	Detail    string            `json:"detail,omitempty" example:"artist not found"`
+	Instance  string            `json:"instance,omitempty" example:"/api/v1/artists/999"`
+	Code      string            `json:"code" example:"artist_not_found"`
+	RequestID string            `json:"request_id,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
 }
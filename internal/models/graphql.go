@@ -0,0 +1,33 @@
+package models
+
+// GraphQLRequest is the body of POST /graphql. Extensions is accepted for
+// client compatibility and ignored.
+type GraphQLRequest struct {
+	Query         string         `json:"query" validate:"required"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	Extensions    map[string]any `json:"extensions,omitempty"`
+}
+
+// GraphQLResponse documents the response of POST /graphql. Data is null
+// when the query was rejected before it ran.
+type GraphQLResponse struct {
+	Data   any            `json:"data"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// GraphQLError is one error of a GraphQL response. Extensions holds the
+// same code as a problem response, the invalid fields of validation errors
+// and, for internal errors, the request_id.
+type GraphQLError struct {
+	Message    string            `json:"message"`
+	Locations  []GraphQLLocation `json:"locations,omitempty"`
+	Path       []any             `json:"path,omitempty"`
+	Extensions map[string]any    `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation is a position in the query, counted from 1.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
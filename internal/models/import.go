@@ -0,0 +1,48 @@
+package models
+
+// ImportReport describes what a catalog import did, or for a dry run what
+// it would do. An import is applied only when every row succeeded.
+type ImportReport struct {
+	DryRun     bool   `json:"dry_run"`
+	OnConflict string `json:"on_conflict" example:"skip"`
+	Applied    bool   `json:"applied"`
+	Rows       int    `json:"rows"`
+	Created    int    `json:"created"`
+	Updated    int    `json:"updated"`
+	Unchanged  int    `json:"unchanged"`
+	Skipped    int    `json:"skipped"`
+	Failed     int    `json:"failed"`
+	// Names of the artists, albums, genres and media types the import
+	// creates because no existing one matched.
+	NewArtists    []string    `json:"new_artists"`
+	NewAlbums     []string    `json:"new_albums"`
+	NewGenres     []string    `json:"new_genres"`
+	NewMediaTypes []string    `json:"new_media_types"`
+	Results       []ImportRow `json:"results"`
+}
+
+// ImportRow is the outcome of one row of the file. Row counts data rows
+// from 1, not counting a CSV header.
+type ImportRow struct {
+	Row    int    `json:"row"`
+	Action string `json:"action" example:"create" enums:"create,update,unchanged,skip,error"`
+	// TrackID is the existing track for update, unchanged and skip, and the
+	// new track for an applied create.
+	TrackID int                     `json:"track_id,omitempty"`
+	Changes map[string]ImportChange `json:"changes,omitempty"`
+	Errors  map[string]string       `json:"errors,omitempty"`
+}
+
+// ImportChange is the old and new value of a track field changed by an
+// update.
+type ImportChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// ImportFailure is the problem response of an import that was not applied
+// because some rows failed.
+type ImportFailure struct {
+	Problem
+	ImportReport
+}
@@ -12,6 +12,10 @@ type Invoice struct {
 	BillingCountry    *string   `json:"billing_country,omitempty"`
 	BillingPostalCode *string   `json:"billing_postal_code,omitempty"`
 	Total             float64   `json:"total"`
+
+	// Embedded on request with ?include=
+	Customer *Customer     `json:"customer,omitempty"`
+	Lines    []InvoiceLine `json:"lines,omitempty"`
 }
 
 type InvoiceLine struct {
@@ -20,4 +24,50 @@ type InvoiceLine struct {
     TrackId       int     `json:"track_id"`
     UnitPrice     float64 `json:"unit_price"`
     Quantity      int     `json:"quantity"`
-}
\ No newline at end of file
+
+    // Embedded on request with ?include=
+    Track   *Track   `json:"track,omitempty"`
+    Invoice *Invoice `json:"invoice,omitempty"`
+}
+// InvoiceDetail is the fully joined view of one invoice returned for
+// ?detail=full. Amounts are summed in cents, so LinesTotal and Difference
+// are exact to the cent.
+type InvoiceDetail struct {
+    InvoiceId      int                 `json:"invoice_id"`
+    InvoiceDate    time.Time           `json:"invoice_date"`
+    Customer       InvoiceCustomer     `json:"customer"`
+    BillingAddress BillingAddress      `json:"billing_address"`
+    Lines          []InvoiceDetailLine `json:"lines"`
+    Total          float64             `json:"total"`
+    LinesTotal     float64             `json:"lines_total"`
+    // TotalMatches is false when the line subtotals do not add up to Total;
+    // Difference is then Total minus LinesTotal.
+    TotalMatches bool    `json:"total_matches"`
+    Difference   float64 `json:"difference"`
+}
+
+type InvoiceCustomer struct {
+    CustomerId int    `json:"customer_id"`
+    FirstName  string `json:"first_name"`
+    LastName   string `json:"last_name"`
+    Email      string `json:"email"`
+}
+
+type BillingAddress struct {
+    Address    *string `json:"address,omitempty"`
+    City       *string `json:"city,omitempty"`
+    State      *string `json:"state,omitempty"`
+    Country    *string `json:"country,omitempty"`
+    PostalCode *string `json:"postal_code,omitempty"`
+}
+
+type InvoiceDetailLine struct {
+    InvoiceLineId int     `json:"invoice_line_id"`
+    TrackId       int     `json:"track_id"`
+    TrackName     string  `json:"track_name"`
+    AlbumTitle    *string `json:"album_title,omitempty"`
+    ArtistName    *string `json:"artist_name,omitempty"`
+    UnitPrice     float64 `json:"unit_price"`
+    Quantity      int     `json:"quantity"`
+    Subtotal      float64 `json:"subtotal"`
+}
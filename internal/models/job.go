@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job statuses. A job is queued until a worker picks it up, and queued again
+// while it waits to retry after a failed attempt.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+// JobRequest is the body of POST /jobs. Params depend on the type; see
+// ExportJobParams, ImportJobParams and ReportJobParams.
+type JobRequest struct {
+	Type   string          `json:"type" validate:"required" example:"export"`
+	Params json.RawMessage `json:"params" validate:"required" swaggertype:"object"`
+}
+
+// Job is a background job and how far it has got.
+type Job struct {
+	ID   int    `json:"job_id"`
+	Type string `json:"type" example:"export"`
+	// Owner is the username that submitted the job.
+	Owner       string      `json:"-"`
+	Status      string      `json:"status" example:"running" enums:"queued,running,succeeded,failed,canceled"`
+	Progress    JobProgress `json:"progress"`
+	Attempts    int         `json:"attempts"`
+	MaxAttempts int         `json:"max_attempts"`
+	// Error is why the last attempt failed. A queued job with an error is
+	// waiting to retry at NextRunAt.
+	Error      *JobError  `json:"error,omitempty"`
+	HasResult  bool       `json:"has_result"`
+	CreatedAt  time.Time  `json:"created_at"`
+	NextRunAt  *time.Time `json:"next_run_at,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobProgress counts the units of work done, such as rows exported. Total is
+// unset while it is unknown.
+type JobProgress struct {
+	Done  int  `json:"done"`
+	Total *int `json:"total,omitempty"`
+}
+
+// JobError has the same code and detail a problem response would.
+type JobError struct {
+	Code   string `json:"code" example:"internal_error"`
+	Detail string `json:"detail"`
+}
+
+// ExportJobParams exports a list as a file. Query holds the filter, sort,
+// limit and offset parameters the list endpoint takes.
+type ExportJobParams struct {
+	Resource string            `json:"resource" validate:"required,oneof=invoices customers tracks" example:"invoices"`
+	Format   string            `json:"format" validate:"required,oneof=csv ndjson xlsx" example:"csv"`
+	Query    map[string]string `json:"query,omitempty"`
+}
+
+// ImportJobParams imports a catalog delivery given inline as Data, with the
+// same options as POST /admin/imports.
+type ImportJobParams struct {
+	Format     string `json:"format" validate:"required,oneof=csv json" example:"csv"`
+	OnConflict string `json:"on_conflict,omitempty" validate:"omitempty,oneof=skip update fail" example:"fail"`
+	DryRun     bool   `json:"dry_run,omitempty"`
+	Data       string `json:"data" validate:"required"`
+}
+
+// ReportJobParams runs a sales report. Query holds the period, from, to and
+// manager parameters the report endpoint takes.
+type ReportJobParams struct {
+	Report string            `json:"report" validate:"required,oneof=sales-reps managers" example:"sales-reps"`
+	Query  map[string]string `json:"query,omitempty"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// Page is one page of a paginated collection.
+type Page[T any] struct {
+	Data    []T  `json:"data"`
+	Total   int  `json:"total"`
+	Limit   int  `json:"limit"`
+	Offset  int  `json:"offset"`
+	HasMore bool `json:"hasMore"`
+}
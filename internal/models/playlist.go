@@ -4,3 +4,19 @@ type Playlist struct {
 	PlaylistId int     `json:"playlist_id"`
 	Name       *string `json:"name,omitempty"`
 }
+
+// PlaylistInput is the body of playlist write requests.
+type PlaylistInput struct {
+	Name *string `json:"name,omitempty" validate:"omitempty,max=120"`
+}
+
+// Playlist returns the input as the playlist with the given id.
+func (in PlaylistInput) Playlist(id int) Playlist {
+	return Playlist{PlaylistId: id, Name: in.Name}
+}
+
+// Input returns the playlist in the form of an update body, which PATCH
+// requests are applied to.
+func (p Playlist) Input() PlaylistInput {
+	return PlaylistInput{Name: p.Name}
+}
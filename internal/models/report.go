@@ -0,0 +1,66 @@
+package models
+
+// CommissionPlan is the commission calculation applied to each period. A rep
+// earns Rate on the revenue of their own customers above Threshold; a
+// manager also earns OverrideRate on the revenue of everyone below them.
+type CommissionPlan struct {
+	Rate         float64 `json:"rate"`
+	OverrideRate float64 `json:"override_rate"`
+	Threshold    float64 `json:"threshold"`
+}
+
+// SalesFigures are the invoice totals of a set of customers. Customers
+// counts the distinct customers invoiced.
+type SalesFigures struct {
+	Customers      int     `json:"customers"`
+	Invoices       int     `json:"invoices"`
+	Revenue        float64 `json:"revenue"`
+	AverageInvoice float64 `json:"average_invoice"`
+	Commission     float64 `json:"commission"`
+}
+
+type PeriodSales struct {
+	Period string `json:"period"`
+	SalesFigures
+}
+
+// RepSalesReport covers the customers whose support rep is the employee.
+type RepSalesReport struct {
+	EmployeeId        int     `json:"employee_id"`
+	Name              string  `json:"name"`
+	Title             *string `json:"title,omitempty"`
+	ReportsTo         *int    `json:"reports_to,omitempty"`
+	AssignedCustomers int     `json:"assigned_customers"`
+	SalesFigures
+	Periods []PeriodSales `json:"periods"`
+}
+
+// ManagerSalesReport rolls up the manager's own customers and those of
+// everyone below them in the hierarchy. Commission is the manager's own rep
+// commission plus the override on the team's revenue.
+type ManagerSalesReport struct {
+	EmployeeId        int     `json:"employee_id"`
+	Name              string  `json:"name"`
+	Title             *string `json:"title,omitempty"`
+	ReportsTo         *int    `json:"reports_to,omitempty"`
+	TeamSize          int     `json:"team_size"`
+	AssignedCustomers int     `json:"assigned_customers"`
+	SalesFigures
+	Periods []PeriodSales `json:"periods"`
+}
+
+type RepSalesResponse struct {
+	Period     string           `json:"period"`
+	From       string           `json:"from,omitempty"`
+	To         string           `json:"to,omitempty"`
+	Commission CommissionPlan   `json:"commission"`
+	Reps       []RepSalesReport `json:"reps"`
+}
+
+type ManagerSalesResponse struct {
+	Period     string               `json:"period"`
+	From       string               `json:"from,omitempty"`
+	To         string               `json:"to,omitempty"`
+	Commission CommissionPlan       `json:"commission"`
+	Managers   []ManagerSalesReport `json:"managers"`
+}
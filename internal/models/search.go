@@ -0,0 +1,62 @@
+package models
+
+// SearchHit is one ranked full-text match; a higher Score is more relevant.
+// Highlight is the matched name with the query terms wrapped in <mark> tags;
+// Snippet is a short fragment around the best match, which for tracks may
+// come from the composer.
+type SearchHit struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	Highlight string  `json:"highlight"`
+	Snippet   string  `json:"snippet"`
+	Artist    string  `json:"artist,omitempty"`
+	Album     string  `json:"album,omitempty"`
+	Composer  string  `json:"composer,omitempty"`
+	Score     float64 `json:"score"`
+}
+
+// SearchGroup holds the best hits of one kind and how many matched in total.
+type SearchGroup struct {
+	Total int         `json:"total"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+type SearchResponse struct {
+	Query   string       `json:"query"`
+	Artists *SearchGroup `json:"artists,omitempty"`
+	Albums  *SearchGroup `json:"albums,omitempty"`
+	Tracks  *SearchGroup `json:"tracks,omitempty"`
+	// Suggestions is set when no group has any hits.
+	Suggestions []Suggestion `json:"suggestions,omitempty"`
+}
+
+// Suggestion is a catalog name similar to a query that found nothing, with
+// its trigram similarity to the query between 0 and 1.
+type Suggestion struct {
+	Type  string  `json:"type"`
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// NoMatchResponse is the problem returned when a name search finds no exact
+// matches, with similarly named entries as suggestions.
+type NoMatchResponse struct {
+	Problem
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// AutocompleteItem is one completion; Sales is the number of units sold,
+// which drives the ranking.
+type AutocompleteItem struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Sales int    `json:"sales"`
+}
+
+// AutocompleteResponse holds the completions for each requested type,
+// keyed by "artist", "album" or "track".
+type AutocompleteResponse struct {
+	Query   string                        `json:"query"`
+	Results map[string][]AutocompleteItem `json:"results"`
+}
@@ -10,4 +10,51 @@ type Track struct {
 	Milliseconds int     `json:"milliseconds"`
 	Bytes        *int    `json:"bytes,omitempty"`
 	UnitPrice    float64 `json:"unit_price"`
+
+	// Embedded on request with ?include=
+	Album     *Album     `json:"album,omitempty"`
+	Genre     *Genre     `json:"genre,omitempty"`
+	MediaType *MediaType `json:"media_type,omitempty"`
+}
+
+// TrackInput is the body of track write requests.
+type TrackInput struct {
+	Name         string  `json:"name" validate:"required,max=200"`
+	AlbumId      *int    `json:"album_id,omitempty" validate:"omitempty,gt=0"`
+	MediaTypeId  int     `json:"media_type_id" validate:"required,gt=0"`
+	GenreId      *int    `json:"genre_id,omitempty" validate:"omitempty,gt=0"`
+	Composer     *string `json:"composer,omitempty" validate:"omitempty,max=220"`
+	Milliseconds int     `json:"milliseconds" validate:"required,gt=0"`
+	Bytes        *int    `json:"bytes,omitempty" validate:"omitempty,gte=0"`
+	UnitPrice    float64 `json:"unit_price" validate:"gte=0,lt=100000000"`
+}
+
+// Track returns the input as the track with the given id.
+func (in TrackInput) Track(id int) Track {
+	return Track{
+		TrackId:      id,
+		Name:         in.Name,
+		AlbumId:      in.AlbumId,
+		MediaTypeId:  in.MediaTypeId,
+		GenreId:      in.GenreId,
+		Composer:     in.Composer,
+		Milliseconds: in.Milliseconds,
+		Bytes:        in.Bytes,
+		UnitPrice:    in.UnitPrice,
+	}
+}
+
+// Input returns the track in the form of an update body, which PATCH
+// requests are applied to.
+func (t Track) Input() TrackInput {
+	return TrackInput{
+		Name:         t.Name,
+		AlbumId:      t.AlbumId,
+		MediaTypeId:  t.MediaTypeId,
+		GenreId:      t.GenreId,
+		Composer:     t.Composer,
+		Milliseconds: t.Milliseconds,
+		Bytes:        t.Bytes,
+		UnitPrice:    t.UnitPrice,
+	}
 }
@@ -5,7 +5,10 @@ type User struct {
 	Username      string `json:"username"`
 	Email         string `json:"email"`
 	Password      string `json:"password,omitempty"`
+	Role          string `json:"role,omitempty"`
 	Authenticated bool   `json:"authenticated,omitempty"`
+	// EmployeeID is the employee the user acts as in reports.
+	EmployeeID *int `json:"employee_id,omitempty"`
 }
 
 type LoginRequest struct {
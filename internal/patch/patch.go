@@ -0,0 +1,390 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON values. Numbers are kept as json.Number, so
+// patching does not change how untouched values are written.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Media types of the two patch formats.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalid is returned for patch documents that are not well formed.
+	ErrInvalid = errors.New("invalid patch")
+	// ErrConflict is returned when an operation cannot be applied to the
+	// document, e.g. because its path does not exist.
+	ErrConflict = errors.New("patch cannot be applied")
+	// ErrTestFailed is returned when a test operation does not match.
+	ErrTestFailed = errors.New("patch test failed")
+)
+
+// Merge applies the merge patch to doc and returns the result.
+func Merge(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = merge(t[key], value)
+		}
+	}
+	return t
+}
+
+// Apply applies the operations of the JSON patch to doc in order and
+// returns the result. When any operation fails, no result is returned.
+func Apply(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	var ops []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: a JSON patch must be an array of operations", ErrInvalid)
+	}
+	for i, raw := range ops {
+		op, err := parseOp(raw)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.name, op.path, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+type operation struct {
+	name  string
+	path  string
+	from  string
+	value any
+}
+
+func parseOp(raw map[string]json.RawMessage) (operation, error) {
+	var op operation
+	if err := json.Unmarshal(raw["op"], &op.name); err != nil {
+		return op, fmt.Errorf("%w: op must be a string", ErrInvalid)
+	}
+	if err := json.Unmarshal(raw["path"], &op.path); err != nil {
+		return op, fmt.Errorf("%w: path must be a string", ErrInvalid)
+	}
+	if _, err := pointer(op.path); err != nil {
+		return op, err
+	}
+	switch op.name {
+	case "add", "replace", "test":
+		value, ok := raw["value"]
+		if !ok {
+			return op, fmt.Errorf("%w: %s requires a value", ErrInvalid, op.name)
+		}
+		v, err := decode(value)
+		if err != nil {
+			return op, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		op.value = v
+	case "move", "copy":
+		if err := json.Unmarshal(raw["from"], &op.from); err != nil {
+			return op, fmt.Errorf("%w: %s requires from", ErrInvalid, op.name)
+		}
+		if _, err := pointer(op.from); err != nil {
+			return op, err
+		}
+	case "remove":
+	default:
+		return op, fmt.Errorf("%w: unknown op %q", ErrInvalid, op.name)
+	}
+	return op, nil
+}
+
+func (op operation) apply(doc any) (any, error) {
+	path, _ := pointer(op.path)
+	switch op.name {
+	case "add":
+		return add(doc, path, op.value)
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return op.value, nil
+		}
+		return update(doc, path, func(parent any, key string) (any, error) {
+			switch p := parent.(type) {
+			case map[string]any:
+				p[key] = op.value
+				return p, nil
+			case []any:
+				i, _ := index(key, len(p))
+				p[i] = op.value
+				return p, nil
+			}
+			return nil, errNotFound
+		})
+	case "move":
+		if op.path != op.from && strings.HasPrefix(op.path, op.from+"/") {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrConflict)
+		}
+		from, _ := pointer(op.from)
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "copy":
+		from, _ := pointer(op.from)
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, clone(value))
+	case "test":
+		value, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(value, op.value) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalid, op.name)
+}
+
+var errNotFound = fmt.Errorf("%w: path does not exist", ErrConflict)
+
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(parent any, key string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			p[key] = value
+			return p, nil
+		case []any:
+			if key == "-" {
+				return append(p, value), nil
+			}
+			i, err := index(key, len(p)+1)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		}
+		return nil, errNotFound
+	})
+}
+
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrConflict)
+	}
+	return update(doc, path, func(parent any, key string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			if _, ok := p[key]; !ok {
+				return nil, errNotFound
+			}
+			delete(p, key)
+			return p, nil
+		case []any:
+			i, err := index(key, len(p))
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return nil, errNotFound
+	})
+}
+
+// update walks to the container holding the last token of path and replaces
+// it with the result of fn, rebuilding the containers above it as needed
+// since appending to an array may move it.
+func update(doc any, path []string, fn func(parent any, key string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	switch d := doc.(type) {
+	case map[string]any:
+		child, ok := d[path[0]]
+		if !ok {
+			return nil, errNotFound
+		}
+		updated, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		d[path[0]] = updated
+		return d, nil
+	case []any:
+		i, err := index(path[0], len(d))
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(d[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		d[i] = updated
+		return d, nil
+	}
+	return nil, errNotFound
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, key := range path {
+		switch d := doc.(type) {
+		case map[string]any:
+			child, ok := d[key]
+			if !ok {
+				return nil, errNotFound
+			}
+			doc = child
+		case []any:
+			i, err := index(key, len(d))
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, errNotFound
+		}
+	}
+	return doc, nil
+}
+
+// pointer splits a JSON pointer (RFC 6901) into its unescaped tokens.
+func pointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("%w: path %q must be empty or start with /", ErrInvalid, s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// index parses an array index below n. Leading zeros are not allowed.
+func index(key string, n int) (int, error) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || (len(key) > 1 && key[0] == '0') {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrConflict, key)
+	}
+	if i >= n {
+		return 0, fmt.Errorf("%w: index %d is out of range", ErrConflict, i)
+	}
+	return i, nil
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, errors.New("more than one JSON value")
+	}
+	return v, nil
+}
+
+func clone(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(t))
+		for k, v := range t {
+			c[k] = clone(v)
+		}
+		return c
+	case []any:
+		c := make([]any, len(t))
+		for i, v := range t {
+			c[i] = clone(v)
+		}
+		return c
+	}
+	return v
+}
+
+// equal compares decoded JSON values, treating numbers by value so that 1
+// and 1.0 are equal.
+func equal(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errx := x.Float64()
+		fy, erry := y.Float64()
+		return errx == nil && erry == nil && fx == fy
+	}
+	return a == b
+}
@@ -0,0 +1,290 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+// sameJSON reports whether a and b are the same JSON value, regardless of
+// key order and number formatting.
+func sameJSON(t *testing.T, a, b string) bool {
+	t.Helper()
+	x, err := decode([]byte(a))
+	if err != nil {
+		t.Fatalf("decoding %s: %v", a, err)
+	}
+	y, err := decode([]byte(b))
+	if err != nil {
+		t.Fatalf("decoding %s: %v", b, err)
+	}
+	return equal(x, y)
+}
+
+// The examples of RFC 7396, appendix A.
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got, err := Merge([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("Merge(%s, %s): %v", tt.doc, tt.patch, err)
+			continue
+		}
+		if !sameJSON(t, string(got), tt.want) {
+			t.Errorf("Merge(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestMergeKeepsNumbers(t *testing.T) {
+	got, err := Merge([]byte(`{"price":0.990,"id":12345678901234567890}`), []byte(`{"name":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"id":12345678901234567890,"name":"x","price":0.990}`; string(got) != want {
+		t.Errorf("Merge = %s, want %s", got, want)
+	}
+}
+
+func TestMergeInvalid(t *testing.T) {
+	if _, err := Merge([]byte(`{}`), []byte(`{"a":`)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("malformed merge patch: err = %v, want ErrInvalid", err)
+	}
+}
+
+// The examples of RFC 6902, appendix A, followed by the edge cases of
+// pointers and arrays that the appendix does not cover. A.13, an operation
+// with two "op" members, is left out: encoding/json keeps the last one.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name             string
+		doc, patch, want string
+		err              error
+	}{
+		{
+			name:  "A.1 adding an object member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:  "A.2 adding an array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "A.3 removing an object member",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			name:  "A.4 removing an array element",
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "A.5 replacing a value",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "A.6 moving a value",
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:  "A.7 moving an array element",
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name: "A.8 testing a value: success",
+			doc:  `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[
+				{"op":"test","path":"/baz","value":"qux"},
+				{"op":"test","path":"/foo/1","value":2}
+			]`,
+			want: `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:  "A.9 testing a value: error",
+			doc:   `{"baz":"qux"}`,
+			patch: `[{"op":"test","path":"/baz","value":"bar"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.10 adding a nested member object",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:  `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:  "A.11 ignoring unrecognized elements",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			want:  `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:  "A.12 adding to a nonexistent target",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "A.14 ~ escape ordering",
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":10}]`,
+			want:  `{"/":9,"~1":10}`,
+		},
+		{
+			name:  "A.15 comparing strings and numbers",
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":"10"}]`,
+			err:   ErrTestFailed,
+		},
+		{
+			name:  "A.16 adding an array value",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:  `{"foo":["bar",["abc","def"]]}`,
+		},
+
+		{
+			name:  "escaped slash",
+			doc:   `{"a/b":1}`,
+			patch: `[{"op":"replace","path":"/a~1b","value":2}]`,
+			want:  `{"a/b":2}`,
+		},
+		{
+			name:  "append to a nested array",
+			doc:   `{"tracks":[{"ids":[1]}]}`,
+			patch: `[{"op":"add","path":"/tracks/0/ids/-","value":2}]`,
+			want:  `{"tracks":[{"ids":[1,2]}]}`,
+		},
+		{
+			name:  "add at the end index",
+			doc:   `[1,2]`,
+			patch: `[{"op":"add","path":"/2","value":3}]`,
+			want:  `[1,2,3]`,
+		},
+		{
+			name:  "add past the end",
+			doc:   `[1,2]`,
+			patch: `[{"op":"add","path":"/3","value":3}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "index with a leading zero",
+			doc:   `[1,2]`,
+			patch: `[{"op":"remove","path":"/01"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "replace the whole document",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"replace","path":"","value":[1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "replace a missing member",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"replace","path":"/b","value":2}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "remove a missing member",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"remove","path":"/b"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "move into its own child",
+			doc:   `{"a":{"b":{}}}`,
+			patch: `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			err:   ErrConflict,
+		},
+		{
+			name:  "move to a sibling sharing a prefix",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"move","from":"/a","path":"/ab"}]`,
+			want:  `{"ab":1}`,
+		},
+		{
+			name:  "move onto itself",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"move","from":"/a","path":"/a"}]`,
+			want:  `{"a":1}`,
+		},
+		{
+			name: "copy is deep",
+			doc:  `{"a":{"b":1}}`,
+			patch: `[
+				{"op":"copy","from":"/a","path":"/c"},
+				{"op":"replace","path":"/c/b","value":2}
+			]`,
+			want: `{"a":{"b":1},"c":{"b":2}}`,
+		},
+		{
+			name:  "test compares numbers by value",
+			doc:   `{"price":0.99}`,
+			patch: `[{"op":"test","path":"/price","value":0.990}]`,
+			want:  `{"price":0.99}`,
+		},
+		{
+			name: "a failed test stops the patch",
+			doc:  `{"a":1}`,
+			patch: `[
+				{"op":"replace","path":"/a","value":2},
+				{"op":"test","path":"/a","value":1}
+			]`,
+			err: ErrTestFailed,
+		},
+
+		{name: "not an array", doc: `{}`, patch: `{"op":"add"}`, err: ErrInvalid},
+		{name: "unknown op", doc: `{}`, patch: `[{"op":"merge","path":"/a"}]`, err: ErrInvalid},
+		{name: "missing value", doc: `{}`, patch: `[{"op":"add","path":"/a"}]`, err: ErrInvalid},
+		{name: "missing from", doc: `{"a":1}`, patch: `[{"op":"move","path":"/b"}]`, err: ErrInvalid},
+		{name: "relative path", doc: `{}`, patch: `[{"op":"add","path":"a","value":1}]`, err: ErrInvalid},
+		{name: "null value", doc: `{}`, patch: `[{"op":"add","path":"/a","value":null}]`, want: `{"a":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Apply = %s, %v; want %v", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !sameJSON(t, string(got), tt.want) {
+				t.Errorf("Apply = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
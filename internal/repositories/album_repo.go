@@ -5,13 +5,18 @@ import (
 	"database/sql"
 	"fmt"
 
+	"chinook-api/internal/apperr"
+
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
+	"chinook-api/internal/search"
 
 	"github.com/rs/zerolog/log"
 )
 
 type AlbumRepository struct {
-    DB *sql.DB
+    DB       *database.DB
+    Watchers search.Watchers
 }
 
 func (r *AlbumRepository) GetAllAlbums(ctx context.Context) ([]models.Album, error) {
@@ -40,7 +45,7 @@ func (r *AlbumRepository) GetAlbumByID(ctx context.Context, id int) (models.Albu
     if err != nil {
         if err == sql.ErrNoRows {
             log.Warn().Int("id", id).Msg("Album not found")
-            return album, fmt.Errorf("album not found")
+            return album, apperr.NotFound("album_not_found", "album not found")
         }
         log.Error().Err(err).Int("id", id).Msg("Database error fetching album")
         return album, fmt.Errorf("database error: %w", err)
@@ -51,33 +56,35 @@ func (r *AlbumRepository) GetAlbumByID(ctx context.Context, id int) (models.Albu
 
 func (r *AlbumRepository) CreateAlbum(ctx context.Context, album models.Album) (int64, error) {
     log.Debug().Msg("Creating album")
-    result, err := r.DB.ExecContext(ctx, "INSERT INTO Album (Title, ArtistId) VALUES (?, ?)", album.Title, album.ArtistID)
+    id, err := r.DB.InsertReturningID(ctx, "AlbumId", "INSERT INTO Album (Title, ArtistId) VALUES (?, ?)", album.Title, album.ArtistID)
     if err != nil {
         log.Error().Err(err).Msg("failed to create album")
         return 0, fmt.Errorf("error creating album: %w", err)
     }
-    id, err := result.LastInsertId()
-    if err != nil {
-        log.Error().Err(err).Msg("failed to get last inserted ID")
-        return 0, fmt.Errorf("error getting last inserted ID: %w", err)
-    }
     log.Debug().Int64("id", id).Msg("Created album")
+    r.Watchers.Upsert(search.Entry{Kind: search.Album, ID: int(id), Name: album.Title})
     return id, nil
 }
 
 func (r *AlbumRepository) UpdateAlbum(ctx context.Context, album models.Album) error {
-    _, err := r.DB.ExecContext(ctx, "UPDATE Album SET Title = ?, ArtistId = ? WHERE AlbumId = ?", album.Title, album.ArtistID, album.ID)
+    result, err := r.DB.ExecContext(ctx, "UPDATE Album SET Title = ?, ArtistId = ? WHERE AlbumId = ?", album.Title, album.ArtistID, album.ID)
     if err != nil {
         log.Error().Err(err).Msg("failed to update album")
         return fmt.Errorf("error updating album: %w", err)
     }
     log.Debug().Int("id", album.ID).Msg("Updated album")
+    if n, err := result.RowsAffected(); err == nil && n > 0 {
+        r.Watchers.Upsert(search.Entry{Kind: search.Album, ID: album.ID, Name: album.Title})
+    }
     return nil
 }
 
 func (r *AlbumRepository) DeleteAlbum(ctx context.Context, id int) error {
     log.Debug().Int("id", id).Msg("Deleting album")
     result, err := r.DB.ExecContext(ctx, "DELETE FROM Album WHERE AlbumId = ?", id)
+    if database.IsForeignKeyViolation(err) {
+        return apperr.Conflict("album_in_use", "album still has tracks")
+    }
     if err != nil {
         log.Error().Err(err).Msg("failed to delete album")
         return fmt.Errorf("error deleting album: %w", err)
@@ -89,8 +96,36 @@ func (r *AlbumRepository) DeleteAlbum(ctx context.Context, id int) error {
     }
     if rowsAffected == 0 {
         log.Warn().Int("id", id).Msg("Album not found")
-        return fmt.Errorf("album not found")
+        return apperr.NotFound("album_not_found", "album not found")
     }
     log.Debug().Int("id", id).Msg("Deleted album")
+    r.Watchers.Remove(search.Album, id)
     return nil
-}
\ No newline at end of file
+}
+
+// GetAlbumsByArtistID returns a page of the artist's albums and their total count
+func (r *AlbumRepository) GetAlbumsByArtistID(ctx context.Context, artistID, limit, offset int) ([]models.Album, int, error) {
+    var total int
+    if err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM Album WHERE ArtistId = ?", artistID).Scan(&total); err != nil {
+        log.Error().Err(err).Int("artist_id", artistID).Msg("failed to count albums for artist")
+        return nil, 0, fmt.Errorf("error counting albums: %w", err)
+    }
+
+    rows, err := r.DB.QueryContext(ctx, "SELECT AlbumId, Title, ArtistId FROM Album WHERE ArtistId = ? ORDER BY AlbumId LIMIT ? OFFSET ?", artistID, limit, offset)
+    if err != nil {
+        log.Error().Err(err).Int("artist_id", artistID).Msg("failed to query albums for artist")
+        return nil, 0, fmt.Errorf("error fetching albums: %w", err)
+    }
+    defer rows.Close()
+
+    albums := []models.Album{}
+    for rows.Next() {
+        var album models.Album
+        if err := rows.Scan(&album.ID, &album.Title, &album.ArtistID); err != nil {
+            log.Error().Err(err).Msg("failed to scan album")
+            return nil, 0, fmt.Errorf("error scanning album: %w", err)
+        }
+        albums = append(albums, album)
+    }
+    return albums, total, rows.Err()
+}
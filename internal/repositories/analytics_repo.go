@@ -0,0 +1,440 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SalesPeriods lists the time buckets sales can be grouped by.
+var SalesPeriods = []string{"day", "week", "month", "quarter", "year"}
+
+// SalesDimensions lists what sales can be broken down by. Country is the
+// invoice's billing country.
+var SalesDimensions = []string{"artist", "album", "track", "genre", "media_type", "country"}
+
+// ErrTooManyPeriods is returned when a time series would have more than
+// MaxSalesPeriods buckets, e.g. daily sales over decades.
+var ErrTooManyPeriods = apperr.BadRequest("too_many_periods", "range has more than %d periods; use a longer period or a shorter range", MaxSalesPeriods)
+
+const (
+	MaxSalesPeriods = 5000
+	// maxAnalyticsCacheEntries bounds the result cache; it is cleared when full.
+	maxAnalyticsCacheEntries = 256
+)
+
+// salesDimensionColumns maps a dimension to its key and label expressions.
+// The empty dimension is a single series over all sales.
+var salesDimensionColumns = map[string][2]string{
+	"":           {"'total'", "'All sales'"},
+	"artist":     {"ar.ArtistId", "COALESCE(ar.Name, 'Unknown')"},
+	"album":      {"al.AlbumId", "COALESCE(al.Title, 'Unknown')"},
+	"track":      {"t.TrackId", "t.Name"},
+	"genre":      {"g.GenreId", "COALESCE(g.Name, 'Unknown')"},
+	"media_type": {"mt.MediaTypeId", "COALESCE(mt.Name, 'Unknown')"},
+	"country":    {"i.BillingCountry", "COALESCE(i.BillingCountry, 'Unknown')"},
+}
+
+// salesBuckets formats an invoice date as its period label. The labels sort
+// chronologically and match salesPeriodLabel.
+var salesBuckets = map[database.Dialect]map[string]string{
+	database.SQLite: {
+		"day":     "strftime('%Y-%m-%d', i.InvoiceDate)",
+		"week":    "date(i.InvoiceDate, 'weekday 0', '-6 days')",
+		"month":   "strftime('%Y-%m', i.InvoiceDate)",
+		"quarter": "strftime('%Y', i.InvoiceDate) || '-Q' || ((CAST(strftime('%m', i.InvoiceDate) AS INTEGER) + 2) / 3)",
+		"year":    "strftime('%Y', i.InvoiceDate)",
+	},
+	database.Postgres: {
+		"day":     "to_char(i.InvoiceDate, 'YYYY-MM-DD')",
+		"week":    "to_char(date_trunc('week', i.InvoiceDate), 'YYYY-MM-DD')",
+		"month":   "to_char(i.InvoiceDate, 'YYYY-MM')",
+		"quarter": "to_char(i.InvoiceDate, 'YYYY-\"Q\"Q')",
+		"year":    "to_char(i.InvoiceDate, 'YYYY')",
+	},
+}
+
+const salesFrom = `
+	FROM InvoiceLine il
+	JOIN Invoice i ON i.InvoiceId = il.InvoiceId
+	JOIN Track t ON t.TrackId = il.TrackId
+	LEFT JOIN Album al ON al.AlbumId = t.AlbumId
+	LEFT JOIN Artist ar ON ar.ArtistId = al.ArtistId
+	LEFT JOIN Genre g ON g.GenreId = t.GenreId
+	LEFT JOIN MediaType mt ON mt.MediaTypeId = t.MediaTypeId`
+
+// SalesFilter selects and groups the invoice lines behind a sales report.
+// From and To are inclusive dates and are ignored when zero; Countries
+// match billing countries case-insensitively.
+type SalesFilter struct {
+	Period    string
+	Dimension string
+	From      time.Time
+	To        time.Time
+	Countries []string
+	Top       int
+}
+
+func (f SalesFilter) where() (string, []any) {
+	conds, args := f.conditions()
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// conditions returns the filter as SQL conditions over the invoice alias i.
+func (f SalesFilter) conditions() (conds []string, args []any) {
+	if !f.From.IsZero() {
+		conds = append(conds, "i.InvoiceDate >= ?")
+		args = append(args, f.From.Format(time.DateOnly))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "i.InvoiceDate < ?")
+		args = append(args, f.To.AddDate(0, 0, 1).Format(time.DateOnly))
+	}
+	if len(f.Countries) > 0 {
+		conds = append(conds, "LOWER(i.BillingCountry) IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(f.Countries)), ", ")+")")
+		for _, country := range f.Countries {
+			args = append(args, strings.ToLower(country))
+		}
+	}
+	return conds, args
+}
+
+// Dates returns From and To formatted as YYYY-MM-DD, empty when unset.
+func (f SalesFilter) Dates() (from, to string) {
+	if !f.From.IsZero() {
+		from = f.From.Format(time.DateOnly)
+	}
+	if !f.To.IsZero() {
+		to = f.To.Format(time.DateOnly)
+	}
+	return from, to
+}
+
+// AnalyticsRepository aggregates sales from invoice lines. Results are cached
+// until the next invoice write, which it detects through the "invoice"
+// counter in DataVersion that triggers bump on every change to Invoice or
+// InvoiceLine.
+type AnalyticsRepository struct {
+	DB *database.DB
+
+	mu      sync.Mutex
+	version int64
+	cache   map[string]any
+}
+
+// invoiceVersion returns the current invoice change counter, or false when
+// it cannot be read, e.g. because migrations have not been applied.
+func (r *AnalyticsRepository) invoiceVersion(ctx context.Context) (int64, bool) {
+	var version int64
+	err := r.DB.QueryRowContext(ctx, "SELECT Version FROM DataVersion WHERE Name = 'invoice'").Scan(&version)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to read invoice data version, analytics cache bypassed")
+		return 0, false
+	}
+	return version, true
+}
+
+// cachedSales returns the cached result for key, or computes and caches it.
+// The boolean reports a cache hit.
+func cachedSales[T any](ctx context.Context, r *AnalyticsRepository, key string, compute func() (T, error)) (T, bool, error) {
+	version, ok := r.invoiceVersion(ctx)
+	if !ok {
+		v, err := compute()
+		return v, false, err
+	}
+
+	r.mu.Lock()
+	if r.cache == nil || r.version != version {
+		r.cache, r.version = map[string]any{}, version
+	}
+	if v, ok := r.cache[key].(T); ok {
+		r.mu.Unlock()
+		return v, true, nil
+	}
+	r.mu.Unlock()
+
+	v, err := compute()
+	if err != nil {
+		return v, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.version == version {
+		if len(r.cache) >= maxAnalyticsCacheEntries {
+			r.cache = map[string]any{}
+		}
+		r.cache[key] = v
+	}
+	return v, false, nil
+}
+
+// SalesTimeSeries returns revenue, units and invoice counts per period, as
+// one series per top f.Top dimension values by revenue, or as a single
+// series when f.Dimension is empty. Periods without sales are filled with
+// zeros between From (or the first sale) and To (or the last sale).
+func (r *AnalyticsRepository) SalesTimeSeries(ctx context.Context, f SalesFilter) (models.SalesTimeSeries, bool, error) {
+	key := fmt.Sprintf("series|%s|%s|%s|%s|%s|%d", f.Period, f.Dimension, f.From, f.To, strings.Join(f.Countries, ","), f.Top)
+	return cachedSales(ctx, r, key, func() (models.SalesTimeSeries, error) {
+		return r.salesTimeSeries(ctx, f)
+	})
+}
+
+func (r *AnalyticsRepository) salesTimeSeries(ctx context.Context, f SalesFilter) (models.SalesTimeSeries, error) {
+	from, to := f.Dates()
+	result := models.SalesTimeSeries{
+		Period:    f.Period,
+		Dimension: f.Dimension,
+		From:      from,
+		To:        to,
+		Countries: f.Countries,
+		Labels:    []string{},
+		Series:    []models.SalesSeries{},
+	}
+
+	labels, err := r.salesLabels(ctx, f)
+	if err != nil {
+		return result, err
+	}
+	if len(labels) == 0 {
+		return result, nil
+	}
+	result.Labels = labels
+	position := make(map[string]int, len(labels))
+	for i, label := range labels {
+		position[label] = i
+	}
+
+	cols := salesDimensionColumns[f.Dimension]
+	where, args := f.where()
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT `+cols[0]+`, `+cols[1]+`, `+salesBuckets[r.DB.Dialect][f.Period]+`,
+		       SUM(il.UnitPrice * il.Quantity), SUM(il.Quantity), COUNT(DISTINCT i.InvoiceId)
+		`+salesFrom+where+`
+		GROUP BY 1, 2, 3`, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query sales time series")
+		return result, fmt.Errorf("error fetching sales: %w", err)
+	}
+	defer rows.Close()
+
+	series := map[string]*models.SalesSeries{}
+	for rows.Next() {
+		var (
+			key             *string
+			label, bucket   string
+			revenue         float64
+			units, invoices int
+		)
+		if err := rows.Scan(&key, &label, &bucket, &revenue, &units, &invoices); err != nil {
+			log.Error().Err(err).Msg("failed to scan sales")
+			return result, fmt.Errorf("error scanning sales: %w", err)
+		}
+		i, ok := position[bucket]
+		if !ok {
+			continue
+		}
+		k := ""
+		if key != nil {
+			k = *key
+		}
+		s, ok := series[k]
+		if !ok {
+			s = &models.SalesSeries{
+				Key:      k,
+				Label:    label,
+				Revenue:  make([]float64, len(labels)),
+				Units:    make([]int, len(labels)),
+				Invoices: make([]int, len(labels)),
+			}
+			series[k] = s
+		}
+		s.Revenue[i] = roundCents(s.Revenue[i] + revenue)
+		s.Units[i] += units
+		s.Invoices[i] += invoices
+		s.TotalRevenue += revenue
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over sales")
+		return result, fmt.Errorf("error iterating over sales: %w", err)
+	}
+
+	for _, s := range series {
+		s.TotalRevenue = roundCents(s.TotalRevenue)
+		result.Series = append(result.Series, *s)
+	}
+	sort.Slice(result.Series, func(i, j int) bool {
+		a, b := result.Series[i], result.Series[j]
+		if a.TotalRevenue != b.TotalRevenue {
+			return a.TotalRevenue > b.TotalRevenue
+		}
+		return a.Label < b.Label
+	})
+	if f.Dimension != "" && len(result.Series) > f.Top {
+		result.Series = result.Series[:f.Top]
+	}
+	return result, nil
+}
+
+// salesLabels returns every period label from the start to the end of the
+// filtered range, defaulting to the first and last sale.
+func (r *AnalyticsRepository) salesLabels(ctx context.Context, f SalesFilter) ([]string, error) {
+	start, end := f.From, f.To
+	if start.IsZero() || end.IsZero() {
+		day := salesBuckets[r.DB.Dialect]["day"]
+		where, args := f.where()
+		var first, last *string
+		err := r.DB.QueryRowContext(ctx, `
+			SELECT MIN(`+day+`), MAX(`+day+`) FROM Invoice i`+where, args...).Scan(&first, &last)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to query sales date range")
+			return nil, fmt.Errorf("error fetching sales date range: %w", err)
+		}
+		if first == nil || last == nil {
+			return nil, nil
+		}
+		if start.IsZero() {
+			if start, err = time.Parse(time.DateOnly, *first); err != nil {
+				return nil, fmt.Errorf("error parsing sales date range: %w", err)
+			}
+		}
+		if end.IsZero() {
+			if end, err = time.Parse(time.DateOnly, *last); err != nil {
+				return nil, fmt.Errorf("error parsing sales date range: %w", err)
+			}
+		}
+	}
+
+	var labels []string
+	for t := salesPeriodStart(f.Period, start); !t.After(end); t = salesPeriodNext(f.Period, t) {
+		if len(labels) == MaxSalesPeriods {
+			return nil, ErrTooManyPeriods
+		}
+		labels = append(labels, salesPeriodLabel(f.Period, t))
+	}
+	return labels, nil
+}
+
+// SalesBreakdown returns the top f.Top dimension values by revenue together
+// with the totals of the whole filtered range.
+func (r *AnalyticsRepository) SalesBreakdown(ctx context.Context, f SalesFilter) (models.SalesBreakdown, bool, error) {
+	key := fmt.Sprintf("breakdown|%s|%s|%s|%s|%d", f.Dimension, f.From, f.To, strings.Join(f.Countries, ","), f.Top)
+	return cachedSales(ctx, r, key, func() (models.SalesBreakdown, error) {
+		return r.salesBreakdown(ctx, f)
+	})
+}
+
+func (r *AnalyticsRepository) salesBreakdown(ctx context.Context, f SalesFilter) (models.SalesBreakdown, error) {
+	from, to := f.Dates()
+	result := models.SalesBreakdown{
+		Dimension: f.Dimension,
+		From:      from,
+		To:        to,
+		Countries: f.Countries,
+		Rows:      []models.SalesBreakdownRow{},
+	}
+
+	where, args := f.where()
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(il.UnitPrice * il.Quantity), 0), COALESCE(SUM(il.Quantity), 0), COUNT(DISTINCT i.InvoiceId)
+		`+salesFrom+where, args...).Scan(&result.TotalRevenue, &result.TotalUnits, &result.TotalInvoices)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query sales totals")
+		return result, fmt.Errorf("error fetching sales totals: %w", err)
+	}
+	result.TotalRevenue = roundCents(result.TotalRevenue)
+
+	cols := salesDimensionColumns[f.Dimension]
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT `+cols[0]+`, `+cols[1]+`,
+		       SUM(il.UnitPrice * il.Quantity), SUM(il.Quantity), COUNT(DISTINCT i.InvoiceId)
+		`+salesFrom+where+`
+		GROUP BY 1, 2
+		ORDER BY 3 DESC, 2
+		LIMIT ?`, append(args, f.Top)...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query sales breakdown")
+		return result, fmt.Errorf("error fetching sales breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.SalesBreakdownRow
+		var key *string
+		if err := rows.Scan(&key, &row.Label, &row.Revenue, &row.Units, &row.Invoices); err != nil {
+			log.Error().Err(err).Msg("failed to scan sales breakdown")
+			return result, fmt.Errorf("error scanning sales breakdown: %w", err)
+		}
+		if key != nil {
+			row.Key = *key
+		}
+		if result.TotalRevenue > 0 {
+			row.Share = math.Round(row.Revenue/result.TotalRevenue*10000) / 10000
+		}
+		row.Revenue = roundCents(row.Revenue)
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over sales breakdown")
+		return result, fmt.Errorf("error iterating over sales breakdown: %w", err)
+	}
+	return result, nil
+}
+
+func salesPeriodStart(period string, t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch period {
+	case "week":
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func salesPeriodNext(period string, t time.Time) time.Time {
+	switch period {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	case "quarter":
+		return t.AddDate(0, 3, 0)
+	case "year":
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+func salesPeriodLabel(period string, t time.Time) string {
+	switch period {
+	case "month":
+		return t.Format("2006-01")
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())+2)/3)
+	case "year":
+		return t.Format("2006")
+	}
+	return t.Format(time.DateOnly)
+}
+
+func roundCents(v float64) float64 {
+	return fromCents(toCents(v))
+}
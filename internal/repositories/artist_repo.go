@@ -5,13 +5,18 @@ import (
 	"database/sql"
 	"fmt"
 
+	"chinook-api/internal/apperr"
+
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
+	"chinook-api/internal/search"
 
 	"github.com/rs/zerolog/log"
 )
 
 type ArtistRepository struct {
-    DB *sql.DB
+    DB       *database.DB
+    Watchers search.Watchers
 }
 
 // GetArtistsPaginated returns a paginated list of artists and the total count
@@ -71,7 +76,7 @@ func (r *ArtistRepository) GetArtistByID(ctx context.Context, id int) (models.Ar
     if err != nil {
         if err == sql.ErrNoRows {
             log.Warn().Int("id", id).Msg("Artist not found")
-            return artist, fmt.Errorf("artist not found")
+            return artist, apperr.NotFound("artist_not_found", "artist not found")
         }
         log.Error().Err(err).Int("id", id).Msg("Database error fetching artist")
         return artist, fmt.Errorf("database error: %w", err)
@@ -83,47 +88,59 @@ func (r *ArtistRepository) GetArtistByID(ctx context.Context, id int) (models.Ar
 
 func (r *ArtistRepository) CreateArtist(ctx context.Context, artist models.Artist) (int64, error) {
     log.Debug().Str("name", artist.Name).Msg("Creating artist")
-    result, err := r.DB.ExecContext(ctx, "INSERT INTO Artist (Name) VALUES (?)", artist.Name)
+    id, err := r.DB.InsertReturningID(ctx, "ArtistId", "INSERT INTO Artist (Name) VALUES (?)", artist.Name)
     if err != nil {
         log.Error().Err(err).Str("name", artist.Name).Msg("Error creating artist")
         return 0, fmt.Errorf("error creating artist: %w", err)
     }
 
-    id, err := result.LastInsertId()
-    if err != nil {
-        log.Error().Err(err).Msg("Error getting last insert id")
-        return 0, fmt.Errorf("error getting last insert id: %w", err)
-    }
-
     log.Info().Int64("id", id).Str("name", artist.Name).Msg("Artist created")
+    r.Watchers.Upsert(search.Entry{Kind: search.Artist, ID: int(id), Name: artist.Name})
     return id, nil
 }
 
 func (r *ArtistRepository) UpdateArtist(ctx context.Context, artist models.Artist) error {
     log.Debug().Int("id", artist.ID).Str("name", artist.Name).Msg("Updating artist")
-    _, err := r.DB.ExecContext(ctx, "UPDATE Artist SET Name = ? WHERE ArtistId = ?", artist.Name, artist.ID)
+    result, err := r.DB.ExecContext(ctx, "UPDATE Artist SET Name = ? WHERE ArtistId = ?", artist.Name, artist.ID)
     if err != nil {
         log.Error().Err(err).Int("id", artist.ID).Msg("Error updating artist")
         return fmt.Errorf("error updating artist: %w", err)
     }
     log.Info().Int("id", artist.ID).Msg("Artist updated")
+    if n, err := result.RowsAffected(); err == nil && n > 0 {
+        r.Watchers.Upsert(search.Entry{Kind: search.Artist, ID: artist.ID, Name: artist.Name})
+    }
     return nil
 }
 
 func (r *ArtistRepository) DeleteArtist(ctx context.Context, id int) error {
     log.Debug().Int("id", id).Msg("Deleting artist")
-    _, err := r.DB.ExecContext(ctx, "DELETE FROM Artist WHERE ArtistId = ?", id)
+    result, err := r.DB.ExecContext(ctx, "DELETE FROM Artist WHERE ArtistId = ?", id)
+    if database.IsForeignKeyViolation(err) {
+        return apperr.Conflict("artist_in_use", "artist still has albums")
+    }
     if err != nil {
         log.Error().Err(err).Int("id", id).Msg("Error deleting artist")
         return fmt.Errorf("error deleting artist: %w", err)
     }
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        log.Error().Err(err).Int("id", id).Msg("Error getting rows affected")
+        return fmt.Errorf("error getting rows affected: %w", err)
+    }
+    if rowsAffected == 0 {
+        log.Warn().Int("id", id).Msg("Artist not found")
+        return apperr.NotFound("artist_not_found", "artist not found")
+    }
     log.Info().Int("id", id).Msg("Artist deleted")
+    r.Watchers.Remove(search.Artist, id)
     return nil
 }
 
 // SearchArtistsByName returns artists whose names match the search term (case-insensitive, partial match)
 func (r *ArtistRepository) SearchArtistsByName(ctx context.Context, name string) ([]models.Artist, error) {
-    rows, err := r.DB.QueryContext(ctx, "SELECT ArtistId, Name FROM Artist WHERE Name LIKE ?", "%"+name+"%")
+    // LIKE is case-sensitive on Postgres, so compare lowercased on both backends.
+    rows, err := r.DB.QueryContext(ctx, "SELECT ArtistId, Name FROM Artist WHERE LOWER(Name) LIKE LOWER(?)", "%"+name+"%")
     if err != nil {
         log.Error().Err(err).Str("name", name).Msg("Error searching artists by name")
         return nil, fmt.Errorf("error searching artists: %w", err)
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/database/dbtest"
+	"chinook-api/internal/models"
+)
+
+func TestArtistRepository(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		repo := &ArtistRepository{DB: db}
+
+		id, err := repo.CreateArtist(ctx, models.Artist{Name: "Black Sabbath"})
+		if err != nil {
+			t.Fatalf("CreateArtist: %v", err)
+		}
+		if id <= 3 {
+			t.Errorf("created id = %d, want after the seeded artists", id)
+		}
+
+		if err := repo.UpdateArtist(ctx, models.Artist{ID: int(id), Name: "Black Sabbath (UK)"}); err != nil {
+			t.Fatalf("UpdateArtist: %v", err)
+		}
+		artist, err := repo.GetArtistByID(ctx, int(id))
+		if err != nil || artist.Name != "Black Sabbath (UK)" {
+			t.Fatalf("GetArtistByID = %+v, %v", artist, err)
+		}
+
+		page, total, err := repo.GetArtistsPaginated(ctx, 2, 2)
+		if err != nil || total != 4 || len(page) != 2 {
+			t.Fatalf("GetArtistsPaginated(2, 2) = %d artists of %d, %v; want 2 of 4", len(page), total, err)
+		}
+
+		// case-insensitive on both backends
+		found, err := repo.SearchArtistsByName(ctx, "SABBATH")
+		if err != nil || len(found) != 1 || found[0].ID != int(id) {
+			t.Fatalf("SearchArtistsByName = %+v, %v", found, err)
+		}
+
+		if err := repo.DeleteArtist(ctx, int(id)); err != nil {
+			t.Fatalf("DeleteArtist: %v", err)
+		}
+		if _, err := repo.GetArtistByID(ctx, int(id)); !apperr.IsNotFound(err) {
+			t.Errorf("GetArtistByID after delete: err = %v, want not found", err)
+		}
+	})
+}
+
+func TestBulkArtists(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		repo := &ArtistRepository{DB: db}
+
+		ops := []BulkOp[models.Artist]{
+			{Op: BulkCreate, Data: models.Artist{Name: "Queen"}},
+			{Op: BulkDelete, ID: 1}, // has albums
+			{Op: BulkUpdate, ID: 3, Data: models.Artist{Name: "Aerosmith!"}},
+		}
+
+		results, err := repo.BulkArtists(ctx, true, ops)
+		if err != nil {
+			t.Fatalf("atomic BulkArtists: %v", err)
+		}
+		if e, ok := apperr.As(results[1].Err); !ok || e.Code != "artist_in_use" {
+			t.Errorf("deleting an artist with albums: err = %v, want artist_in_use", results[1].Err)
+		}
+		if artist, _ := repo.GetArtistByID(ctx, 3); artist.Name != "Aerosmith" {
+			t.Errorf("atomic batch with a failure changed artist 3 to %q", artist.Name)
+		}
+
+		results, err = repo.BulkArtists(ctx, false, ops)
+		if err != nil {
+			t.Fatalf("best effort BulkArtists: %v", err)
+		}
+		if results[0].Err != nil || results[2].Err != nil || results[1].Err == nil {
+			t.Errorf("best effort results = %+v, want only the delete to fail", results)
+		}
+		if artist, _ := repo.GetArtistByID(ctx, 3); artist.Name != "Aerosmith!" {
+			t.Errorf("best effort batch left artist 3 as %q", artist.Name)
+		}
+	})
+}
+
+func TestDeleteReferenced(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		artists := &ArtistRepository{DB: db}
+		albums := &AlbumRepository{DB: db}
+
+		if e, ok := apperr.As(artists.DeleteArtist(ctx, 1)); !ok || e.Code != "artist_in_use" {
+			t.Errorf("deleting an artist with albums: err = %v, want artist_in_use", e)
+		}
+		if e, ok := apperr.As(albums.DeleteAlbum(ctx, 1)); !ok || e.Code != "album_in_use" {
+			t.Errorf("deleting an album with tracks: err = %v, want album_in_use", e)
+		}
+		if err := artists.DeleteArtist(ctx, 99); !apperr.IsNotFound(err) {
+			t.Errorf("DeleteArtist(99): err = %v, want not found", err)
+		}
+		if err := artists.DeleteArtist(ctx, 3); err != nil {
+			t.Errorf("deleting an artist without albums: %v", err)
+		}
+	})
+}
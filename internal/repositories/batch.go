@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+)
+
+// Batcher loads the rows of many keys at once, with one IN query per batch
+// of keys, for callers that gather the keys before fetching, like the
+// GraphQL dataloaders. The ...By methods group rows under their parent key;
+// parents without rows are missing from the result.
+type Batcher struct {
+	DB *database.DB
+}
+
+func (b *Batcher) Artists(ctx context.Context, ids []int) (map[int]*models.Artist, error) {
+	artists, err := fetchIn(ctx, b.DB, "SELECT ArtistId, Name FROM Artist WHERE ArtistId IN (%s)", ids, scanArtist)
+	return indexBy(artists, func(a models.Artist) int { return a.ID }), err
+}
+
+func (b *Batcher) Albums(ctx context.Context, ids []int) (map[int]*models.Album, error) {
+	albums, err := fetchIn(ctx, b.DB, "SELECT AlbumId, Title, ArtistId FROM Album WHERE AlbumId IN (%s)", ids, scanAlbum)
+	return indexBy(albums, func(a models.Album) int { return a.ID }), err
+}
+
+func (b *Batcher) AlbumsByArtist(ctx context.Context, artistIDs []int) (map[int][]models.Album, error) {
+	albums, err := fetchIn(ctx, b.DB, `
+		SELECT AlbumId, Title, ArtistId FROM Album WHERE ArtistId IN (%s) ORDER BY AlbumId`, artistIDs, scanAlbum)
+	return groupBy(albums, func(a models.Album) int { return a.ArtistID }), err
+}
+
+func (b *Batcher) Tracks(ctx context.Context, ids []int) (map[int]*models.Track, error) {
+	tracks, err := fetchIn(ctx, b.DB, `
+		SELECT TrackId, Name, AlbumId, MediaTypeId, GenreId, Composer, Milliseconds, Bytes, UnitPrice
+		FROM Track WHERE TrackId IN (%s)`, ids, scanTrack)
+	return indexBy(tracks, func(t models.Track) int { return t.TrackId }), err
+}
+
+func (b *Batcher) TracksByAlbum(ctx context.Context, albumIDs []int) (map[int][]models.Track, error) {
+	tracks, err := fetchIn(ctx, b.DB, `
+		SELECT TrackId, Name, AlbumId, MediaTypeId, GenreId, Composer, Milliseconds, Bytes, UnitPrice
+		FROM Track WHERE AlbumId IN (%s) ORDER BY TrackId`, albumIDs, scanTrack)
+	return groupBy(tracks, func(t models.Track) int { return *t.AlbumId }), err
+}
+
+func (b *Batcher) Genres(ctx context.Context, ids []int) (map[int]*models.Genre, error) {
+	genres, err := fetchIn(ctx, b.DB, "SELECT GenreId, Name FROM Genre WHERE GenreId IN (%s)", ids, scanGenre)
+	return indexBy(genres, func(g models.Genre) int { return g.GenreId }), err
+}
+
+func (b *Batcher) MediaTypes(ctx context.Context, ids []int) (map[int]*models.MediaType, error) {
+	mediaTypes, err := fetchIn(ctx, b.DB, "SELECT MediaTypeId, Name FROM MediaType WHERE MediaTypeId IN (%s)", ids, scanMediaType)
+	return indexBy(mediaTypes, func(m models.MediaType) int { return m.MediaTypeId }), err
+}
+
+func (b *Batcher) Customers(ctx context.Context, ids []int) (map[int]*models.Customer, error) {
+	customers, err := fetchIn(ctx, b.DB, `
+		SELECT CustomerId, FirstName, LastName, Company, Address, City, State, Country,
+		       PostalCode, Phone, Fax, Email, SupportRepId
+		FROM Customer WHERE CustomerId IN (%s)`, ids, scanCustomer)
+	return indexBy(customers, func(c models.Customer) int { return c.CustomerId }), err
+}
+
+func (b *Batcher) CustomersBySupportRep(ctx context.Context, employeeIDs []int) (map[int][]models.Customer, error) {
+	customers, err := fetchIn(ctx, b.DB, `
+		SELECT CustomerId, FirstName, LastName, Company, Address, City, State, Country,
+		       PostalCode, Phone, Fax, Email, SupportRepId
+		FROM Customer WHERE SupportRepId IN (%s) ORDER BY CustomerId`, employeeIDs, scanCustomer)
+	return groupBy(customers, func(c models.Customer) int { return *c.SupportRepId }), err
+}
+
+func (b *Batcher) Employees(ctx context.Context, ids []int) (map[int]*models.Employee, error) {
+	employees, err := fetchIn(ctx, b.DB, `
+		SELECT EmployeeId, LastName, FirstName, Title, ReportsTo, BirthDate, HireDate,
+		       Address, City, State, Country, PostalCode, Phone, Fax, Email
+		FROM Employee WHERE EmployeeId IN (%s)`, ids, scanEmployee)
+	return indexBy(employees, func(e models.Employee) int { return e.EmployeeId }), err
+}
+
+func (b *Batcher) Invoices(ctx context.Context, ids []int) (map[int]*models.Invoice, error) {
+	invoices, err := fetchIn(ctx, b.DB, `
+		SELECT InvoiceId, CustomerId, InvoiceDate, BillingAddress, BillingCity,
+		       BillingState, BillingCountry, BillingPostalCode, Total
+		FROM Invoice WHERE InvoiceId IN (%s)`, ids, scanInvoice)
+	return indexBy(invoices, func(inv models.Invoice) int { return inv.InvoiceId }), err
+}
+
+func (b *Batcher) InvoicesByCustomer(ctx context.Context, customerIDs []int) (map[int][]models.Invoice, error) {
+	invoices, err := fetchIn(ctx, b.DB, `
+		SELECT InvoiceId, CustomerId, InvoiceDate, BillingAddress, BillingCity,
+		       BillingState, BillingCountry, BillingPostalCode, Total
+		FROM Invoice WHERE CustomerId IN (%s) ORDER BY InvoiceId`, customerIDs, scanInvoice)
+	return groupBy(invoices, func(inv models.Invoice) int { return inv.CustomerId }), err
+}
+
+func (b *Batcher) InvoiceLinesByInvoice(ctx context.Context, invoiceIDs []int) (map[int][]models.InvoiceLine, error) {
+	lines, err := fetchIn(ctx, b.DB, `
+		SELECT InvoiceLineId, InvoiceId, TrackId, UnitPrice, Quantity
+		FROM InvoiceLine WHERE InvoiceId IN (%s) ORDER BY InvoiceLineId`, invoiceIDs, scanInvoiceLine)
+	return groupBy(lines, func(l models.InvoiceLine) int { return l.InvoiceId }), err
+}
+
+func scanEmployee(rows *sql.Rows) (models.Employee, error) {
+	var e models.Employee
+	err := rows.Scan(&e.EmployeeId, &e.LastName, &e.FirstName, &e.Title, &e.ReportsTo, &e.BirthDate,
+		&e.HireDate, &e.Address, &e.City, &e.State, &e.Country, &e.PostalCode, &e.Phone, &e.Fax, &e.Email)
+	return e, err
+}
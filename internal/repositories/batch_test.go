@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/database/dbtest"
+)
+
+func TestBatcher(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		b := &Batcher{DB: db}
+
+		artists, err := b.Artists(ctx, []int{1, 2, 1, 99})
+		if err != nil {
+			t.Fatalf("Artists: %v", err)
+		}
+		if len(artists) != 2 || artists[1].Name != "AC/DC" || artists[99] != nil {
+			t.Errorf("Artists(1, 2, 1, 99) = %v, want 1 and 2 only", artists)
+		}
+
+		albums, err := b.AlbumsByArtist(ctx, []int{1, 2, 3})
+		if err != nil {
+			t.Fatalf("AlbumsByArtist: %v", err)
+		}
+		if len(albums[1]) != 2 || len(albums[2]) != 1 || albums[3] != nil {
+			t.Errorf("AlbumsByArtist = %v, want 2, 1 and none", albums)
+		}
+
+		lines, err := b.InvoiceLinesByInvoice(ctx, []int{1})
+		if err != nil || len(lines[1]) != 2 || lines[1][0].InvoiceLineId != 1 {
+			t.Errorf("InvoiceLinesByInvoice(1) = %v, %v; want lines 1 and 2", lines, err)
+		}
+
+		customers, err := b.CustomersBySupportRep(ctx, []int{1})
+		if err != nil || len(customers[1]) != 1 {
+			t.Errorf("CustomersBySupportRep(1) = %v, %v; want customer 1", customers, err)
+		}
+
+		invoices, err := b.Invoices(ctx, []int{1})
+		if err != nil || invoices[1] == nil || invoices[1].InvoiceDate.Year() != 2024 {
+			t.Errorf("Invoices(1) = %v, %v", invoices, err)
+		}
+
+		if none, err := b.Tracks(ctx, nil); err != nil || len(none) != 0 {
+			t.Errorf("Tracks(nil) = %v, %v; want nothing", none, err)
+		}
+	})
+}
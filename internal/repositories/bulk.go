@@ -0,0 +1,319 @@
+package repositories
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+	"chinook-api/internal/search"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Bulk operation names.
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkOp is one operation of a bulk write. Data is ignored for deletes.
+type BulkOp[T any] struct {
+	Op   string
+	ID   int
+	Data T
+}
+
+// BulkResult is the outcome of one operation: the id of the row it wrote,
+// or the reason it failed.
+type BulkResult struct {
+	ID  int
+	Err error
+}
+
+// ErrBulkNotApplied is the result of the operations of an atomic batch that
+// were rolled back, or never run, because another operation failed.
+var ErrBulkNotApplied = apperr.New(apperr.KindFailedDependency, "not_applied", "not applied because another operation in the batch failed")
+
+// bulkStep applies one operation inside the batch transaction. It returns
+// the id of the row written and a function that updates the search indexes
+// once the batch is committed.
+type bulkStep[T any] func(ctx context.Context, tx *database.Tx, op BulkOp[T]) (int, func(), error)
+
+// runBulk applies ops in order in a single transaction. In atomic mode the
+// first failure rolls back the whole batch; otherwise every operation runs
+// in its own savepoint, so a failed one is undone without affecting the
+// others. The returned error is set only when the batch as a whole could not
+// be run or committed.
+func runBulk[T any](ctx context.Context, db *database.DB, atomic bool, ops []BulkOp[T], step bulkStep[T]) ([]BulkResult, error) {
+	results := make([]BulkResult, len(ops))
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to begin bulk transaction")
+		return nil, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var notify []func()
+	for i, op := range ops {
+		if !atomic {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT bulk_op"); err != nil {
+				log.Error().Err(err).Msg("failed to create savepoint")
+				return nil, fmt.Errorf("error creating savepoint: %w", err)
+			}
+		}
+		id, after, err := step(ctx, tx, op)
+		if err != nil {
+			if _, ok := apperr.As(err); !ok {
+				log.Error().Err(err).Int("index", i).Str("op", op.Op).Msg("bulk operation failed")
+			}
+			if atomic {
+				for j := range results {
+					results[j] = BulkResult{Err: ErrBulkNotApplied}
+				}
+				results[i].Err = err
+				return results, nil
+			}
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT bulk_op"); err != nil {
+				log.Error().Err(err).Msg("failed to roll back savepoint")
+				return nil, fmt.Errorf("error rolling back savepoint: %w", err)
+			}
+			results[i].Err = err
+		} else {
+			results[i].ID = id
+			notify = append(notify, after)
+		}
+		if !atomic {
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT bulk_op"); err != nil {
+				log.Error().Err(err).Msg("failed to release savepoint")
+				return nil, fmt.Errorf("error releasing savepoint: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit bulk transaction")
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	for _, fn := range notify {
+		fn()
+	}
+	return results, nil
+}
+
+// exists reports whether table has a row whose column equals id.
+func exists(ctx context.Context, tx *database.Tx, table, column string, id int) (bool, error) {
+	var one int
+	err := tx.QueryRowContext(ctx, "SELECT 1 FROM "+table+" WHERE "+column+" = ?", id).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error checking %s: %w", table, err)
+	}
+	return true, nil
+}
+
+// affected turns an UPDATE or DELETE that matched no row into notFound.
+func affected(result sql.Result, notFound error) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+var errUnknownBulkOp = apperr.Field("op", "must be one of create update delete")
+
+// BulkArtists applies the artist operations as one batch.
+func (r *ArtistRepository) BulkArtists(ctx context.Context, atomic bool, ops []BulkOp[models.Artist]) ([]BulkResult, error) {
+	notFound := apperr.NotFound("artist_not_found", "artist not found")
+	return runBulk(ctx, r.DB, atomic, ops, func(ctx context.Context, tx *database.Tx, op BulkOp[models.Artist]) (int, func(), error) {
+		artist := op.Data
+		switch op.Op {
+		case BulkCreate:
+			id, err := tx.InsertReturningID(ctx, "ArtistId", "INSERT INTO Artist (Name) VALUES (?)", artist.Name)
+			if err != nil {
+				return 0, nil, fmt.Errorf("error creating artist: %w", err)
+			}
+			artist.ID = int(id)
+		case BulkUpdate:
+			artist.ID = op.ID
+			result, err := tx.ExecContext(ctx, "UPDATE Artist SET Name = ? WHERE ArtistId = ?", artist.Name, artist.ID)
+			if err != nil {
+				return 0, nil, fmt.Errorf("error updating artist: %w", err)
+			}
+			if err := affected(result, notFound); err != nil {
+				return 0, nil, err
+			}
+		case BulkDelete:
+			result, err := tx.ExecContext(ctx, "DELETE FROM Artist WHERE ArtistId = ?", op.ID)
+			if database.IsForeignKeyViolation(err) {
+				return 0, nil, apperr.Conflict("artist_in_use", "artist still has albums")
+			}
+			if err != nil {
+				return 0, nil, fmt.Errorf("error deleting artist: %w", err)
+			}
+			if err := affected(result, notFound); err != nil {
+				return 0, nil, err
+			}
+			return op.ID, func() { r.Watchers.Remove(search.Artist, op.ID) }, nil
+		default:
+			return 0, nil, errUnknownBulkOp
+		}
+		return artist.ID, func() {
+			r.Watchers.Upsert(search.Entry{Kind: search.Artist, ID: artist.ID, Name: artist.Name})
+		}, nil
+	})
+}
+
+// BulkAlbums applies the album operations as one batch.
+func (r *AlbumRepository) BulkAlbums(ctx context.Context, atomic bool, ops []BulkOp[models.Album]) ([]BulkResult, error) {
+	notFound := apperr.NotFound("album_not_found", "album not found")
+	return runBulk(ctx, r.DB, atomic, ops, func(ctx context.Context, tx *database.Tx, op BulkOp[models.Album]) (int, func(), error) {
+		album := op.Data
+		if op.Op == BulkCreate || op.Op == BulkUpdate {
+			ok, err := exists(ctx, tx, "Artist", "ArtistId", album.ArtistID)
+			if err != nil {
+				return 0, nil, err
+			}
+			if !ok {
+				return 0, nil, apperr.Field("artist_id", "artist not found")
+			}
+		}
+		switch op.Op {
+		case BulkCreate:
+			id, err := tx.InsertReturningID(ctx, "AlbumId", "INSERT INTO Album (Title, ArtistId) VALUES (?, ?)", album.Title, album.ArtistID)
+			if err != nil {
+				return 0, nil, fmt.Errorf("error creating album: %w", err)
+			}
+			album.ID = int(id)
+		case BulkUpdate:
+			album.ID = op.ID
+			result, err := tx.ExecContext(ctx, "UPDATE Album SET Title = ?, ArtistId = ? WHERE AlbumId = ?", album.Title, album.ArtistID, album.ID)
+			if err != nil {
+				return 0, nil, fmt.Errorf("error updating album: %w", err)
+			}
+			if err := affected(result, notFound); err != nil {
+				return 0, nil, err
+			}
+		case BulkDelete:
+			result, err := tx.ExecContext(ctx, "DELETE FROM Album WHERE AlbumId = ?", op.ID)
+			if database.IsForeignKeyViolation(err) {
+				return 0, nil, apperr.Conflict("album_in_use", "album still has tracks")
+			}
+			if err != nil {
+				return 0, nil, fmt.Errorf("error deleting album: %w", err)
+			}
+			if err := affected(result, notFound); err != nil {
+				return 0, nil, err
+			}
+			return op.ID, func() { r.Watchers.Remove(search.Album, op.ID) }, nil
+		default:
+			return 0, nil, errUnknownBulkOp
+		}
+		return album.ID, func() {
+			r.Watchers.Upsert(search.Entry{Kind: search.Album, ID: album.ID, Name: album.Title})
+		}, nil
+	})
+}
+
+// BulkTracks applies the track operations as one batch.
+func (r *TrackRepository) BulkTracks(ctx context.Context, atomic bool, ops []BulkOp[models.Track]) ([]BulkResult, error) {
+	notFound := apperr.NotFound("track_not_found", "track not found")
+	return runBulk(ctx, r.DB, atomic, ops, func(ctx context.Context, tx *database.Tx, op BulkOp[models.Track]) (int, func(), error) {
+		track := op.Data
+		if op.Op == BulkCreate || op.Op == BulkUpdate {
+			if err := checkTrackRefs(ctx, tx, track); err != nil {
+				return 0, nil, err
+			}
+		}
+		switch op.Op {
+		case BulkCreate:
+			id, err := tx.InsertReturningID(ctx, "TrackId", `
+				INSERT INTO Track (Name, AlbumId, MediaTypeId, GenreId, Composer, Milliseconds, Bytes, UnitPrice)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`,
+				track.Name, track.AlbumId, track.MediaTypeId, track.GenreId, track.Composer,
+				track.Milliseconds, track.Bytes, track.UnitPrice,
+			)
+			if err != nil {
+				return 0, nil, fmt.Errorf("error creating track: %w", err)
+			}
+			track.TrackId = int(id)
+		case BulkUpdate:
+			track.TrackId = op.ID
+			result, err := tx.ExecContext(ctx, `
+				UPDATE Track SET
+					Name = ?, AlbumId = ?, MediaTypeId = ?, GenreId = ?, Composer = ?,
+					Milliseconds = ?, Bytes = ?, UnitPrice = ?
+				WHERE TrackId = ?
+			`,
+				track.Name, track.AlbumId, track.MediaTypeId, track.GenreId, track.Composer,
+				track.Milliseconds, track.Bytes, track.UnitPrice, track.TrackId,
+			)
+			if err != nil {
+				return 0, nil, fmt.Errorf("error updating track: %w", err)
+			}
+			if err := affected(result, notFound); err != nil {
+				return 0, nil, err
+			}
+		case BulkDelete:
+			result, err := tx.ExecContext(ctx, "DELETE FROM Track WHERE TrackId = ?", op.ID)
+			if database.IsForeignKeyViolation(err) {
+				return 0, nil, apperr.Conflict("track_in_use", "track is in playlists or invoices")
+			}
+			if err != nil {
+				return 0, nil, fmt.Errorf("error deleting track: %w", err)
+			}
+			if err := affected(result, notFound); err != nil {
+				return 0, nil, err
+			}
+			return op.ID, func() { r.Watchers.Remove(search.Track, op.ID) }, nil
+		default:
+			return 0, nil, errUnknownBulkOp
+		}
+		return track.TrackId, func() {
+			r.Watchers.Upsert(search.Entry{Kind: search.Track, ID: track.TrackId, Name: track.Name})
+		}, nil
+	})
+}
+
+// checkTrackRefs reports the album, genre and media type of track that do
+// not exist, as field errors.
+func checkTrackRefs(ctx context.Context, tx *database.Tx, track models.Track) error {
+	fields := map[string]string{}
+	check := func(field, what, table, column string, id int) error {
+		ok, err := exists(ctx, tx, table, column, id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fields[field] = what + " not found"
+		}
+		return nil
+	}
+	if track.AlbumId != nil {
+		if err := check("album_id", "album", "Album", "AlbumId", *track.AlbumId); err != nil {
+			return err
+		}
+	}
+	if track.GenreId != nil {
+		if err := check("genre_id", "genre", "Genre", "GenreId", *track.GenreId); err != nil {
+			return err
+		}
+	}
+	if err := check("media_type_id", "media type", "MediaType", "MediaTypeId", track.MediaTypeId); err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		return apperr.Validation(fields)
+	}
+	return nil
+}
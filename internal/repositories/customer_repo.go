@@ -1,32 +1,54 @@
 package repositories
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 )
 
 type CustomerRepository struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
-func (r *CustomerRepository) GetAllCustomers(ctx context.Context) ([]models.Customer, error) {
+// GetAllCustomers returns the customers matching q.
+func (r *CustomerRepository) GetAllCustomers(ctx context.Context, q ListQuery) ([]models.Customer, error) {
+	var customers []models.Customer
+	err := r.EachCustomer(ctx, q, func(customer models.Customer) error {
+		customers = append(customers, customer)
+		return nil
+	})
+	return customers, err
+}
+
+// CountCustomers returns the number of customers matching q.
+func (r *CustomerRepository) CountCustomers(ctx context.Context, q ListQuery) (int, error) {
+	return q.count(ctx, r.DB, "Customer")
+}
+
+// EachCustomer calls fn with each customer matching q as it is read, so
+// that callers can stream the result. It stops at the first error fn
+// returns.
+func (r *CustomerRepository) EachCustomer(ctx context.Context, q ListQuery, fn func(models.Customer) error) error {
+	clause, args := q.clause()
 	rows, err := r.DB.QueryContext(ctx, `
 		SELECT
 			CustomerId, FirstName, LastName, Company, Address, City, State, Country,
 			PostalCode, Phone, Fax, Email, SupportRepId
 		FROM Customer
-	`)
+	`+clause, args...)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to query customers")
-		return nil, fmt.Errorf("error fetching customers: %w", err)
+		return fmt.Errorf("error fetching customers: %w", err)
 	}
 	defer rows.Close()
 
-	var customers []models.Customer
 	for rows.Next() {
 		var customer models.Customer
 		if err := rows.Scan(&customer.CustomerId, &customer.FirstName, &customer.LastName,
@@ -34,15 +56,17 @@ func (r *CustomerRepository) GetAllCustomers(ctx context.Context) ([]models.Cust
 			&customer.Country, &customer.PostalCode, &customer.Phone,
 			&customer.Fax, &customer.Email, &customer.SupportRepId); err != nil {
 			log.Error().Err(err).Msg("failed to scan customer")
-			return nil, fmt.Errorf("error scanning customer: %w", err)
+			return fmt.Errorf("error scanning customer: %w", err)
+		}
+		if err := fn(customer); err != nil {
+			return err
 		}
-		customers = append(customers, customer)
 	}
 	if err := rows.Err(); err != nil {
 		log.Error().Err(err).Msg("error iterating over customers")
-		return nil, fmt.Errorf("error iterating over customers: %w", err)
+		return fmt.Errorf("error iterating over customers: %w", err)
 	}
-	return customers, nil
+	return nil
 }
 
 func (r *CustomerRepository) GetCustomerByID(ctx context.Context, id int) (models.Customer, error) {
@@ -60,10 +84,199 @@ func (r *CustomerRepository) GetCustomerByID(ctx context.Context, id int) (model
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.Customer{}, fmt.Errorf("customer with ID %d not found", id)
+			return models.Customer{}, apperr.NotFound("customer_not_found", "customer with ID %d not found", id)
 		}
 		log.Error().Err(err).Msg("failed to query customer by ID")
 		return models.Customer{}, fmt.Errorf("error fetching customer by ID: %w", err)
 	}
 	return customer, nil
 }
+
+// GetCustomersBySupportRepID returns a page of the customers supported by
+// the employee and their total count.
+func (r *CustomerRepository) GetCustomersBySupportRepID(ctx context.Context, employeeID, limit, offset int) ([]models.Customer, int, error) {
+	var total int
+	if err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM Customer WHERE SupportRepId = ?", employeeID).Scan(&total); err != nil {
+		log.Error().Err(err).Int("employee_id", employeeID).Msg("failed to count customers for support rep")
+		return nil, 0, fmt.Errorf("error counting customers: %w", err)
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT
+			CustomerId, FirstName, LastName, Company, Address, City, State, Country,
+			PostalCode, Phone, Fax, Email, SupportRepId
+		FROM Customer
+		WHERE SupportRepId = ?
+		ORDER BY CustomerId
+		LIMIT ? OFFSET ?
+	`, employeeID, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Int("employee_id", employeeID).Msg("failed to query customers for support rep")
+		return nil, 0, fmt.Errorf("error fetching customers: %w", err)
+	}
+	defer rows.Close()
+
+	customers := []models.Customer{}
+	for rows.Next() {
+		var customer models.Customer
+		if err := rows.Scan(&customer.CustomerId, &customer.FirstName, &customer.LastName,
+			&customer.Company, &customer.Address, &customer.City, &customer.State,
+			&customer.Country, &customer.PostalCode, &customer.Phone,
+			&customer.Fax, &customer.Email, &customer.SupportRepId); err != nil {
+			log.Error().Err(err).Msg("failed to scan customer")
+			return nil, 0, fmt.Errorf("error scanning customer: %w", err)
+		}
+		customers = append(customers, customer)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over customers")
+		return nil, 0, fmt.Errorf("error iterating over customers: %w", err)
+	}
+	return customers, total, nil
+}
+
+func (r *CustomerRepository) CreateCustomer(ctx context.Context, customer models.Customer) (int64, error) {
+	id, err := r.DB.InsertReturningID(
+		ctx,
+		"CustomerId",
+		`INSERT INTO Customer (
+			FirstName, LastName, Company, Address, City, State, Country,
+			PostalCode, Phone, Fax, Email, SupportRepId
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		customer.FirstName, customer.LastName, customer.Company, customer.Address, customer.City,
+		customer.State, customer.Country, customer.PostalCode, customer.Phone, customer.Fax,
+		customer.Email, customer.SupportRepId,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create customer")
+		return 0, fmt.Errorf("error creating customer: %w", err)
+	}
+	log.Info().Int64("id", id).Msg("Customer created")
+	return id, nil
+}
+
+func (r *CustomerRepository) UpdateCustomer(ctx context.Context, customer models.Customer) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE Customer SET
+			FirstName = ?, LastName = ?, Company = ?, Address = ?, City = ?, State = ?, Country = ?,
+			PostalCode = ?, Phone = ?, Fax = ?, Email = ?, SupportRepId = ?
+		WHERE CustomerId = ?
+	`,
+		customer.FirstName, customer.LastName, customer.Company, customer.Address, customer.City,
+		customer.State, customer.Country, customer.PostalCode, customer.Phone, customer.Fax,
+		customer.Email, customer.SupportRepId, customer.CustomerId,
+	)
+	if err != nil {
+		log.Error().Err(err).Int("id", customer.CustomerId).Msg("failed to update customer")
+		return fmt.Errorf("error updating customer: %w", err)
+	}
+	log.Info().Int("id", customer.CustomerId).Msg("Customer updated")
+	return nil
+}
+
+// ErrCustomerHasInvoices is returned when deleting a customer who has been
+// invoiced; invoices are kept for the sales history.
+var ErrCustomerHasInvoices = apperr.Conflict("customer_has_invoices", "customer has invoices")
+
+func (r *CustomerRepository) DeleteCustomer(ctx context.Context, id int) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to start customer delete")
+		return fmt.Errorf("error deleting customer: %w", err)
+	}
+	defer tx.Rollback()
+
+	var invoices int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Invoice WHERE CustomerId = ?", id).Scan(&invoices); err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to count customer invoices")
+		return fmt.Errorf("error deleting customer: %w", err)
+	}
+	if invoices > 0 {
+		return ErrCustomerHasInvoices.Withf("customer has %d invoices", invoices)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Customer WHERE CustomerId = ?", id); err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to delete customer")
+		return fmt.Errorf("error deleting customer: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to commit customer delete")
+		return fmt.Errorf("error deleting customer: %w", err)
+	}
+	log.Info().Int("id", id).Msg("Customer deleted")
+	return nil
+}
+
+// ErrCustomerNotAssigned is returned when a customer to reassign is not
+// supported by the source rep.
+var ErrCustomerNotAssigned = apperr.Conflict("customer_not_assigned", "customers are not supported by the employee")
+
+// ReassignCustomers moves customers from one support rep to another in a
+// single transaction and returns the ids moved. With ids empty, every
+// customer of the source rep is moved; otherwise each id must currently be
+// supported by from, or nothing is moved.
+func (r *CustomerRepository) ReassignCustomers(ctx context.Context, from, to int, ids []int) ([]int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start customer reassignment")
+		return nil, fmt.Errorf("error reassigning customers: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "SELECT CustomerId FROM Customer WHERE SupportRepId = ?"
+	args := []any{from}
+	if len(ids) > 0 {
+		query += " AND CustomerId IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	rows, err := tx.QueryContext(ctx, query+" ORDER BY CustomerId", args...)
+	if err != nil {
+		log.Error().Err(err).Int("from", from).Msg("failed to query customers to reassign")
+		return nil, fmt.Errorf("error reassigning customers: %w", err)
+	}
+	moved := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Error().Err(err).Msg("failed to scan customer to reassign")
+			return nil, fmt.Errorf("error reassigning customers: %w", err)
+		}
+		moved = append(moved, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over customers to reassign")
+		return nil, fmt.Errorf("error reassigning customers: %w", err)
+	}
+
+	var missing []int
+	for _, id := range ids {
+		if !slices.Contains(moved, id) && !slices.Contains(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, ErrCustomerNotAssigned.Withf("customers %v are not supported by employee %d", missing, from)
+	}
+	if len(moved) == 0 {
+		return moved, nil
+	}
+
+	update := "UPDATE Customer SET SupportRepId = ? WHERE CustomerId IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(moved)), ", ") + ")"
+	args = []any{to}
+	for _, id := range moved {
+		args = append(args, id)
+	}
+	if _, err := tx.ExecContext(ctx, update, args...); err != nil {
+		log.Error().Err(err).Int("from", from).Int("to", to).Msg("failed to reassign customers")
+		return nil, fmt.Errorf("error reassigning customers: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("failed to commit customer reassignment")
+		return nil, fmt.Errorf("error reassigning customers: %w", err)
+	}
+	log.Info().Int("from", from).Int("to", to).Int("count", len(moved)).Msg("Customers reassigned")
+	return moved, nil
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/database/dbtest"
+	"chinook-api/internal/models"
+)
+
+func TestCustomerDelete(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		repo := &CustomerRepository{DB: db}
+
+		if err := repo.DeleteCustomer(ctx, 1); !errors.Is(err, ErrCustomerHasInvoices) {
+			t.Errorf("deleting a customer with invoices: err = %v, want ErrCustomerHasInvoices", err)
+		}
+		if err := repo.DeleteCustomer(ctx, 2); err != nil {
+			t.Fatalf("DeleteCustomer(2): %v", err)
+		}
+		if _, err := repo.GetCustomerByID(ctx, 2); !apperr.IsNotFound(err) {
+			t.Errorf("GetCustomerByID after delete: err = %v, want not found", err)
+		}
+	})
+}
+
+func TestCustomerCreate(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		repo := &CustomerRepository{DB: db}
+
+		city, rep := "Paris", 1
+		id, err := repo.CreateCustomer(ctx, models.Customer{FirstName: "Camille", LastName: "Bernard", City: &city, Email: "camille.bernard@yahoo.fr", SupportRepId: &rep})
+		if err != nil {
+			t.Fatalf("CreateCustomer: %v", err)
+		}
+		customer, err := repo.GetCustomerByID(ctx, int(id))
+		if err != nil || customer.City == nil || *customer.City != city || customer.Company != nil {
+			t.Fatalf("GetCustomerByID = %+v, %v", customer, err)
+		}
+
+		customers, total, err := repo.GetCustomersBySupportRepID(ctx, 1, 10, 0)
+		if err != nil || total != 2 || len(customers) != 2 {
+			t.Errorf("GetCustomersBySupportRepID(1) = %d customers of %d, %v; want 2 of 2", len(customers), total, err)
+		}
+	})
+}
+
+func TestUserCreateConflict(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		repo := &UserRepository{DB: db}
+
+		user := models.User{Username: "alice", Email: "alice@example.com", Password: "hash"}
+		if _, err := repo.CreateUser(ctx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		_, err := repo.CreateUser(ctx, user)
+		if e, ok := apperr.As(err); !ok || e.Code != "user_exists" {
+			t.Errorf("creating a duplicate user: err = %v, want user_exists", err)
+		}
+		if got, err := repo.GetUserByUsername(ctx, "alice"); err != nil || got.Email != user.Email {
+			t.Errorf("GetUserByUsername = %+v, %v", got, err)
+		}
+	})
+}
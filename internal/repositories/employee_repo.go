@@ -1,29 +1,145 @@
 package repositories
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
+	"slices"
+	"sort"
 
 	"github.com/rs/zerolog/log"
 )
 
 type EmployeeRepository struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
 func (r *EmployeeRepository) CreateEmployee(ctx context.Context, emp models.Employee) (int64, error) {
-	result, err := r.DB.ExecContext(
+	id, err := r.DB.InsertReturningID(
 		ctx,
-		"INSERT INTO Employee (FirstName, LastName, Title, Email) VALUES (?, ?, ?, ?)",
-		emp.FirstName, emp.LastName, emp.Title, emp.Email,
+		"EmployeeId",
+		`INSERT INTO Employee (
+			LastName, FirstName, Title, ReportsTo, BirthDate, HireDate,
+			Address, City, State, Country, PostalCode, Phone, Fax, Email
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		emp.LastName, emp.FirstName, emp.Title, emp.ReportsTo, emp.BirthDate, emp.HireDate,
+		emp.Address, emp.City, emp.State, emp.Country, emp.PostalCode, emp.Phone, emp.Fax, emp.Email,
 	)
 	if err != nil {
 		log.Error().Err(err).Msg("Error creating employee")
 		return 0, fmt.Errorf("error creating employee: %w", err)
 	}
-	return result.LastInsertId()
+	log.Info().Int64("id", id).Msg("Employee created")
+	return id, nil
+}
+
+// UpdateEmployee writes emp. A new ReportsTo is checked for cycles in the
+// same write transaction as the update, so that two reassignments racing
+// each other (A under B, B under A) cannot both pass the check: on SQLite
+// the single writer takes the lock at BEGIN, and on Postgres the Employee
+// table is locked against other writers. It returns ErrEmployeeCycle when
+// the new manager reports to emp, directly or transitively.
+func (r *EmployeeRepository) UpdateEmployee(ctx context.Context, emp models.Employee) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Int("id", emp.EmployeeId).Msg("Error starting employee update")
+		return fmt.Errorf("error updating employee: %w", err)
+	}
+	defer tx.Rollback()
+
+	if emp.ReportsTo != nil {
+		if r.DB.Dialect == database.Postgres {
+			if _, err := tx.ExecContext(ctx, "LOCK TABLE Employee IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+				log.Error().Err(err).Int("id", emp.EmployeeId).Msg("Error locking employees")
+				return fmt.Errorf("error updating employee: %w", err)
+			}
+		}
+		cycle, err := manages(ctx, tx, emp.EmployeeId, *emp.ReportsTo)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrEmployeeCycle
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE Employee SET
+			LastName = ?, FirstName = ?, Title = ?, ReportsTo = ?, BirthDate = ?, HireDate = ?,
+			Address = ?, City = ?, State = ?, Country = ?, PostalCode = ?, Phone = ?, Fax = ?, Email = ?
+		WHERE EmployeeId = ?
+	`,
+		emp.LastName, emp.FirstName, emp.Title, emp.ReportsTo, emp.BirthDate, emp.HireDate,
+		emp.Address, emp.City, emp.State, emp.Country, emp.PostalCode, emp.Phone, emp.Fax, emp.Email,
+		emp.EmployeeId,
+	)
+	if err != nil {
+		log.Error().Err(err).Int("id", emp.EmployeeId).Msg("Error updating employee")
+		return fmt.Errorf("error updating employee: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Error().Err(err).Int("id", emp.EmployeeId).Msg("Error getting rows affected")
+		return fmt.Errorf("error updating employee: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperr.NotFound("employee_not_found", "employee not found")
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Int("id", emp.EmployeeId).Msg("Error committing employee update")
+		return fmt.Errorf("error updating employee: %w", err)
+	}
+	log.Info().Int("id", emp.EmployeeId).Msg("Employee updated")
+	return nil
+}
+
+// ErrEmployeeInUse is returned when deleting an employee who still supports
+// customers or has direct reports.
+var ErrEmployeeInUse = apperr.Conflict("employee_in_use", "employee still has customers or direct reports")
+
+// DeleteEmployee removes the employee and unlinks any user accounts from
+// them. It refuses with ErrEmployeeInUse while customers or direct reports
+// still point at the employee; reassign them first.
+func (r *EmployeeRepository) DeleteEmployee(ctx context.Context, id int) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("Error starting employee delete")
+		return fmt.Errorf("error deleting employee: %w", err)
+	}
+	defer tx.Rollback()
+
+	var customers, reports int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM Customer WHERE SupportRepId = ?),
+			(SELECT COUNT(*) FROM Employee WHERE ReportsTo = ? AND EmployeeId <> ?)
+	`, id, id, id).Scan(&customers, &reports); err != nil {
+		log.Error().Err(err).Int("id", id).Msg("Error counting employee dependents")
+		return fmt.Errorf("error deleting employee: %w", err)
+	}
+	if customers > 0 || reports > 0 {
+		return ErrEmployeeInUse.Withf("employee still has %d customers and %d direct reports", customers, reports)
+	}
+
+	for _, query := range []string{
+		`UPDATE "User" SET EmployeeId = NULL WHERE EmployeeId = ?`,
+		"DELETE FROM Employee WHERE EmployeeId = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			log.Error().Err(err).Int("id", id).Msg("Error deleting employee")
+			return fmt.Errorf("error deleting employee: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Error().Err(err).Int("id", id).Msg("Error committing employee delete")
+		return fmt.Errorf("error deleting employee: %w", err)
+	}
+	log.Info().Int("id", id).Msg("Employee deleted")
+	return nil
 }
 
 func (r *EmployeeRepository) GetAllEmployees(ctx context.Context) ([]models.Employee, error) {
@@ -79,7 +195,7 @@ func (r *EmployeeRepository) GetEmployeeByID(ctx context.Context, id int) (model
 			Address, City, State, Country, PostalCode, Phone, Fax, Email
 		FROM Employee
 		WHERE EmployeeId = ?
-	`).Scan(
+	`, id).Scan(
 		&employee.EmployeeId,
 		&employee.LastName,
 		&employee.FirstName,
@@ -99,7 +215,7 @@ func (r *EmployeeRepository) GetEmployeeByID(ctx context.Context, id int) (model
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Warn().Int("id", id).Msg("Employee not found")
-			return employee, fmt.Errorf("employee not found")
+			return employee, apperr.NotFound("employee_not_found", "employee not found")
 		}
 		log.Error().Err(err).Int("id", id).Msg("Database error fetching employee")
 		return employee, fmt.Errorf("database error: %w", err)
@@ -107,3 +223,231 @@ func (r *EmployeeRepository) GetEmployeeByID(ctx context.Context, id int) (model
 	log.Debug().Int("id", employee.EmployeeId).Msg("Fetched employee by ID")
 	return employee, nil
 }
+
+// ErrEmployeeCycle is returned when a change of manager would make an
+// employee report, directly or transitively, to themselves.
+var ErrEmployeeCycle = &apperr.Error{
+	Kind:    apperr.KindValidation,
+	Code:    "employee_cycle",
+	Message: "employee would report to themselves",
+	Fields:  map[string]string{"reports_to": "would make the employee report to themselves"},
+}
+
+// The recursive queries below carry the path of ids walked so far, and stop
+// at an employee already on it, so a ReportsTo cycle in the data ends the
+// walk instead of looping forever. The row that closes a cycle is returned
+// with Cycle = 1.
+
+// GetOrgChart returns the whole management hierarchy.
+func (r *EmployeeRepository) GetOrgChart(ctx context.Context) (models.OrgChart, error) {
+	chart := models.OrgChart{Roots: []*models.OrgNode{}}
+	rows, err := r.DB.QueryContext(ctx, `
+		WITH RECURSIVE org (EmployeeId, Depth, Path) AS (
+			SELECT EmployeeId, 0, '/' || CAST(EmployeeId AS TEXT) || '/'
+			FROM Employee
+			WHERE ReportsTo IS NULL OR ReportsTo NOT IN (SELECT EmployeeId FROM Employee)
+			UNION ALL
+			SELECT e.EmployeeId, org.Depth + 1, org.Path || CAST(e.EmployeeId AS TEXT) || '/'
+			FROM Employee e JOIN org ON e.ReportsTo = org.EmployeeId
+			WHERE org.Path NOT LIKE ('%/' || CAST(e.EmployeeId AS TEXT) || '/%')
+		)
+		SELECT e.EmployeeId, e.LastName, e.FirstName, e.Title, e.ReportsTo, org.Depth
+		FROM Employee e LEFT JOIN org ON org.EmployeeId = e.EmployeeId
+	`)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query org chart")
+		return chart, fmt.Errorf("error fetching org chart: %w", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		node      *models.OrgNode
+		reportsTo *int
+		depth     *int
+	}
+	var all []row
+	for rows.Next() {
+		n := &models.OrgNode{Reports: []*models.OrgNode{}}
+		var rw row
+		if err := rows.Scan(&n.EmployeeId, &n.LastName, &n.FirstName, &n.Title, &rw.reportsTo, &rw.depth); err != nil {
+			log.Error().Err(err).Msg("failed to scan org chart")
+			return chart, fmt.Errorf("error scanning org chart: %w", err)
+		}
+		rw.node = n
+		all = append(all, rw)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over org chart")
+		return chart, fmt.Errorf("error iterating over org chart: %w", err)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].node.EmployeeId < all[j].node.EmployeeId })
+	nodes := make(map[int]*models.OrgNode, len(all))
+	parents := make(map[int]int, len(all))
+	for _, rw := range all {
+		nodes[rw.node.EmployeeId] = rw.node
+		if rw.reportsTo != nil {
+			parents[rw.node.EmployeeId] = *rw.reportsTo
+		}
+	}
+	for _, rw := range all {
+		switch {
+		case rw.depth == nil:
+			chart.Unreachable = append(chart.Unreachable, rw.node.EmployeeId)
+		case *rw.depth == 0:
+			chart.Roots = append(chart.Roots, rw.node)
+		default:
+			parent := nodes[*rw.reportsTo]
+			parent.Reports = append(parent.Reports, rw.node)
+		}
+	}
+	chart.Cycles = findCycles(chart.Unreachable, parents)
+	if len(chart.Cycles) > 0 {
+		log.Warn().Interface("cycles", chart.Cycles).Msg("Employee hierarchy contains reporting cycles")
+	}
+	return chart, nil
+}
+
+// findCycles follows the managers of each unreachable employee until an
+// employee repeats, and returns each distinct cycle once.
+func findCycles(unreachable []int, parents map[int]int) [][]int {
+	cycles := [][]int{}
+	inCycle := map[int]bool{}
+	for _, start := range unreachable {
+		position := map[int]int{}
+		var walk []int
+		id, ok := start, true
+		for ok && !inCycle[id] {
+			if i, seen := position[id]; seen {
+				cycle := walk[i:]
+				low := 0
+				for j, member := range cycle {
+					inCycle[member] = true
+					if member < cycle[low] {
+						low = j
+					}
+				}
+				cycles = append(cycles, append(slices.Clone(cycle[low:]), cycle[:low]...))
+				break
+			}
+			position[id] = len(walk)
+			walk = append(walk, id)
+			id, ok = parents[id]
+		}
+	}
+	if len(cycles) == 0 {
+		return nil
+	}
+	return cycles
+}
+
+// GetReports returns the employees below id: only direct reports, or
+// everyone in the subtree when transitive is set, nearest first.
+func (r *EmployeeRepository) GetReports(ctx context.Context, id int, transitive bool) (models.OrgMembers, error) {
+	maxDepth := 1
+	if transitive {
+		maxDepth = math.MaxInt32
+	}
+	return orgMembers(ctx, r.DB, id, `
+		WITH RECURSIVE walk (EmployeeId, Depth, Path, Cycle) AS (
+			SELECT EmployeeId, 1,
+			       '/' || CAST(ReportsTo AS TEXT) || '/' || CAST(EmployeeId AS TEXT) || '/',
+			       CASE WHEN EmployeeId = ReportsTo THEN 1 ELSE 0 END
+			FROM Employee WHERE ReportsTo = ?
+			UNION ALL
+			SELECT e.EmployeeId, walk.Depth + 1,
+			       walk.Path || CAST(e.EmployeeId AS TEXT) || '/',
+			       CASE WHEN walk.Path LIKE ('%/' || CAST(e.EmployeeId AS TEXT) || '/%') THEN 1 ELSE 0 END
+			FROM Employee e JOIN walk ON e.ReportsTo = walk.EmployeeId
+			WHERE walk.Cycle = 0 AND walk.Depth < ?
+		)`, id, maxDepth)
+}
+
+// GetChain returns the management chain above id, from its direct manager
+// up to the root.
+func (r *EmployeeRepository) GetChain(ctx context.Context, id int) (models.OrgMembers, error) {
+	return chain(ctx, r.DB, id)
+}
+
+func chain(ctx context.Context, q queryer, id int) (models.OrgMembers, error) {
+	return orgMembers(ctx, q, id, `
+		WITH RECURSIVE walk (EmployeeId, Depth, Path, Cycle) AS (
+			SELECT m.EmployeeId, 1,
+			       '/' || CAST(e.EmployeeId AS TEXT) || '/' || CAST(m.EmployeeId AS TEXT) || '/',
+			       CASE WHEN m.EmployeeId = e.EmployeeId THEN 1 ELSE 0 END
+			FROM Employee e JOIN Employee m ON m.EmployeeId = e.ReportsTo
+			WHERE e.EmployeeId = ?
+			UNION ALL
+			SELECT m.EmployeeId, walk.Depth + 1,
+			       walk.Path || CAST(m.EmployeeId AS TEXT) || '/',
+			       CASE WHEN walk.Path LIKE ('%/' || CAST(m.EmployeeId AS TEXT) || '/%') THEN 1 ELSE 0 END
+			FROM walk
+			JOIN Employee c ON c.EmployeeId = walk.EmployeeId
+			JOIN Employee m ON m.EmployeeId = c.ReportsTo
+			WHERE walk.Cycle = 0
+		)`, id)
+}
+
+// queryer is what the hierarchy walks need, so that they can run on the
+// read pool or inside a write transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// orgMembers runs a recursive walk CTE and returns the employees it reached.
+func orgMembers(ctx context.Context, q queryer, id int, walk string, args ...any) (models.OrgMembers, error) {
+	members := models.OrgMembers{EmployeeId: id, Members: []models.OrgMember{}}
+	rows, err := q.QueryContext(ctx, walk+`
+		SELECT e.EmployeeId, e.LastName, e.FirstName, e.Title, e.ReportsTo, walk.Depth, walk.Cycle
+		FROM walk JOIN Employee e ON e.EmployeeId = walk.EmployeeId
+		ORDER BY walk.Depth, e.EmployeeId
+	`, args...)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to walk employee hierarchy")
+		return members, fmt.Errorf("error walking employee hierarchy: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m models.OrgMember
+		var cycle int
+		if err := rows.Scan(&m.EmployeeId, &m.LastName, &m.FirstName, &m.Title, &m.ReportsTo, &m.Depth, &cycle); err != nil {
+			log.Error().Err(err).Msg("failed to scan employee")
+			return members, fmt.Errorf("error scanning employee: %w", err)
+		}
+		if cycle == 1 {
+			members.CycleDetected = true
+			continue
+		}
+		members.Members = append(members.Members, m)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over employees")
+		return members, fmt.Errorf("error iterating over employees: %w", err)
+	}
+	if members.CycleDetected {
+		log.Warn().Int("id", id).Msg("Employee hierarchy contains a reporting cycle")
+	}
+	return members, nil
+}
+
+// Manages reports whether employeeID is managerID or somewhere below it, by
+// walking up employeeID's management chain.
+func (r *EmployeeRepository) Manages(ctx context.Context, managerID, employeeID int) (bool, error) {
+	return manages(ctx, r.DB, managerID, employeeID)
+}
+
+func manages(ctx context.Context, q queryer, managerID, employeeID int) (bool, error) {
+	if managerID == employeeID {
+		return true, nil
+	}
+	up, err := chain(ctx, q, employeeID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range up.Members {
+		if m.EmployeeId == managerID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
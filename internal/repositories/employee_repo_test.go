@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/database/dbtest"
+	"chinook-api/internal/models"
+)
+
+func TestEmployeeUpdate(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		repo := &EmployeeRepository{DB: db}
+
+		// 1 <- 2 <- 3
+		one := 1
+		id, err := repo.CreateEmployee(ctx, models.Employee{LastName: "Edwards", FirstName: "Nancy", ReportsTo: &one})
+		if err != nil {
+			t.Fatalf("CreateEmployee: %v", err)
+		}
+		two := int(id)
+		id, err = repo.CreateEmployee(ctx, models.Employee{LastName: "Peacock", FirstName: "Jane", ReportsTo: &two})
+		if err != nil {
+			t.Fatalf("CreateEmployee: %v", err)
+		}
+		three := int(id)
+
+		boss, err := repo.GetEmployeeByID(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		boss.ReportsTo = &three
+		if err := repo.UpdateEmployee(ctx, boss); !errors.Is(err, ErrEmployeeCycle) {
+			t.Errorf("putting the root under its grandchild: err = %v, want ErrEmployeeCycle", err)
+		}
+		boss.ReportsTo = &boss.EmployeeId
+		if err := repo.UpdateEmployee(ctx, boss); !errors.Is(err, ErrEmployeeCycle) {
+			t.Errorf("making an employee their own manager: err = %v, want ErrEmployeeCycle", err)
+		}
+
+		gone := models.Employee{EmployeeId: 999, LastName: "Nobody", FirstName: "No", ReportsTo: &one}
+		if err := repo.UpdateEmployee(ctx, gone); !apperr.IsNotFound(err) {
+			t.Errorf("updating a missing employee: err = %v, want not found", err)
+		}
+	})
+}
+
+func TestEmployeeConcurrentReassignment(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		repo := &EmployeeRepository{DB: db}
+
+		for round := 0; round < 20; round++ {
+			var ids [2]int
+			for i := range ids {
+				id, err := repo.CreateEmployee(ctx, models.Employee{LastName: "Park", FirstName: "Margaret"})
+				if err != nil {
+					t.Fatalf("CreateEmployee: %v", err)
+				}
+				ids[i] = int(id)
+			}
+
+			// A under B and B under A at once: one of them must lose
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i := range ids {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					manager := ids[1-i]
+					errs[i] = repo.UpdateEmployee(ctx, models.Employee{EmployeeId: ids[i], LastName: "Park", FirstName: "Margaret", ReportsTo: &manager})
+				}(i)
+			}
+			wg.Wait()
+			if errs[0] == nil && errs[1] == nil {
+				t.Fatalf("round %d: both reassignments succeeded, leaving a cycle", round)
+			}
+			for _, err := range errs {
+				if err != nil && !errors.Is(err, ErrEmployeeCycle) {
+					t.Fatalf("round %d: err = %v, want ErrEmployeeCycle", round, err)
+				}
+			}
+		}
+	})
+}
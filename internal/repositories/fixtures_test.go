@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"chinook-api/internal/database"
+)
+
+// seedFixtures is a small slice of the Chinook catalog and its sales:
+// artist 3 has no albums, customer 2 has no invoices and track 3 has no
+// genre.
+const seedFixtures = `[
+	{"table": "MediaType", "rows": [{"MediaTypeId": 1, "Name": "MPEG audio file"}]},
+	{"table": "Genre", "rows": [{"GenreId": 1, "Name": "Rock"}, {"GenreId": 2, "Name": "Jazz"}]},
+	{"table": "Artist", "rows": [
+		{"ArtistId": 1, "Name": "AC/DC"},
+		{"ArtistId": 2, "Name": "Accept"},
+		{"ArtistId": 3, "Name": "Aerosmith"}
+	]},
+	{"table": "Album", "rows": [
+		{"AlbumId": 1, "Title": "For Those About To Rock We Salute You", "ArtistId": 1},
+		{"AlbumId": 2, "Title": "Let There Be Rock", "ArtistId": 1},
+		{"AlbumId": 3, "Title": "Balls to the Wall", "ArtistId": 2}
+	]},
+	{"table": "Track", "rows": [
+		{"TrackId": 1, "Name": "For Those About To Rock (We Salute You)", "AlbumId": 1, "MediaTypeId": 1, "GenreId": 1, "Composer": "Angus Young", "Milliseconds": 343719, "Bytes": 11170334, "UnitPrice": 0.99},
+		{"TrackId": 2, "Name": "Put The Finger On You", "AlbumId": 1, "MediaTypeId": 1, "GenreId": 1, "Milliseconds": 205662, "UnitPrice": 0.99},
+		{"TrackId": 3, "Name": "Go Down", "AlbumId": 2, "MediaTypeId": 1, "Milliseconds": 331180, "UnitPrice": 0.99},
+		{"TrackId": 4, "Name": "Balls to the Wall", "AlbumId": 3, "MediaTypeId": 1, "GenreId": 1, "Milliseconds": 342562, "UnitPrice": 1.99}
+	]},
+	{"table": "Employee", "rows": [{"EmployeeId": 1, "LastName": "Adams", "FirstName": "Andrew"}]},
+	{"table": "Customer", "rows": [
+		{"CustomerId": 1, "FirstName": "Luís", "LastName": "Gonçalves", "Email": "luisg@embraer.com.br", "SupportRepId": 1},
+		{"CustomerId": 2, "FirstName": "Leonie", "LastName": "Köhler", "Email": "leonekohler@surfeu.de"}
+	]},
+	{"table": "Invoice", "rows": [{"InvoiceId": 1, "CustomerId": 1, "InvoiceDate": "2024-01-01 00:00:00", "BillingCountry": "Brazil", "Total": 1.98}]},
+	{"table": "InvoiceLine", "rows": [
+		{"InvoiceLineId": 1, "InvoiceId": 1, "TrackId": 1, "UnitPrice": 0.99, "Quantity": 1},
+		{"InvoiceLineId": 2, "InvoiceId": 1, "TrackId": 2, "UnitPrice": 0.99, "Quantity": 1}
+	]}
+]`
+
+func seed(t *testing.T, db *database.DB) {
+	t.Helper()
+	if _, err := database.LoadFixtures(context.Background(), db, strings.NewReader(seedFixtures)); err != nil {
+		t.Fatalf("seeding: %v", err)
+	}
+}
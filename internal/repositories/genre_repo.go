@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
 	"context"
 	"database/sql"
@@ -10,7 +12,7 @@ import (
 )
 
 type GenreRepository struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
 func (r *GenreRepository) GetAllGenres(ctx context.Context) ([]models.Genre, error) {
@@ -52,7 +54,7 @@ func (r *GenreRepository) GetGenreByID(ctx context.Context, id int) (models.Genr
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.Genre{}, fmt.Errorf("genre with ID %d not found", id)
+			return models.Genre{}, apperr.NotFound("genre_not_found", "genre with ID %d not found", id)
 		}
 		log.Error().Err(err).Msg("failed to query genre by ID")
 		return models.Genre{}, fmt.Errorf("error fetching genre by ID: %w", err)
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// IdempotencyRepository stores the responses to POST requests made with an
+// Idempotency-Key, keyed by the caller's scope and the key.
+type IdempotencyRepository struct {
+	DB *database.DB
+}
+
+// StoredResponse is a response recorded for replay.
+type StoredResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+var (
+	// ErrIdempotencyKeyReused is returned when a key comes back with a
+	// different request than the one it was first used for.
+	ErrIdempotencyKeyReused = apperr.New(apperr.KindUnprocessable, "idempotency_key_reused", "the Idempotency-Key was already used for a different request")
+	// ErrIdempotencyInFlight is returned while the first request with a key
+	// is still being handled.
+	ErrIdempotencyInFlight = apperr.Conflict("idempotency_in_flight", "a request with this Idempotency-Key is still being processed")
+)
+
+// Begin claims key for a new request with the given fingerprint. It returns
+// nil when the caller should handle the request and record the outcome with
+// Complete or Release, or the stored response when the same request was
+// already handled within the TTL.
+func (r *IdempotencyRepository) Begin(ctx context.Context, scope, key, fingerprint string, ttl time.Duration) (*StoredResponse, error) {
+	now := time.Now()
+	if _, err := r.DB.ExecContext(ctx, "DELETE FROM IdempotencyKey WHERE ExpiresAt <= ?", now.Unix()); err != nil {
+		log.Error().Err(err).Msg("failed to purge expired idempotency keys")
+		return nil, fmt.Errorf("error purging idempotency keys: %w", err)
+	}
+
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO IdempotencyKey (Scope, RequestKey, Fingerprint, CreatedAt, ExpiresAt)
+		VALUES (?, ?, ?, ?, ?)
+	`, scope, key, fingerprint, now.Unix(), now.Add(ttl).Unix())
+	if err == nil {
+		return nil, nil
+	}
+	if !database.IsUniqueViolation(err) {
+		log.Error().Err(err).Msg("failed to claim idempotency key")
+		return nil, fmt.Errorf("error claiming idempotency key: %w", err)
+	}
+
+	var stored StoredResponse
+	var storedFingerprint string
+	var status sql.NullInt64
+	var contentType sql.NullString
+	err = r.DB.QueryRowContext(ctx, `
+		SELECT Fingerprint, Status, ContentType, Body FROM IdempotencyKey
+		WHERE Scope = ? AND RequestKey = ?
+	`, scope, key).Scan(&storedFingerprint, &status, &contentType, &stored.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		// released by a failed first attempt in the meantime
+		return nil, ErrIdempotencyInFlight
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to read idempotency key")
+		return nil, fmt.Errorf("error reading idempotency key: %w", err)
+	}
+	if storedFingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !status.Valid {
+		return nil, ErrIdempotencyInFlight
+	}
+	stored.Status = int(status.Int64)
+	stored.ContentType = contentType.String
+	return &stored, nil
+}
+
+// Complete records the response to the request holding key.
+func (r *IdempotencyRepository) Complete(ctx context.Context, scope, key string, resp StoredResponse) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE IdempotencyKey SET Status = ?, ContentType = ?, Body = ?
+		WHERE Scope = ? AND RequestKey = ?
+	`, resp.Status, resp.ContentType, resp.Body, scope, key)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to store idempotent response")
+		return fmt.Errorf("error storing idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release forgets key, so that a request which failed on the server side can
+// be retried with it.
+func (r *IdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := r.DB.ExecContext(ctx, "DELETE FROM IdempotencyKey WHERE Scope = ? AND RequestKey = ?", scope, key)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to release idempotency key")
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,399 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MaxIncludeDepth bounds how many relations one include path may follow,
+// e.g. lines.track.album is three deep.
+const MaxIncludeDepth = 3
+
+// maxIncludePaths bounds how many include paths one request may name.
+const maxIncludePaths = 10
+
+// includeBatchSize bounds the number of ids bound into one IN (...) query.
+const includeBatchSize = 500
+
+// includeRelations is the allow-list of relations that can be embedded on
+// each resource, mapped to the resource they lead to.
+var includeRelations = map[string]map[string]string{
+	"track":        {"album": "album", "genre": "genre", "media_type": "media_type"},
+	"album":        {"artist": "artist", "tracks": "track"},
+	"invoice":      {"customer": "customer", "lines": "invoice_line"},
+	"invoice_line": {"track": "track", "invoice": "invoice"},
+}
+
+// IncludeTree is a parsed ?include= parameter: each key is a relation to
+// embed and its value the relations to embed inside it.
+type IncludeTree map[string]IncludeTree
+
+// ParseInclude parses a comma-separated list of dotted relation paths for
+// resource, such as "album.artist,genre" on tracks. Every step must be on
+// the resource's allow-list and no path may be deeper than MaxIncludeDepth.
+func ParseInclude(resource, raw string) (IncludeTree, error) {
+	tree := IncludeTree{}
+	if strings.TrimSpace(raw) == "" {
+		return tree, nil
+	}
+	paths := strings.Split(raw, ",")
+	if len(paths) > maxIncludePaths {
+		return nil, apperr.Field("include", fmt.Sprintf("lists %d paths; at most %d are allowed", len(paths), maxIncludePaths))
+	}
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		steps := strings.Split(path, ".")
+		if len(steps) > MaxIncludeDepth {
+			return nil, apperr.Field("include", fmt.Sprintf("%q is nested deeper than %d levels", path, MaxIncludeDepth))
+		}
+		node, current := tree, resource
+		for _, step := range steps {
+			next, ok := includeRelations[current][step]
+			if !ok {
+				return nil, apperr.Field("include", fmt.Sprintf("cannot include %q on %s; allowed: %s", step, current, allowedIncludes(current)))
+			}
+			if node[step] == nil {
+				node[step] = IncludeTree{}
+			}
+			node, current = node[step], next
+		}
+	}
+	return tree, nil
+}
+
+func allowedIncludes(resource string) string {
+	var names []string
+	for name := range includeRelations[resource] {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Includer embeds related rows into already fetched resources. Each relation
+// is loaded with one batched IN query per level, however many rows are being
+// expanded, so includes never cause N+1 queries.
+type Includer struct {
+	DB *database.DB
+}
+
+// Tracks embeds the relations in tree into every track, in place.
+func (in *Includer) Tracks(ctx context.Context, tracks []models.Track, tree IncludeTree) error {
+	if len(tracks) == 0 || len(tree) == 0 {
+		return nil
+	}
+	if sub, ok := tree["album"]; ok {
+		var ids []int
+		for _, t := range tracks {
+			if t.AlbumId != nil {
+				ids = append(ids, *t.AlbumId)
+			}
+		}
+		albums, err := fetchIn(ctx, in.DB, "SELECT AlbumId, Title, ArtistId FROM Album WHERE AlbumId IN (%s)", ids, scanAlbum)
+		if err != nil {
+			return err
+		}
+		if err := in.Albums(ctx, albums, sub); err != nil {
+			return err
+		}
+		byID := indexBy(albums, func(a models.Album) int { return a.ID })
+		for i := range tracks {
+			if tracks[i].AlbumId != nil {
+				tracks[i].Album = byID[*tracks[i].AlbumId]
+			}
+		}
+	}
+	if _, ok := tree["genre"]; ok {
+		var ids []int
+		for _, t := range tracks {
+			if t.GenreId != nil {
+				ids = append(ids, *t.GenreId)
+			}
+		}
+		genres, err := fetchIn(ctx, in.DB, "SELECT GenreId, Name FROM Genre WHERE GenreId IN (%s)", ids, scanGenre)
+		if err != nil {
+			return err
+		}
+		byID := indexBy(genres, func(g models.Genre) int { return g.GenreId })
+		for i := range tracks {
+			if tracks[i].GenreId != nil {
+				tracks[i].Genre = byID[*tracks[i].GenreId]
+			}
+		}
+	}
+	if _, ok := tree["media_type"]; ok {
+		ids := make([]int, len(tracks))
+		for i, t := range tracks {
+			ids[i] = t.MediaTypeId
+		}
+		mediaTypes, err := fetchIn(ctx, in.DB, "SELECT MediaTypeId, Name FROM MediaType WHERE MediaTypeId IN (%s)", ids, scanMediaType)
+		if err != nil {
+			return err
+		}
+		byID := indexBy(mediaTypes, func(m models.MediaType) int { return m.MediaTypeId })
+		for i := range tracks {
+			tracks[i].MediaType = byID[tracks[i].MediaTypeId]
+		}
+	}
+	return nil
+}
+
+// Albums embeds the relations in tree into every album, in place.
+func (in *Includer) Albums(ctx context.Context, albums []models.Album, tree IncludeTree) error {
+	if len(albums) == 0 || len(tree) == 0 {
+		return nil
+	}
+	ids := make([]int, len(albums))
+	artistIDs := make([]int, len(albums))
+	for i, a := range albums {
+		ids[i] = a.ID
+		artistIDs[i] = a.ArtistID
+	}
+	if _, ok := tree["artist"]; ok {
+		artists, err := fetchIn(ctx, in.DB, "SELECT ArtistId, Name FROM Artist WHERE ArtistId IN (%s)", artistIDs, scanArtist)
+		if err != nil {
+			return err
+		}
+		byID := indexBy(artists, func(a models.Artist) int { return a.ID })
+		for i := range albums {
+			albums[i].Artist = byID[albums[i].ArtistID]
+		}
+	}
+	if sub, ok := tree["tracks"]; ok {
+		tracks, err := fetchIn(ctx, in.DB, `
+			SELECT TrackId, Name, AlbumId, MediaTypeId, GenreId, Composer, Milliseconds, Bytes, UnitPrice
+			FROM Track WHERE AlbumId IN (%s) ORDER BY TrackId`, ids, scanTrack)
+		if err != nil {
+			return err
+		}
+		if err := in.Tracks(ctx, tracks, sub); err != nil {
+			return err
+		}
+		byAlbum := groupBy(tracks, func(t models.Track) int { return *t.AlbumId })
+		for i := range albums {
+			albums[i].Tracks = byAlbum[albums[i].ID]
+			if albums[i].Tracks == nil {
+				albums[i].Tracks = []models.Track{}
+			}
+		}
+	}
+	return nil
+}
+
+// Invoices embeds the relations in tree into every invoice, in place.
+func (in *Includer) Invoices(ctx context.Context, invoices []models.Invoice, tree IncludeTree) error {
+	if len(invoices) == 0 || len(tree) == 0 {
+		return nil
+	}
+	ids := make([]int, len(invoices))
+	customerIDs := make([]int, len(invoices))
+	for i, inv := range invoices {
+		ids[i] = inv.InvoiceId
+		customerIDs[i] = inv.CustomerId
+	}
+	if _, ok := tree["customer"]; ok {
+		customers, err := fetchIn(ctx, in.DB, `
+			SELECT CustomerId, FirstName, LastName, Company, Address, City, State, Country,
+			       PostalCode, Phone, Fax, Email, SupportRepId
+			FROM Customer WHERE CustomerId IN (%s)`, customerIDs, scanCustomer)
+		if err != nil {
+			return err
+		}
+		byID := indexBy(customers, func(c models.Customer) int { return c.CustomerId })
+		for i := range invoices {
+			invoices[i].Customer = byID[invoices[i].CustomerId]
+		}
+	}
+	if sub, ok := tree["lines"]; ok {
+		lines, err := fetchIn(ctx, in.DB, `
+			SELECT InvoiceLineId, InvoiceId, TrackId, UnitPrice, Quantity
+			FROM InvoiceLine WHERE InvoiceId IN (%s) ORDER BY InvoiceLineId`, ids, scanInvoiceLine)
+		if err != nil {
+			return err
+		}
+		if err := in.InvoiceLines(ctx, lines, sub); err != nil {
+			return err
+		}
+		byInvoice := groupBy(lines, func(l models.InvoiceLine) int { return l.InvoiceId })
+		for i := range invoices {
+			invoices[i].Lines = byInvoice[invoices[i].InvoiceId]
+			if invoices[i].Lines == nil {
+				invoices[i].Lines = []models.InvoiceLine{}
+			}
+		}
+	}
+	return nil
+}
+
+// InvoiceLines embeds the relations in tree into every invoice line, in place.
+func (in *Includer) InvoiceLines(ctx context.Context, lines []models.InvoiceLine, tree IncludeTree) error {
+	if len(lines) == 0 || len(tree) == 0 {
+		return nil
+	}
+	if sub, ok := tree["track"]; ok {
+		ids := make([]int, len(lines))
+		for i, l := range lines {
+			ids[i] = l.TrackId
+		}
+		tracks, err := fetchIn(ctx, in.DB, `
+			SELECT TrackId, Name, AlbumId, MediaTypeId, GenreId, Composer, Milliseconds, Bytes, UnitPrice
+			FROM Track WHERE TrackId IN (%s)`, ids, scanTrack)
+		if err != nil {
+			return err
+		}
+		if err := in.Tracks(ctx, tracks, sub); err != nil {
+			return err
+		}
+		byID := indexBy(tracks, func(t models.Track) int { return t.TrackId })
+		for i := range lines {
+			lines[i].Track = byID[lines[i].TrackId]
+		}
+	}
+	if sub, ok := tree["invoice"]; ok {
+		ids := make([]int, len(lines))
+		for i, l := range lines {
+			ids[i] = l.InvoiceId
+		}
+		invoices, err := fetchIn(ctx, in.DB, `
+			SELECT InvoiceId, CustomerId, InvoiceDate, BillingAddress, BillingCity,
+			       BillingState, BillingCountry, BillingPostalCode, Total
+			FROM Invoice WHERE InvoiceId IN (%s)`, ids, scanInvoice)
+		if err != nil {
+			return err
+		}
+		if err := in.Invoices(ctx, invoices, sub); err != nil {
+			return err
+		}
+		byID := indexBy(invoices, func(inv models.Invoice) int { return inv.InvoiceId })
+		for i := range lines {
+			lines[i].Invoice = byID[lines[i].InvoiceId]
+		}
+	}
+	return nil
+}
+
+func scanArtist(rows *sql.Rows) (models.Artist, error) {
+	var a models.Artist
+	var name sql.NullString
+	err := rows.Scan(&a.ID, &name)
+	a.Name = name.String
+	return a, err
+}
+
+func scanAlbum(rows *sql.Rows) (models.Album, error) {
+	var a models.Album
+	err := rows.Scan(&a.ID, &a.Title, &a.ArtistID)
+	return a, err
+}
+
+func scanTrack(rows *sql.Rows) (models.Track, error) {
+	var t models.Track
+	err := rows.Scan(&t.TrackId, &t.Name, &t.AlbumId, &t.MediaTypeId, &t.GenreId,
+		&t.Composer, &t.Milliseconds, &t.Bytes, &t.UnitPrice)
+	return t, err
+}
+
+func scanGenre(rows *sql.Rows) (models.Genre, error) {
+	var g models.Genre
+	err := rows.Scan(&g.GenreId, &g.Name)
+	return g, err
+}
+
+func scanMediaType(rows *sql.Rows) (models.MediaType, error) {
+	var m models.MediaType
+	err := rows.Scan(&m.MediaTypeId, &m.Name)
+	return m, err
+}
+
+func scanCustomer(rows *sql.Rows) (models.Customer, error) {
+	var c models.Customer
+	err := rows.Scan(&c.CustomerId, &c.FirstName, &c.LastName, &c.Company, &c.Address,
+		&c.City, &c.State, &c.Country, &c.PostalCode, &c.Phone, &c.Fax, &c.Email, &c.SupportRepId)
+	return c, err
+}
+
+func scanInvoice(rows *sql.Rows) (models.Invoice, error) {
+	var inv models.Invoice
+	err := rows.Scan(&inv.InvoiceId, &inv.CustomerId, &inv.InvoiceDate, &inv.BillingAddress,
+		&inv.BillingCity, &inv.BillingState, &inv.BillingCountry, &inv.BillingPostalCode, &inv.Total)
+	return inv, err
+}
+
+func scanInvoiceLine(rows *sql.Rows) (models.InvoiceLine, error) {
+	var l models.InvoiceLine
+	err := rows.Scan(&l.InvoiceLineId, &l.InvoiceId, &l.TrackId, &l.UnitPrice, &l.Quantity)
+	return l, err
+}
+
+// fetchIn runs query, whose %s is replaced by the placeholder list, once per
+// batch of distinct ids and returns every scanned row.
+func fetchIn[T any](ctx context.Context, db *database.DB, query string, ids []int, scan func(*sql.Rows) (T, error)) ([]T, error) {
+	ids = distinct(ids)
+	var out []T
+	for start := 0; start < len(ids); start += includeBatchSize {
+		batch := ids[start:min(start+includeBatchSize, len(ids))]
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+		rows, err := db.QueryContext(ctx, fmt.Sprintf(query, placeholders), args...)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to load included rows")
+			return nil, fmt.Errorf("error loading includes: %w", err)
+		}
+		for rows.Next() {
+			v, err := scan(rows)
+			if err != nil {
+				rows.Close()
+				log.Error().Err(err).Msg("failed to scan included row")
+				return nil, fmt.Errorf("error scanning includes: %w", err)
+			}
+			out = append(out, v)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error loading includes: %w", err)
+		}
+	}
+	return out, nil
+}
+
+func distinct(ids []int) []int {
+	sorted := slices.Clone(ids)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+func indexBy[T any](items []T, key func(T) int) map[int]*T {
+	byKey := make(map[int]*T, len(items))
+	for i := range items {
+		byKey[key(items[i])] = &items[i]
+	}
+	return byKey
+}
+
+func groupBy[T any](items []T, key func(T) int) map[int][]T {
+	groups := map[int][]T{}
+	for _, item := range items {
+		k := key(item)
+		groups[k] = append(groups[k], item)
+	}
+	return groups
+}
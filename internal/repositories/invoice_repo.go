@@ -1,47 +1,69 @@
 package repositories
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 
 	"github.com/rs/zerolog/log"
 )
 
 type InvoiceRepository struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
-func (r *InvoiceRepository) GetAllInvoices(ctx context.Context) ([]models.Invoice, error) {
+// GetAllInvoices returns the invoices matching q.
+func (r *InvoiceRepository) GetAllInvoices(ctx context.Context, q ListQuery) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	err := r.EachInvoice(ctx, q, func(invoice models.Invoice) error {
+		invoices = append(invoices, invoice)
+		return nil
+	})
+	return invoices, err
+}
+
+// CountInvoices returns the number of invoices matching q.
+func (r *InvoiceRepository) CountInvoices(ctx context.Context, q ListQuery) (int, error) {
+	return q.count(ctx, r.DB, "Invoice")
+}
+
+// EachInvoice calls fn with each invoice matching q as it is read, so that
+// callers can stream the result. It stops at the first error fn returns.
+func (r *InvoiceRepository) EachInvoice(ctx context.Context, q ListQuery, fn func(models.Invoice) error) error {
+	clause, args := q.clause()
 	rows, err := r.DB.QueryContext(ctx, `
 		SELECT
 			InvoiceId, CustomerId, InvoiceDate, BillingAddress, BillingCity,
 			BillingState, BillingCountry, BillingPostalCode, Total
 		FROM Invoice
-	`)
+	`+clause, args...)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to query invoices")
-		return nil, fmt.Errorf("error fetching invoices: %w", err)
+		return fmt.Errorf("error fetching invoices: %w", err)
 	}
 	defer rows.Close()
 
-	var invoices []models.Invoice
 	for rows.Next() {
 		var invoice models.Invoice
 		if err := rows.Scan(&invoice.InvoiceId, &invoice.CustomerId, &invoice.InvoiceDate,
 			&invoice.BillingAddress, &invoice.BillingCity, &invoice.BillingState,
 			&invoice.BillingCountry, &invoice.BillingPostalCode, &invoice.Total); err != nil {
 			log.Error().Err(err).Msg("failed to scan invoice")
-			return nil, fmt.Errorf("error scanning invoice: %w", err)
+			return fmt.Errorf("error scanning invoice: %w", err)
+		}
+		if err := fn(invoice); err != nil {
+			return err
 		}
-		invoices = append(invoices, invoice)
 	}
 	if err := rows.Err(); err != nil {
 		log.Error().Err(err).Msg("error iterating over invoices")
-		return nil, fmt.Errorf("error iterating over invoices: %w", err)
+		return fmt.Errorf("error iterating over invoices: %w", err)
 	}
-	return invoices, nil
+	return nil
 }
 
 func (r *InvoiceRepository) GetInvoiceByID(ctx context.Context, id int) (models.Invoice, error) {
@@ -57,7 +79,7 @@ func (r *InvoiceRepository) GetInvoiceByID(ctx context.Context, id int) (models.
 		&invoice.BillingCountry, &invoice.BillingPostalCode, &invoice.Total)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.Invoice{}, fmt.Errorf("invoice with ID %d not found", id)
+			return models.Invoice{}, apperr.NotFound("invoice_not_found", "invoice with ID %d not found", id)
 		}
 		log.Error().Err(err).Msg("failed to scan invoice by ID")
 		return models.Invoice{}, fmt.Errorf("error scanning invoice by ID: %w", err)
@@ -92,4 +114,126 @@ func (r *InvoiceRepository) GetInvoiceLinesByInvoiceID(ctx context.Context, invo
         return nil, fmt.Errorf("error iterating over invoice lines: %w", err)
     }
     return lines, nil
-}
\ No newline at end of file
+}
+
+// GetInvoicesByCustomerID returns a page of the customer's invoices, oldest
+// first, and their total count.
+func (r *InvoiceRepository) GetInvoicesByCustomerID(ctx context.Context, customerID, limit, offset int) ([]models.Invoice, int, error) {
+	var total int
+	if err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM Invoice WHERE CustomerId = ?", customerID).Scan(&total); err != nil {
+		log.Error().Err(err).Int("customer_id", customerID).Msg("failed to count invoices for customer")
+		return nil, 0, fmt.Errorf("error counting invoices: %w", err)
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT
+			InvoiceId, CustomerId, InvoiceDate, BillingAddress, BillingCity,
+			BillingState, BillingCountry, BillingPostalCode, Total
+		FROM Invoice
+		WHERE CustomerId = ?
+		ORDER BY InvoiceDate, InvoiceId
+		LIMIT ? OFFSET ?
+	`, customerID, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Int("customer_id", customerID).Msg("failed to query invoices for customer")
+		return nil, 0, fmt.Errorf("error fetching invoices: %w", err)
+	}
+	defer rows.Close()
+
+	invoices := []models.Invoice{}
+	for rows.Next() {
+		var invoice models.Invoice
+		if err := rows.Scan(&invoice.InvoiceId, &invoice.CustomerId, &invoice.InvoiceDate,
+			&invoice.BillingAddress, &invoice.BillingCity, &invoice.BillingState,
+			&invoice.BillingCountry, &invoice.BillingPostalCode, &invoice.Total); err != nil {
+			log.Error().Err(err).Msg("failed to scan invoice")
+			return nil, 0, fmt.Errorf("error scanning invoice: %w", err)
+		}
+		invoices = append(invoices, invoice)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over invoices")
+		return nil, 0, fmt.Errorf("error iterating over invoices: %w", err)
+	}
+	return invoices, total, nil
+}
+
+// GetInvoiceDetail returns the invoice joined with its customer and with the
+// track, album and artist of every line, and checks that the line subtotals
+// add up to the invoice total.
+func (r *InvoiceRepository) GetInvoiceDetail(ctx context.Context, id int) (models.InvoiceDetail, error) {
+	var detail models.InvoiceDetail
+	addr := &detail.BillingAddress
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT
+			i.InvoiceId, i.InvoiceDate, i.BillingAddress, i.BillingCity, i.BillingState,
+			i.BillingCountry, i.BillingPostalCode, i.Total,
+			c.CustomerId, c.FirstName, c.LastName, c.Email
+		FROM Invoice i
+		JOIN Customer c ON c.CustomerId = i.CustomerId
+		WHERE i.InvoiceId = ?
+	`, id).Scan(&detail.InvoiceId, &detail.InvoiceDate, &addr.Address, &addr.City, &addr.State,
+		&addr.Country, &addr.PostalCode, &detail.Total,
+		&detail.Customer.CustomerId, &detail.Customer.FirstName, &detail.Customer.LastName, &detail.Customer.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.InvoiceDetail{}, apperr.NotFound("invoice_not_found", "invoice with ID %d not found", id)
+		}
+		log.Error().Err(err).Int("id", id).Msg("failed to query invoice detail")
+		return models.InvoiceDetail{}, fmt.Errorf("error fetching invoice detail: %w", err)
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT
+			il.InvoiceLineId, il.TrackId, t.Name, al.Title, ar.Name, il.UnitPrice, il.Quantity
+		FROM InvoiceLine il
+		JOIN Track t ON t.TrackId = il.TrackId
+		LEFT JOIN Album al ON al.AlbumId = t.AlbumId
+		LEFT JOIN Artist ar ON ar.ArtistId = al.ArtistId
+		WHERE il.InvoiceId = ?
+		ORDER BY il.InvoiceLineId
+	`, id)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to query invoice detail lines")
+		return models.InvoiceDetail{}, fmt.Errorf("error fetching invoice lines: %w", err)
+	}
+	defer rows.Close()
+
+	detail.Lines = []models.InvoiceDetailLine{}
+	var linesCents int64
+	for rows.Next() {
+		var line models.InvoiceDetailLine
+		if err := rows.Scan(&line.InvoiceLineId, &line.TrackId, &line.TrackName, &line.AlbumTitle,
+			&line.ArtistName, &line.UnitPrice, &line.Quantity); err != nil {
+			log.Error().Err(err).Msg("failed to scan invoice detail line")
+			return models.InvoiceDetail{}, fmt.Errorf("error scanning invoice line: %w", err)
+		}
+		subtotal := toCents(line.UnitPrice) * int64(line.Quantity)
+		line.Subtotal = fromCents(subtotal)
+		linesCents += subtotal
+		detail.Lines = append(detail.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over invoice detail lines")
+		return models.InvoiceDetail{}, fmt.Errorf("error iterating over invoice lines: %w", err)
+	}
+
+	totalCents := toCents(detail.Total)
+	detail.LinesTotal = fromCents(linesCents)
+	detail.Difference = fromCents(totalCents - linesCents)
+	detail.TotalMatches = totalCents == linesCents
+	if !detail.TotalMatches {
+		log.Warn().Int("id", id).Float64("total", detail.Total).Float64("lines_total", detail.LinesTotal).
+			Msg("Invoice total does not match its lines")
+	}
+	return detail, nil
+}
+
+// toCents converts a NUMERIC(10,2) amount read as float64 to whole cents.
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,267 @@
+package repositories
+
+import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// JobRepository stores background jobs with their params, progress and
+// result.
+type JobRepository struct {
+	DB *database.DB
+}
+
+// JobResult is the file a job produced.
+type JobResult struct {
+	ContentType string
+	Filename    string
+	Body        []byte
+}
+
+var (
+	ErrJobNotFound = apperr.NotFound("job_not_found", "job not found")
+	// ErrJobNoResult is returned for the result of a job that has none,
+	// because it has not finished or failed before producing one.
+	ErrJobNoResult = apperr.Conflict("job_result_unavailable", "the job has no result")
+)
+
+const jobColumns = `
+	JobId, Type, Owner, Status, Done, Total, Attempts, MaxAttempts,
+	ErrorCode, ErrorDetail, Result IS NOT NULL, CreatedAt, RunAt, StartedAt, FinishedAt
+`
+
+// CreateJob queues a job of type typ with its params encoded as JSON.
+func (r *JobRepository) CreateJob(ctx context.Context, typ, owner string, params []byte, maxAttempts int) (models.Job, error) {
+	now := time.Now().Unix()
+	id, err := r.DB.InsertReturningID(ctx, "JobId", `
+		INSERT INTO Job (Type, Owner, Params, Status, MaxAttempts, CreatedAt, RunAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, typ, owner, string(params), models.JobQueued, maxAttempts, now, now)
+	if err != nil {
+		log.Error().Err(err).Str("type", typ).Msg("failed to create job")
+		return models.Job{}, fmt.Errorf("error creating job: %w", err)
+	}
+	return r.GetJob(ctx, int(id))
+}
+
+func (r *JobRepository) GetJob(ctx context.Context, id int) (models.Job, error) {
+	job, err := scanJob(r.DB.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM Job WHERE JobId = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, ErrJobNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to get job")
+		return job, fmt.Errorf("error fetching job: %w", err)
+	}
+	return job, nil
+}
+
+// ClaimJob marks the queued job that is due first as running and returns it
+// with its params, or nil when no job is due.
+func (r *JobRepository) ClaimJob(ctx context.Context) (*models.Job, []byte, error) {
+	now := time.Now().Unix()
+	for {
+		var id int
+		var params string
+		err := r.DB.QueryRowContext(ctx, `
+			SELECT JobId, Params FROM Job
+			WHERE Status = ? AND RunAt <= ?
+			ORDER BY RunAt, JobId
+			LIMIT 1
+		`, models.JobQueued, now).Scan(&id, &params)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to find due job")
+			return nil, nil, fmt.Errorf("error finding due job: %w", err)
+		}
+
+		result, err := r.DB.ExecContext(ctx, `
+			UPDATE Job SET Status = ?, Attempts = Attempts + 1, StartedAt = ?, FinishedAt = NULL
+			WHERE JobId = ? AND Status = ?
+		`, models.JobRunning, now, id, models.JobQueued)
+		if err != nil {
+			log.Error().Err(err).Int("id", id).Msg("failed to claim job")
+			return nil, nil, fmt.Errorf("error claiming job: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			// canceled in the meantime
+			continue
+		}
+		job, err := r.GetJob(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &job, []byte(params), nil
+	}
+}
+
+// SetProgress records how much of a running job is done.
+func (r *JobRepository) SetProgress(ctx context.Context, id int, progress models.JobProgress) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE Job SET Done = ?, Total = ? WHERE JobId = ?", progress.Done, progress.Total, id)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to record job progress")
+		return fmt.Errorf("error recording job progress: %w", err)
+	}
+	return nil
+}
+
+// FinishJob ends a running job with status, the error of its last attempt
+// if any, and its result if it produced one.
+func (r *JobRepository) FinishJob(ctx context.Context, id int, status string, jobErr *models.JobError, result *JobResult) error {
+	var code, detail sql.NullString
+	if jobErr != nil {
+		code = sql.NullString{String: jobErr.Code, Valid: true}
+		detail = sql.NullString{String: jobErr.Detail, Valid: true}
+	}
+	var contentType, filename sql.NullString
+	var body []byte
+	if result != nil {
+		contentType = sql.NullString{String: result.ContentType, Valid: true}
+		filename = sql.NullString{String: result.Filename, Valid: true}
+		body = result.Body
+		if body == nil {
+			body = []byte{}
+		}
+	}
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE Job SET Status = ?, ErrorCode = ?, ErrorDetail = ?, ResultType = ?, ResultName = ?, Result = ?, FinishedAt = ?
+		WHERE JobId = ?
+	`, status, code, detail, contentType, filename, body, time.Now().Unix(), id)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to finish job")
+		return fmt.Errorf("error finishing job: %w", err)
+	}
+	return nil
+}
+
+// RetryJob queues a job whose attempt failed to run again at runAt.
+func (r *JobRepository) RetryJob(ctx context.Context, id int, runAt time.Time, jobErr models.JobError) error {
+	_, err := r.DB.ExecContext(ctx, `
+		UPDATE Job SET Status = ?, ErrorCode = ?, ErrorDetail = ?, RunAt = ?, Done = 0, Total = NULL
+		WHERE JobId = ?
+	`, models.JobQueued, jobErr.Code, jobErr.Detail, runAt.Unix(), id)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to schedule job retry")
+		return fmt.Errorf("error scheduling job retry: %w", err)
+	}
+	return nil
+}
+
+// RequeueJobs puts running jobs back in the queue without counting the
+// attempt, for jobs interrupted by a shutdown. With no ids it requeues every
+// running job, which at startup are those a previous process left behind.
+func (r *JobRepository) RequeueJobs(ctx context.Context, ids ...int) (int, error) {
+	query := "UPDATE Job SET Status = ?, Attempts = Attempts - 1, Done = 0, Total = NULL WHERE Status = ?"
+	args := []any{models.JobQueued, models.JobRunning}
+	if len(ids) > 0 {
+		query += " AND JobId IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	result, err := r.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to requeue jobs")
+		return 0, fmt.Errorf("error requeuing jobs: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// CancelQueuedJob cancels the job if it has not started, and reports
+// whether it did.
+func (r *JobRepository) CancelQueuedJob(ctx context.Context, id int) (bool, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE Job SET Status = ?, FinishedAt = ? WHERE JobId = ? AND Status = ?
+	`, models.JobCanceled, time.Now().Unix(), id, models.JobQueued)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to cancel job")
+		return false, fmt.Errorf("error canceling job: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetJobResult returns the file job id produced.
+func (r *JobRepository) GetJobResult(ctx context.Context, id int) (JobResult, error) {
+	var result JobResult
+	var contentType, filename sql.NullString
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT ResultType, ResultName, Result FROM Job WHERE JobId = ?
+	`, id).Scan(&contentType, &filename, &result.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result, ErrJobNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("failed to get job result")
+		return result, fmt.Errorf("error fetching job result: %w", err)
+	}
+	if !contentType.Valid {
+		return result, ErrJobNoResult
+	}
+	result.ContentType, result.Filename = contentType.String, filename.String
+	return result, nil
+}
+
+// PurgeJobs deletes jobs that finished before before, with their results.
+func (r *JobRepository) PurgeJobs(ctx context.Context, before time.Time) (int, error) {
+	result, err := r.DB.ExecContext(ctx, `
+		DELETE FROM Job WHERE Status IN (?, ?, ?) AND FinishedAt < ?
+	`, models.JobSucceeded, models.JobFailed, models.JobCanceled, before.Unix())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to purge jobs")
+		return 0, fmt.Errorf("error purging jobs: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+func scanJob(row *sql.Row) (models.Job, error) {
+	var job models.Job
+	var total sql.NullInt64
+	var code, detail sql.NullString
+	var createdAt, runAt int64
+	var startedAt, finishedAt sql.NullInt64
+	err := row.Scan(
+		&job.ID, &job.Type, &job.Owner, &job.Status, &job.Progress.Done, &total,
+		&job.Attempts, &job.MaxAttempts, &code, &detail, &job.HasResult,
+		&createdAt, &runAt, &startedAt, &finishedAt,
+	)
+	if err != nil {
+		return job, err
+	}
+	if total.Valid {
+		n := int(total.Int64)
+		job.Progress.Total = &n
+	}
+	if code.Valid {
+		job.Error = &models.JobError{Code: code.String, Detail: detail.String}
+	}
+	job.CreatedAt = time.Unix(createdAt, 0).UTC()
+	if job.Status == models.JobQueued {
+		next := time.Unix(runAt, 0).UTC()
+		job.NextRunAt = &next
+	}
+	job.StartedAt = unixTime(startedAt)
+	job.FinishedAt = unixTime(finishedAt)
+	return job, nil
+}
+
+func unixTime(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0).UTC()
+	return &t
+}
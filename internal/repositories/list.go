@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+
+	"github.com/rs/zerolog/log"
+)
+
+// listField is a field of a list endpoint that can be sorted on, named by
+// its JSON name. Fields with a filter kind can also be filtered on by
+// equality, as ?<name>=<value>.
+type listField struct {
+	column string
+	filter filterKind
+}
+
+type filterKind int
+
+const (
+	noFilter filterKind = iota
+	intFilter
+	textFilter
+)
+
+// listFields is the allow-list of sortable and filterable fields of each
+// list endpoint.
+var listFields = map[string]map[string]listField{
+	"invoice": {
+		"invoice_id":          {"InvoiceId", intFilter},
+		"customer_id":         {"CustomerId", intFilter},
+		"invoice_date":        {"InvoiceDate", noFilter},
+		"billing_city":        {"BillingCity", textFilter},
+		"billing_state":       {"BillingState", textFilter},
+		"billing_country":     {"BillingCountry", textFilter},
+		"billing_postal_code": {"BillingPostalCode", textFilter},
+		"total":               {"Total", noFilter},
+	},
+	"customer": {
+		"customer_id":    {"CustomerId", intFilter},
+		"first_name":     {"FirstName", textFilter},
+		"last_name":      {"LastName", textFilter},
+		"company":        {"Company", textFilter},
+		"city":           {"City", textFilter},
+		"state":          {"State", textFilter},
+		"country":        {"Country", textFilter},
+		"postal_code":    {"PostalCode", textFilter},
+		"email":          {"Email", textFilter},
+		"support_rep_id": {"SupportRepId", intFilter},
+	},
+	"track": {
+		"track_id":      {"TrackId", intFilter},
+		"name":          {"Name", textFilter},
+		"album_id":      {"AlbumId", intFilter},
+		"media_type_id": {"MediaTypeId", intFilter},
+		"genre_id":      {"GenreId", intFilter},
+		"composer":      {"Composer", textFilter},
+		"milliseconds":  {"Milliseconds", noFilter},
+		"bytes":         {"Bytes", noFilter},
+		"unit_price":    {"UnitPrice", noFilter},
+	},
+}
+
+// listKeys are the primary keys of the list endpoints, which break ties in
+// the order so that it is stable between pages.
+var listKeys = map[string]string{
+	"invoice":  "InvoiceId",
+	"customer": "CustomerId",
+	"track":    "TrackId",
+}
+
+// ListQuery is the filter, order and page of a list request.
+type ListQuery struct {
+	resource string
+	filters  []listFilter
+	sort     []listSort
+	// Limit of 0 returns every matching row.
+	Limit  int
+	Offset int
+}
+
+type listFilter struct {
+	column string
+	value  any
+}
+
+type listSort struct {
+	column string
+	desc   bool
+}
+
+// ParseListQuery reads the equality filters and the sort parameter of a
+// list request for resource, e.g. ?billing_country=USA&sort=-total,invoice_id
+// on invoices. Query parameters that are not fields of the resource are left
+// to the caller; the sort parameter may only name fields on the allow-list.
+func ParseListQuery(resource string, values url.Values) (ListQuery, error) {
+	q := ListQuery{resource: resource}
+	fields := listFields[resource]
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, ok := fields[name]
+		if !ok || field.filter == noFilter {
+			continue
+		}
+		raw := values.Get(name)
+		switch field.filter {
+		case intFilter:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return q, apperr.Field(name, "must be an integer")
+			}
+			q.filters = append(q.filters, listFilter{field.column, n})
+		case textFilter:
+			q.filters = append(q.filters, listFilter{field.column, raw})
+		}
+	}
+
+	for _, key := range strings.Split(values.Get("sort"), ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		desc := strings.HasPrefix(key, "-")
+		name := strings.TrimPrefix(key, "-")
+		field, ok := fields[name]
+		if !ok {
+			return q, apperr.Field("sort", fmt.Sprintf("cannot sort %s by %q; allowed: %s", resource, name, sortableFields(resource)))
+		}
+		q.sort = append(q.sort, listSort{field.column, desc})
+	}
+	return q, nil
+}
+
+func sortableFields(resource string) string {
+	var names []string
+	for name := range listFields[resource] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// clause returns the WHERE, ORDER BY and LIMIT clauses of the query and
+// their arguments. Column names come from the allow-list, never from the
+// request.
+func (q ListQuery) clause() (string, []any) {
+	var b strings.Builder
+	var args []any
+	for i, f := range q.filters {
+		if i == 0 {
+			b.WriteString(" WHERE ")
+		} else {
+			b.WriteString(" AND ")
+		}
+		b.WriteString(f.column + " = ?")
+		args = append(args, f.value)
+	}
+
+	key := listKeys[q.resource]
+	b.WriteString(" ORDER BY ")
+	keyed := false
+	for _, s := range q.sort {
+		b.WriteString(s.column)
+		if s.desc {
+			b.WriteString(" DESC")
+		}
+		b.WriteString(", ")
+		keyed = keyed || s.column == key
+	}
+	if keyed {
+		// the key already decides the order
+		return strings.TrimSuffix(b.String(), ", ") + q.page(&args), args
+	}
+	b.WriteString(key)
+	return b.String() + q.page(&args), args
+}
+
+func (q ListQuery) page(args *[]any) string {
+	if q.Limit <= 0 && q.Offset <= 0 {
+		return ""
+	}
+	limit := int64(q.Limit)
+	if limit <= 0 {
+		// SQLite only takes an OFFSET after a LIMIT
+		limit = math.MaxInt64
+	}
+	*args = append(*args, limit, q.Offset)
+	return " LIMIT ? OFFSET ?"
+}
+
+// count returns the number of rows of table that q matches, within its
+// limit and offset.
+func (q ListQuery) count(ctx context.Context, db *database.DB, table string) (int, error) {
+	clause, args := q.clause()
+	var n int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM (SELECT 1 FROM "+table+clause+") matched", args...).Scan(&n)
+	if err != nil {
+		log.Error().Err(err).Str("table", table).Msg("failed to count rows")
+		return 0, fmt.Errorf("error counting %s rows: %w", table, err)
+	}
+	return n, nil
+}
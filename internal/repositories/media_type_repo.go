@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
 	"context"
 	"database/sql"
@@ -10,7 +12,7 @@ import (
 )
 
 type MediaTypeRepository struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
 func (r *MediaTypeRepository) GetAllMediaTypes(ctx context.Context) ([]models.MediaType, error) {
@@ -52,7 +54,7 @@ func (r *MediaTypeRepository) GetMediaTypeByID(ctx context.Context, id int) (mod
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.MediaType{}, fmt.Errorf("media type with ID %d not found", id)
+			return models.MediaType{}, apperr.NotFound("media_type_not_found", "media type with ID %d not found", id)
 		}
 		log.Error().Err(err).Msg("failed to query media type by ID")
 		return models.MediaType{}, fmt.Errorf("error fetching media type by ID: %w", err)
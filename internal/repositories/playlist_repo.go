@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
 	"context"
 	"database/sql"
@@ -10,7 +12,7 @@ import (
 )
 
 type PlaylistRepository struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
 func (r *PlaylistRepository) GetAllPlaylists(ctx context.Context) ([]models.Playlist, error) {
@@ -52,10 +54,60 @@ func (r *PlaylistRepository) GetPlaylistByID(ctx context.Context, id int) (model
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.Playlist{}, fmt.Errorf("playlist with ID %d not found", id)
+			return models.Playlist{}, apperr.NotFound("playlist_not_found", "playlist with ID %d not found", id)
 		}
 		log.Error().Err(err).Msg("failed to query playlist by ID")
 		return models.Playlist{}, fmt.Errorf("error fetching playlist by ID: %w", err)
 	}
 	return playlist, nil
 }
+
+func (r *PlaylistRepository) UpdatePlaylist(ctx context.Context, playlist models.Playlist) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE Playlist SET Name = ? WHERE PlaylistId = ?", playlist.Name, playlist.PlaylistId)
+	if err != nil {
+		log.Error().Err(err).Int("id", playlist.PlaylistId).Msg("failed to update playlist")
+		return fmt.Errorf("error updating playlist: %w", err)
+	}
+	log.Info().Int("id", playlist.PlaylistId).Msg("Playlist updated")
+	return nil
+}
+
+// GetPlaylistsByTrackID returns a page of the playlists containing the track
+// and their total count.
+func (r *PlaylistRepository) GetPlaylistsByTrackID(ctx context.Context, trackID, limit, offset int) ([]models.Playlist, int, error) {
+	var total int
+	if err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM PlaylistTrack WHERE TrackId = ?", trackID).Scan(&total); err != nil {
+		log.Error().Err(err).Int("track_id", trackID).Msg("failed to count playlists for track")
+		return nil, 0, fmt.Errorf("error counting playlists: %w", err)
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT
+			Playlist.PlaylistId, Playlist.Name
+		FROM Playlist
+		JOIN PlaylistTrack ON PlaylistTrack.PlaylistId = Playlist.PlaylistId
+		WHERE PlaylistTrack.TrackId = ?
+		ORDER BY Playlist.PlaylistId
+		LIMIT ? OFFSET ?
+	`, trackID, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Int("track_id", trackID).Msg("failed to query playlists for track")
+		return nil, 0, fmt.Errorf("error fetching playlists: %w", err)
+	}
+	defer rows.Close()
+
+	playlists := []models.Playlist{}
+	for rows.Next() {
+		var playlist models.Playlist
+		if err := rows.Scan(&playlist.PlaylistId, &playlist.Name); err != nil {
+			log.Error().Err(err).Msg("failed to scan playlist")
+			return nil, 0, fmt.Errorf("error scanning playlist: %w", err)
+		}
+		playlists = append(playlists, playlist)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over playlists")
+		return nil, 0, fmt.Errorf("error iterating over playlists: %w", err)
+	}
+	return playlists, total, nil
+}
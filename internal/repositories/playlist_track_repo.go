@@ -1,16 +1,16 @@
 package repositories
 
 import (
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
 	"context"
-	"database/sql"
 	"fmt"
 
 	"github.com/rs/zerolog/log"
 )
 
 type PlaylistTrackRepository struct {
-	DB *sql.DB
+	DB *database.DB
 }
 
 func (r *PlaylistTrackRepository) GetTracksByPlaylistID(ctx context.Context, playlistId int) ([]models.Track, error) {
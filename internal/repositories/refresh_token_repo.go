@@ -1,8 +1,8 @@
 package repositories
 
 import (
+	"chinook-api/internal/database"
 	"context"
-	"database/sql"
 	"time"
 )
 
@@ -13,7 +13,7 @@ type RefreshToken struct {
 }
 
 type RefreshTokenRepository struct {
-    DB *sql.DB
+    DB *database.DB
 }
 
 func (r *RefreshTokenRepository) Save(ctx context.Context, token, username string, expiresAt time.Time) error {
@@ -38,4 +38,9 @@ func (r *RefreshTokenRepository) Get(ctx context.Context, token string) (Refresh
 func (r *RefreshTokenRepository) Delete(ctx context.Context, token string) error {
     _, err := r.DB.ExecContext(ctx, "DELETE FROM Refresh_Tokens WHERE token = ?", token)
     return err
-}
\ No newline at end of file
+}
+// DeleteByUsername revokes every refresh token issued to username.
+func (r *RefreshTokenRepository) DeleteByUsername(ctx context.Context, username string) error {
+    _, err := r.DB.ExecContext(ctx, "DELETE FROM Refresh_Tokens WHERE username = ?", username)
+    return err
+}
@@ -0,0 +1,324 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ReportRepository builds the sales rep and manager reports from invoices,
+// Customer.SupportRepId and the Employee.ReportsTo hierarchy.
+type ReportRepository struct {
+	DB *database.DB
+}
+
+type repPeriod struct {
+	customers int
+	invoices  int
+	revenue   int64 // cents
+}
+
+// orgEmployee is one employee with the sales of the customers they support.
+type orgEmployee struct {
+	id        int
+	name      string
+	title     *string
+	reportsTo *int
+	assigned  int
+	customers int
+	periods   map[string]*repPeriod
+}
+
+// org is the employee hierarchy with each rep's sales for one filter.
+type org struct {
+	employees map[int]*orgEmployee
+	children  map[int][]int
+}
+
+// subtree returns root and everyone below it. Employees already visited are
+// skipped, so a ReportsTo cycle cannot loop forever.
+func (o *org) subtree(root int) []int {
+	if _, ok := o.employees[root]; !ok {
+		return nil
+	}
+	ids := []int{root}
+	seen := map[int]bool{root: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range o.children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+// scope returns the employees visible from root, or everyone when root is nil.
+func (o *org) scope(root *int) []int {
+	if root != nil {
+		return o.subtree(*root)
+	}
+	ids := make([]int, 0, len(o.employees))
+	for id := range o.employees {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (r *ReportRepository) loadOrg(ctx context.Context, f SalesFilter) (*org, error) {
+	o := &org{employees: map[int]*orgEmployee{}, children: map[int][]int{}}
+
+	rows, err := r.DB.QueryContext(ctx, "SELECT EmployeeId, FirstName, LastName, Title, ReportsTo FROM Employee ORDER BY EmployeeId")
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query employees")
+		return nil, fmt.Errorf("error fetching employees: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &orgEmployee{periods: map[string]*repPeriod{}}
+		var first, last string
+		if err := rows.Scan(&e.id, &first, &last, &e.title, &e.reportsTo); err != nil {
+			log.Error().Err(err).Msg("failed to scan employee")
+			return nil, fmt.Errorf("error scanning employee: %w", err)
+		}
+		e.name = first + " " + last
+		o.employees[e.id] = e
+		if e.reportsTo != nil && *e.reportsTo != e.id {
+			o.children[*e.reportsTo] = append(o.children[*e.reportsTo], e.id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over employees")
+		return nil, fmt.Errorf("error iterating over employees: %w", err)
+	}
+
+	if err := r.scanPerRep(ctx, o, `
+		SELECT SupportRepId, COUNT(*) FROM Customer
+		WHERE SupportRepId IS NOT NULL GROUP BY SupportRepId`, nil,
+		func(e *orgEmployee, n int) { e.assigned = n }); err != nil {
+		return nil, err
+	}
+
+	conds, args := f.conditions()
+	where := " WHERE " + strings.Join(append([]string{"c.SupportRepId IS NOT NULL"}, conds...), " AND ")
+	if err := r.scanPerRep(ctx, o, `
+		SELECT c.SupportRepId, COUNT(DISTINCT c.CustomerId)
+		FROM Invoice i JOIN Customer c ON c.CustomerId = i.CustomerId`+where+`
+		GROUP BY c.SupportRepId`, args,
+		func(e *orgEmployee, n int) { e.customers = n }); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.DB.QueryContext(ctx, `
+		SELECT c.SupportRepId, `+salesBuckets[r.DB.Dialect][f.Period]+`,
+		       COUNT(DISTINCT c.CustomerId), COUNT(*), SUM(i.Total)
+		FROM Invoice i JOIN Customer c ON c.CustomerId = i.CustomerId`+where+`
+		GROUP BY 1, 2`, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query rep sales")
+		return nil, fmt.Errorf("error fetching rep sales: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var period string
+		var p repPeriod
+		var revenue float64
+		if err := rows.Scan(&id, &period, &p.customers, &p.invoices, &revenue); err != nil {
+			log.Error().Err(err).Msg("failed to scan rep sales")
+			return nil, fmt.Errorf("error scanning rep sales: %w", err)
+		}
+		p.revenue = toCents(revenue)
+		if e, ok := o.employees[id]; ok {
+			e.periods[period] = &p
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over rep sales")
+		return nil, fmt.Errorf("error iterating over rep sales: %w", err)
+	}
+	return o, nil
+}
+
+// scanPerRep runs a query returning (employee id, count) rows and applies
+// each count to its employee.
+func (r *ReportRepository) scanPerRep(ctx context.Context, o *org, query string, args []any, apply func(*orgEmployee, int)) error {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query customer counts")
+		return fmt.Errorf("error fetching customer counts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			log.Error().Err(err).Msg("failed to scan customer count")
+			return fmt.Errorf("error scanning customer count: %w", err)
+		}
+		if e, ok := o.employees[id]; ok {
+			apply(e, n)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over customer counts")
+		return fmt.Errorf("error iterating over customer counts: %w", err)
+	}
+	return nil
+}
+
+// RepSales reports every support rep visible from root (everyone when root
+// is nil), highest revenue first.
+func (r *ReportRepository) RepSales(ctx context.Context, f SalesFilter, plan models.CommissionPlan, root *int) ([]models.RepSalesReport, error) {
+	o, err := r.loadOrg(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []models.RepSalesReport{}
+	for _, id := range o.scope(root) {
+		e := o.employees[id]
+		if e.assigned == 0 && len(e.periods) == 0 {
+			continue
+		}
+		report := models.RepSalesReport{
+			EmployeeId:        e.id,
+			Name:              e.name,
+			Title:             e.title,
+			ReportsTo:         e.reportsTo,
+			AssignedCustomers: e.assigned,
+			Periods:           []models.PeriodSales{},
+		}
+		var total repPeriod
+		var commission int64
+		for _, period := range sortedPeriods(e.periods) {
+			p := *e.periods[period]
+			c := repCommission(plan, p.revenue)
+			report.Periods = append(report.Periods, models.PeriodSales{Period: period, SalesFigures: figures(p, c)})
+			total.invoices += p.invoices
+			total.revenue += p.revenue
+			commission += c
+		}
+		total.customers = e.customers
+		report.SalesFigures = figures(total, commission)
+		reports = append(reports, report)
+	}
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].Revenue > reports[j].Revenue })
+	return reports, nil
+}
+
+// ManagerSales reports every employee with direct reports visible from root
+// (everyone when root is nil), rolling up the sales of their whole subtree.
+func (r *ReportRepository) ManagerSales(ctx context.Context, f SalesFilter, plan models.CommissionPlan, root *int) ([]models.ManagerSalesReport, error) {
+	o, err := r.loadOrg(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []models.ManagerSalesReport{}
+	for _, id := range o.scope(root) {
+		if len(o.children[id]) == 0 {
+			continue
+		}
+		m := o.employees[id]
+		team := o.subtree(id)[1:]
+		report := models.ManagerSalesReport{
+			EmployeeId:        m.id,
+			Name:              m.name,
+			Title:             m.title,
+			ReportsTo:         m.reportsTo,
+			TeamSize:          len(team),
+			AssignedCustomers: m.assigned,
+			Periods:           []models.PeriodSales{},
+		}
+
+		// own and team sales per period, kept apart for the commission
+		own := m.periods
+		teamPeriods := map[string]*repPeriod{}
+		total := repPeriod{customers: m.customers}
+		for _, tid := range team {
+			e := o.employees[tid]
+			report.AssignedCustomers += e.assigned
+			total.customers += e.customers
+			for period, p := range e.periods {
+				tp, ok := teamPeriods[period]
+				if !ok {
+					tp = &repPeriod{}
+					teamPeriods[period] = tp
+				}
+				tp.customers += p.customers
+				tp.invoices += p.invoices
+				tp.revenue += p.revenue
+			}
+		}
+
+		periods := map[string]bool{}
+		for period := range own {
+			periods[period] = true
+		}
+		for period := range teamPeriods {
+			periods[period] = true
+		}
+		var commission int64
+		for _, period := range sortedPeriods(periods) {
+			var p, mine, theirs repPeriod
+			if op, ok := own[period]; ok {
+				mine = *op
+			}
+			if tp, ok := teamPeriods[period]; ok {
+				theirs = *tp
+			}
+			p.customers = mine.customers + theirs.customers
+			p.invoices = mine.invoices + theirs.invoices
+			p.revenue = mine.revenue + theirs.revenue
+			c := repCommission(plan, mine.revenue) + int64(math.Round(float64(theirs.revenue)*plan.OverrideRate))
+			report.Periods = append(report.Periods, models.PeriodSales{Period: period, SalesFigures: figures(p, c)})
+			total.invoices += p.invoices
+			total.revenue += p.revenue
+			commission += c
+		}
+		report.SalesFigures = figures(total, commission)
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// repCommission applies the plan's rate to the cents of revenue above the
+// threshold.
+func repCommission(plan models.CommissionPlan, revenue int64) int64 {
+	above := revenue - toCents(plan.Threshold)
+	if above <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(above) * plan.Rate))
+}
+
+func figures(p repPeriod, commission int64) models.SalesFigures {
+	fig := models.SalesFigures{
+		Customers:  p.customers,
+		Invoices:   p.invoices,
+		Revenue:    fromCents(p.revenue),
+		Commission: fromCents(commission),
+	}
+	if p.invoices > 0 {
+		fig.AverageInvoice = fromCents(int64(math.Round(float64(p.revenue) / float64(p.invoices))))
+	}
+	return fig
+}
+
+func sortedPeriods[T any](periods map[string]T) []string {
+	labels := make([]string, 0, len(periods))
+	for period := range periods {
+		labels = append(labels, period)
+	}
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,159 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SearchKinds lists the catalog entities covered by the full-text index.
+var SearchKinds = []string{"artist", "album", "track"}
+
+// maxSearchTerms bounds how many words of a query reach the FTS5 parser.
+const maxSearchTerms = 8
+
+// Names weigh five times as much as composers when ranking; kind and ref_id
+// are unindexed and carry no weight.
+const searchRank = "bm25(CatalogSearch, 0, 0, 10.0, 2.0)"
+
+var searchQueries = map[string]string{
+	"artist": `
+		SELECT CatalogSearch.ref_id, CatalogSearch.name,
+		       COALESCE(highlight(CatalogSearch, 2, '<mark>', '</mark>'), ''),
+		       COALESCE(snippet(CatalogSearch, -1, '<mark>', '</mark>', '…', 12), ''),
+		       '', '', '', -` + searchRank + `
+		FROM CatalogSearch
+		WHERE CatalogSearch MATCH ? AND CatalogSearch.kind = 'artist'
+		ORDER BY ` + searchRank + ` LIMIT ?`,
+	"album": `
+		SELECT CatalogSearch.ref_id, CatalogSearch.name,
+		       COALESCE(highlight(CatalogSearch, 2, '<mark>', '</mark>'), ''),
+		       COALESCE(snippet(CatalogSearch, -1, '<mark>', '</mark>', '…', 12), ''),
+		       COALESCE(ar.Name, ''), '', '', -` + searchRank + `
+		FROM CatalogSearch
+		JOIN Album al ON al.AlbumId = CatalogSearch.ref_id
+		LEFT JOIN Artist ar ON ar.ArtistId = al.ArtistId
+		WHERE CatalogSearch MATCH ? AND CatalogSearch.kind = 'album'
+		ORDER BY ` + searchRank + ` LIMIT ?`,
+	"track": `
+		SELECT CatalogSearch.ref_id, CatalogSearch.name,
+		       COALESCE(highlight(CatalogSearch, 2, '<mark>', '</mark>'), ''),
+		       COALESCE(snippet(CatalogSearch, -1, '<mark>', '</mark>', '…', 12), ''),
+		       COALESCE(ar.Name, ''), COALESCE(al.Title, ''), COALESCE(t.Composer, ''), -` + searchRank + `
+		FROM CatalogSearch
+		JOIN Track t ON t.TrackId = CatalogSearch.ref_id
+		LEFT JOIN Album al ON al.AlbumId = t.AlbumId
+		LEFT JOIN Artist ar ON ar.ArtistId = al.ArtistId
+		WHERE CatalogSearch MATCH ? AND CatalogSearch.kind = 'track'
+		ORDER BY ` + searchRank + ` LIMIT ?`,
+}
+
+// SearchRepository queries the CatalogSearch FTS5 index, which is only
+// available on SQLite.
+type SearchRepository struct {
+	DB *database.DB
+}
+
+// Search runs q against the full-text index and returns, for each requested
+// kind, the total number of matches and the best perKind hits. Every word of
+// q is matched as a prefix, so "led zep" finds "Led Zeppelin".
+func (r *SearchRepository) Search(ctx context.Context, q string, kinds []string, perKind int) (map[string]models.SearchGroup, error) {
+	if r.DB.Dialect != database.SQLite {
+		return nil, database.ErrUnsupported
+	}
+
+	groups := make(map[string]models.SearchGroup, len(kinds))
+	match := ftsQuery(q)
+	if match == "" {
+		for _, kind := range kinds {
+			groups[kind] = models.SearchGroup{Hits: []models.SearchHit{}}
+		}
+		return groups, nil
+	}
+
+	totals, err := r.countByKind(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range kinds {
+		query, ok := searchQueries[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown search kind %q", kind)
+		}
+		hits := []models.SearchHit{}
+		if totals[kind] > 0 {
+			hits, err = r.hits(ctx, query, match, perKind)
+			if err != nil {
+				log.Error().Err(err).Str("kind", kind).Str("q", q).Msg("Error searching catalog")
+				return nil, fmt.Errorf("error searching %ss: %w", kind, err)
+			}
+		}
+		groups[kind] = models.SearchGroup{Total: totals[kind], Hits: hits}
+	}
+	return groups, nil
+}
+
+func (r *SearchRepository) countByKind(ctx context.Context, match string) (map[string]int, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT kind, COUNT(*) FROM CatalogSearch WHERE CatalogSearch MATCH ? GROUP BY kind", match)
+	if err != nil {
+		log.Error().Err(err).Str("match", match).Msg("Error counting search hits")
+		return nil, fmt.Errorf("error counting search hits: %w", err)
+	}
+	defer rows.Close()
+
+	totals := map[string]int{}
+	for rows.Next() {
+		var kind string
+		var n int
+		if err := rows.Scan(&kind, &n); err != nil {
+			return nil, fmt.Errorf("error scanning search count: %w", err)
+		}
+		totals[kind] = n
+	}
+	return totals, rows.Err()
+}
+
+func (r *SearchRepository) hits(ctx context.Context, query, match string, limit int) ([]models.SearchHit, error) {
+	rows, err := r.DB.QueryContext(ctx, query, match, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.SearchHit{}
+	for rows.Next() {
+		var hit models.SearchHit
+		var name sql.NullString
+		if err := rows.Scan(&hit.ID, &name, &hit.Highlight, &hit.Snippet,
+			&hit.Artist, &hit.Album, &hit.Composer, &hit.Score); err != nil {
+			return nil, err
+		}
+		hit.Name = name.String
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// ftsQuery turns free text into an FTS5 query that ANDs every word as a
+// quoted prefix term. Punctuation is dropped, so user input can never be
+// parsed as FTS5 syntax.
+func ftsQuery(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"*`
+	}
+	return strings.Join(terms, " ")
+}
@@ -1,7 +1,10 @@
 package repositories
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
+	"chinook-api/internal/search"
 	"context"
 	"database/sql"
 	"fmt"
@@ -10,47 +13,66 @@ import (
 )
 
 type TrackRepository struct {
-	DB *sql.DB
+	DB       *database.DB
+	Watchers search.Watchers
 }
 
-func (r *TrackRepository) GetTracksPaginated(ctx context.Context, limit, offset int) ([]models.Track, error) {
-    rows, err := r.DB.QueryContext(ctx, `
-        SELECT
-            TrackId, Name, AlbumId, MediaTypeId, GenreId, Composer,
-            Milliseconds, Bytes, UnitPrice
-        FROM Track
-        LIMIT ? OFFSET ?
-    `, limit, offset)
-    if err != nil {
-        log.Error().Err(err).Msg("failed to query tracks with pagination")
-        return nil, fmt.Errorf("error fetching tracks: %w", err)
-    }
-    defer rows.Close()
+// GetTracks returns the tracks matching q.
+func (r *TrackRepository) GetTracks(ctx context.Context, q ListQuery) ([]models.Track, error) {
+	var tracks []models.Track
+	err := r.EachTrack(ctx, q, func(track models.Track) error {
+		tracks = append(tracks, track)
+		return nil
+	})
+	return tracks, err
+}
 
-    var tracks []models.Track
-    for rows.Next() {
-        var track models.Track
-        if err := rows.Scan(
-            &track.TrackId,
-            &track.Name,
-            &track.AlbumId,
-            &track.MediaTypeId,
-            &track.GenreId,
-            &track.Composer,
-            &track.Milliseconds,
-            &track.Bytes,
-            &track.UnitPrice,
-        ); err != nil {
-            log.Error().Err(err).Msg("failed to scan track")
-            return nil, fmt.Errorf("error scanning track: %w", err)
-        }
-        tracks = append(tracks, track)
-    }
-    if err := rows.Err(); err != nil {
-        log.Error().Err(err).Msg("error iterating over tracks")
-        return nil, fmt.Errorf("error iterating over tracks: %w", err)
-    }
-    return tracks, nil
+// CountTracks returns the number of tracks matching q.
+func (r *TrackRepository) CountTracks(ctx context.Context, q ListQuery) (int, error) {
+	return q.count(ctx, r.DB, "Track")
+}
+
+// EachTrack calls fn with each track matching q as it is read, so that
+// callers can stream the result. It stops at the first error fn returns.
+func (r *TrackRepository) EachTrack(ctx context.Context, q ListQuery, fn func(models.Track) error) error {
+	clause, args := q.clause()
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT
+			TrackId, Name, AlbumId, MediaTypeId, GenreId, Composer,
+			Milliseconds, Bytes, UnitPrice
+		FROM Track
+	`+clause, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query tracks")
+		return fmt.Errorf("error fetching tracks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var track models.Track
+		if err := rows.Scan(
+			&track.TrackId,
+			&track.Name,
+			&track.AlbumId,
+			&track.MediaTypeId,
+			&track.GenreId,
+			&track.Composer,
+			&track.Milliseconds,
+			&track.Bytes,
+			&track.UnitPrice,
+		); err != nil {
+			log.Error().Err(err).Msg("failed to scan track")
+			return fmt.Errorf("error scanning track: %w", err)
+		}
+		if err := fn(track); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over tracks")
+		return fmt.Errorf("error iterating over tracks: %w", err)
+	}
+	return nil
 }
 
 func (r *TrackRepository) GetTrackByID(ctx context.Context, id int) (models.Track, error) {
@@ -75,7 +97,7 @@ func (r *TrackRepository) GetTrackByID(ctx context.Context, id int) (models.Trac
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Warn().Int("id", id).Msg("Track not found")
-			return track, fmt.Errorf("track not found")
+			return track, apperr.NotFound("track_not_found", "track not found")
 		}
 		log.Error().Err(err).Int("id", id).Msg("Database error fetching track")
 		return track, fmt.Errorf("database error: %w", err)
@@ -83,3 +105,89 @@ func (r *TrackRepository) GetTrackByID(ctx context.Context, id int) (models.Trac
 	log.Debug().Int("id", track.TrackId).Msg("Fetched track by ID")
 	return track, nil
 }
+
+func (r *TrackRepository) UpdateTrack(ctx context.Context, track models.Track) error {
+	result, err := r.DB.ExecContext(ctx, `
+		UPDATE Track SET
+			Name = ?, AlbumId = ?, MediaTypeId = ?, GenreId = ?, Composer = ?,
+			Milliseconds = ?, Bytes = ?, UnitPrice = ?
+		WHERE TrackId = ?
+	`,
+		track.Name, track.AlbumId, track.MediaTypeId, track.GenreId, track.Composer,
+		track.Milliseconds, track.Bytes, track.UnitPrice, track.TrackId,
+	)
+	if err != nil {
+		log.Error().Err(err).Int("id", track.TrackId).Msg("failed to update track")
+		return fmt.Errorf("error updating track: %w", err)
+	}
+	log.Info().Int("id", track.TrackId).Msg("Track updated")
+	if n, err := result.RowsAffected(); err == nil && n > 0 {
+		r.Watchers.Upsert(search.Entry{Kind: search.Track, ID: track.TrackId, Name: track.Name})
+	}
+	return nil
+}
+
+// GetTracksByAlbumID returns a page of the album's tracks and their total count.
+func (r *TrackRepository) GetTracksByAlbumID(ctx context.Context, albumID, limit, offset int) ([]models.Track, int, error) {
+	return r.getTracksBy(ctx, "AlbumId", albumID, limit, offset)
+}
+
+// GetTracksByGenreID returns a page of the genre's tracks and their total count.
+func (r *TrackRepository) GetTracksByGenreID(ctx context.Context, genreID, limit, offset int) ([]models.Track, int, error) {
+	return r.getTracksBy(ctx, "GenreId", genreID, limit, offset)
+}
+
+// GetTracksByMediaTypeID returns a page of the media type's tracks and their total count.
+func (r *TrackRepository) GetTracksByMediaTypeID(ctx context.Context, mediaTypeID, limit, offset int) ([]models.Track, int, error) {
+	return r.getTracksBy(ctx, "MediaTypeId", mediaTypeID, limit, offset)
+}
+
+// getTracksBy pages through the tracks whose foreign key column equals id.
+// column is always one of the constants above, never user input.
+func (r *TrackRepository) getTracksBy(ctx context.Context, column string, id, limit, offset int) ([]models.Track, int, error) {
+	var total int
+	if err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM Track WHERE "+column+" = ?", id).Scan(&total); err != nil {
+		log.Error().Err(err).Str("column", column).Int("id", id).Msg("failed to count tracks")
+		return nil, 0, fmt.Errorf("error counting tracks: %w", err)
+	}
+
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT
+			TrackId, Name, AlbumId, MediaTypeId, GenreId, Composer,
+			Milliseconds, Bytes, UnitPrice
+		FROM Track
+		WHERE `+column+` = ?
+		ORDER BY TrackId
+		LIMIT ? OFFSET ?
+	`, id, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Str("column", column).Int("id", id).Msg("failed to query tracks")
+		return nil, 0, fmt.Errorf("error fetching tracks: %w", err)
+	}
+	defer rows.Close()
+
+	tracks := []models.Track{}
+	for rows.Next() {
+		var track models.Track
+		if err := rows.Scan(
+			&track.TrackId,
+			&track.Name,
+			&track.AlbumId,
+			&track.MediaTypeId,
+			&track.GenreId,
+			&track.Composer,
+			&track.Milliseconds,
+			&track.Bytes,
+			&track.UnitPrice,
+		); err != nil {
+			log.Error().Err(err).Msg("failed to scan track")
+			return nil, 0, fmt.Errorf("error scanning track: %w", err)
+		}
+		tracks = append(tracks, track)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("error iterating over tracks")
+		return nil, 0, fmt.Errorf("error iterating over tracks: %w", err)
+	}
+	return tracks, total, nil
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
+	"chinook-api/internal/database/dbtest"
+)
+
+func TestTrackList(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		repo := &TrackRepository{DB: db}
+
+		q, err := ParseListQuery("track", url.Values{"album_id": {"1"}, "sort": {"-name"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracks, err := repo.GetTracks(ctx, q)
+		if err != nil {
+			t.Fatalf("GetTracks: %v", err)
+		}
+		if len(tracks) != 2 || tracks[0].TrackId != 2 || tracks[1].TrackId != 1 {
+			t.Errorf("album 1 by name descending = %+v, want tracks 2, 1", tracks)
+		}
+		if total, err := repo.CountTracks(ctx, q); err != nil || total != 2 {
+			t.Errorf("CountTracks = %d, %v; want 2", total, err)
+		}
+
+		q, _ = ParseListQuery("track", url.Values{"sort": {"-unit_price,track_id"}})
+		q.Limit, q.Offset = 2, 1
+		tracks, err = repo.GetTracks(ctx, q)
+		if err != nil || len(tracks) != 2 || tracks[0].TrackId != 1 || tracks[1].TrackId != 2 {
+			t.Errorf("second page by price = %+v, %v; want tracks 1, 2", tracks, err)
+		}
+		if n, _ := repo.CountTracks(ctx, q); n != 2 {
+			t.Errorf("CountTracks within the page = %d, want 2", n)
+		}
+		q.Limit, q.Offset = 0, 0
+		if n, _ := repo.CountTracks(ctx, q); n != 4 {
+			t.Errorf("CountTracks without a page = %d, want 4", n)
+		}
+
+		if _, err := ParseListQuery("track", url.Values{"sort": {"password"}}); err == nil {
+			t.Error("sorting by a field off the allow-list succeeded")
+		}
+	})
+}
+
+func TestTrackUpdate(t *testing.T) {
+	dbtest.Each(t, func(t *testing.T, db *database.DB) {
+		ctx := context.Background()
+		seed(t, db)
+		repo := &TrackRepository{DB: db}
+
+		track, err := repo.GetTrackByID(ctx, 3)
+		if err != nil {
+			t.Fatalf("GetTrackByID: %v", err)
+		}
+		if track.GenreId != nil || track.Composer != nil || track.Bytes != nil {
+			t.Errorf("track 3 = %+v, want nil genre, composer and bytes", track)
+		}
+
+		genre, composer := 2, "Bon Scott"
+		track.GenreId, track.Composer = &genre, &composer
+		if err := repo.UpdateTrack(ctx, track); err != nil {
+			t.Fatalf("UpdateTrack: %v", err)
+		}
+		track, err = repo.GetTrackByID(ctx, 3)
+		if err != nil || track.GenreId == nil || *track.GenreId != 2 || track.Composer == nil || *track.Composer != composer {
+			t.Errorf("updated track = %+v, %v", track, err)
+		}
+
+		tracks, total, err := repo.GetTracksByAlbumID(ctx, 1, 1, 0)
+		if err != nil || total != 2 || len(tracks) != 1 {
+			t.Errorf("GetTracksByAlbumID(1, limit 1) = %d tracks of %d, %v; want 1 of 2", len(tracks), total, err)
+		}
+
+		if _, err := repo.GetTrackByID(ctx, 999); !apperr.IsNotFound(err) {
+			t.Errorf("GetTrackByID(999): err = %v, want not found", err)
+		}
+	})
+}
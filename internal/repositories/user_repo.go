@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"chinook-api/internal/apperr"
+	"chinook-api/internal/database"
 	"chinook-api/internal/models"
 	"context"
 	"database/sql"
@@ -10,32 +12,106 @@ import (
 )
 
 type UserRepository struct {
-    DB *sql.DB
+    DB *database.DB
 }
 
 func (r *UserRepository) CreateUser(ctx context.Context, user models.User) (int64, error) {
-    result, err := r.DB.ExecContext(
+    // User is a reserved word on Postgres, so the table name is always quoted.
+    id, err := r.DB.InsertReturningID(
         ctx,
-        "INSERT INTO User (Username, Email, Password) VALUES (?, ?, ?)",
+        "UserId",
+        `INSERT INTO "User" (Username, Email, Password) VALUES (?, ?, ?)`,
         user.Username, user.Email, user.Password,
     )
     if err != nil {
+        if database.IsUniqueViolation(err) {
+            return 0, apperr.Conflict("user_exists", "username or email is already taken")
+        }
         log.Error().Err(err).Msg("Error creating user")
         return 0, fmt.Errorf("error creating user: %w", err)
     }
-    return result.LastInsertId()
+    return id, nil
 }
 
 func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
     var user models.User
     err := r.DB.QueryRowContext(
         ctx,
-        "SELECT UserId, Username, Email, Password FROM User WHERE Username = ?",
+        `SELECT UserId, Username, Email, Password, COALESCE(Role, ''), EmployeeId FROM "User" WHERE Username = ?`,
         username,
-    ).Scan(&user.ID, &user.Username, &user.Email, &user.Password)
+    ).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.EmployeeID)
     if err != nil {
-        log.Error().Err(err).Msg("User not found")
-        return user, fmt.Errorf("user not found")
+        if err == sql.ErrNoRows {
+            log.Warn().Str("username", username).Msg("User not found")
+            return user, apperr.NotFound("user_not_found", "user not found")
+        }
+        log.Error().Err(err).Str("username", username).Msg("Database error fetching user")
+        return user, fmt.Errorf("database error: %w", err)
     }
     return user, nil
-}
\ No newline at end of file
+}
+func (r *UserRepository) ListUsers(ctx context.Context) ([]models.User, error) {
+    rows, err := r.DB.QueryContext(ctx, `SELECT UserId, Username, Email, COALESCE(Role, ''), EmployeeId FROM "User" ORDER BY UserId`)
+    if err != nil {
+        log.Error().Err(err).Msg("Error listing users")
+        return nil, fmt.Errorf("error listing users: %w", err)
+    }
+    defer rows.Close()
+
+    var users []models.User
+    for rows.Next() {
+        var user models.User
+        if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.EmployeeID); err != nil {
+            log.Error().Err(err).Msg("Error scanning user")
+            return nil, fmt.Errorf("error scanning user: %w", err)
+        }
+        users = append(users, user)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("error iterating over users: %w", err)
+    }
+    return users, nil
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, username, role string) error {
+    return r.updateColumn(ctx, username, "Role", role)
+}
+
+// UpdatePassword stores an already hashed password.
+func (r *UserRepository) UpdatePassword(ctx context.Context, username, hashedPassword string) error {
+    return r.updateColumn(ctx, username, "Password", hashedPassword)
+}
+
+// SetEmployee links the user to an employee, or unlinks it when employeeID
+// is nil.
+func (r *UserRepository) SetEmployee(ctx context.Context, username string, employeeID *int) error {
+    result, err := r.DB.ExecContext(ctx, `UPDATE "User" SET EmployeeId = ? WHERE Username = ?`, employeeID, username)
+    if err != nil {
+        log.Error().Err(err).Str("username", username).Msg("Error linking user to employee")
+        return fmt.Errorf("error linking user to employee: %w", err)
+    }
+    n, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error getting rows affected: %w", err)
+    }
+    if n == 0 {
+        return apperr.NotFound("user_not_found", "user not found")
+    }
+    return nil
+}
+
+func (r *UserRepository) updateColumn(ctx context.Context, username, column, value string) error {
+    result, err := r.DB.ExecContext(ctx, `UPDATE "User" SET `+column+` = ? WHERE Username = ?`, value, username)
+    if err != nil {
+        log.Error().Err(err).Str("username", username).Msg("Error updating user")
+        return fmt.Errorf("error updating user: %w", err)
+    }
+    n, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error getting rows affected: %w", err)
+    }
+    if n == 0 {
+        return apperr.NotFound("user_not_found", "user not found")
+    }
+    return nil
+}
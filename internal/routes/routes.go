@@ -1,74 +1,176 @@
 package routes
 
 import (
+	"chinook-api/internal/backup"
+	"chinook-api/internal/catalog"
+	"chinook-api/internal/config"
+	"chinook-api/internal/database"
+	"chinook-api/internal/graph"
 	"chinook-api/internal/handlers"
+	"chinook-api/internal/jobs"
+	"chinook-api/internal/models"
 	"chinook-api/internal/repositories"
+	"chinook-api/internal/search"
 	"chinook-api/internal/utils"
-	"database/sql"
-	"os"
+	"context"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRoutes(r *gin.Engine, db *sql.DB) {
+func SetupRoutes(r *gin.Engine, db *database.DB, cfg *config.AppConfig, backups *backup.Manager, jobQueue *jobs.Queue) {
 	// auth
 	userRepo := &repositories.UserRepository{DB: db}
 	refreshTokenRepo := &repositories.RefreshTokenRepository{DB: db}
 	authHandler := &handlers.AuthHandler{
 		UserRepo:         userRepo,
 		RefreshTokenRepo: refreshTokenRepo,
+		RefreshTokenTTL:  cfg.Auth.RefreshTokenTTL,
 	}
-	// artists
-	artistRepo := &repositories.ArtistRepository{DB: db}
-	artistHandler := &handlers.ArtistHandler{Repo: artistRepo}
+	// fuzzy suggestions and autocomplete, kept current by the catalog repositories
+	fuzzyIndex := search.NewFuzzyIndex()
+	if err := fuzzyIndex.Reload(context.Background(), db); err != nil {
+		log.Error().Err(err).Msg("Failed to build fuzzy search index")
+	} else {
+		log.Info().Int("names", fuzzyIndex.Len()).Msg("Fuzzy search index built")
+	}
+	autocompleteIndex := search.NewAutocompleteIndex()
+	if err := autocompleteIndex.Reload(context.Background(), db); err != nil {
+		log.Error().Err(err).Msg("Failed to build autocomplete index")
+	}
+	catalogWatchers := search.Watchers{fuzzyIndex, autocompleteIndex}
 
-	// albums
-	albumRepo := &repositories.AlbumRepository{DB: db}
-	albumHandler := &handlers.AlbumHandler{Repo: albumRepo}
+	// Child repositories are built first because parent handlers serve the
+	// nested routes, e.g. /artists/:id/albums needs the album repository.
 
-	// employees
+	// ?include= expansion, shared by every handler that returns tracks,
+	// albums, invoices or invoice lines
+	includer := &repositories.Includer{DB: db}
+
+	// invoices
+	invoiceRepo := &repositories.InvoiceRepository{DB: db}
+	invoiceHandler := &handlers.InvoiceHandler{Repo: invoiceRepo, Includes: includer}
+
+	// customers
+	customerRepo := &repositories.CustomerRepository{DB: db}
 	employeeRepo := &repositories.EmployeeRepository{DB: db}
-	employeeHandler := &handlers.EmployeeHandler{Repo: employeeRepo}
+	customerHandler := &handlers.CustomerHandler{Repo: customerRepo, Invoices: invoiceRepo, Employees: employeeRepo, Includes: includer}
+
+	// employees
+	employeeHandler := &handlers.EmployeeHandler{Repo: employeeRepo, Customers: customerRepo}
+
+	// playlists
+	playlistRepo := &repositories.PlaylistRepository{DB: db}
+	playlistHandler := &handlers.PlaylistHandler{Repo: playlistRepo}
+
+	// playlist tracks
+	playlistTrackRepo := &repositories.PlaylistTrackRepository{DB: db}
+	playlistTrackHandler := &handlers.PlaylistTrackHandler{Repo: playlistTrackRepo, Includes: includer}
 
 	// tracks
-	trackRepo := &repositories.TrackRepository{DB: db}
-	trackHandler := &handlers.TrackHandler{Repo: trackRepo}
+	trackRepo := &repositories.TrackRepository{DB: db, Watchers: catalogWatchers}
 
 	// genres
 	genreRepo := &repositories.GenreRepository{DB: db}
-	genreHandler := &handlers.GenreHandler{Repo: genreRepo}
+	genreHandler := &handlers.GenreHandler{Repo: genreRepo, Tracks: trackRepo, Includes: includer}
 
 	// media types
 	mediaTypesRepo := &repositories.MediaTypeRepository{DB: db}
-	mediaTypeHandler := &handlers.MediaTypeHandler{Repo: mediaTypesRepo}
+	mediaTypeHandler := &handlers.MediaTypeHandler{Repo: mediaTypesRepo, Tracks: trackRepo, Includes: includer}
 
-	// playlists
-	playlistRepo := &repositories.PlaylistRepository{DB: db}
-	playlistHandler := &handlers.PlaylistHandler{Repo: playlistRepo}
+	// albums
+	albumRepo := &repositories.AlbumRepository{DB: db, Watchers: catalogWatchers}
+	artistRepo := &repositories.ArtistRepository{DB: db, Watchers: catalogWatchers}
+	albumHandler := &handlers.AlbumHandler{Repo: albumRepo, Artists: artistRepo, Tracks: trackRepo, Includes: includer}
+	trackHandler := &handlers.TrackHandler{Repo: trackRepo, Playlists: playlistRepo, Albums: albumRepo, Genres: genreRepo, MediaTypes: mediaTypesRepo, Includes: includer}
 
-	// playlist tracks
-	playlistTrackRepo := &repositories.PlaylistTrackRepository{DB: db}
-	playlistTrackHandler := &handlers.PlaylistTrackHandler{Repo: playlistTrackRepo}
+	// artists
+	artistHandler := &handlers.ArtistHandler{Repo: artistRepo, Fuzzy: fuzzyIndex, Albums: albumRepo, Includes: includer}
 
-	// customers
-	customerRepo := &repositories.CustomerRepository{DB: db}
-	customerHandler := &handlers.CustomerHandler{Repo: customerRepo}
+	handlers.PageLimits.Default = cfg.Limits.DefaultPageSize
+	handlers.PageLimits.Max = cfg.Limits.MaxPageSize
+	handlers.BulkLimit = cfg.Limits.MaxBulkOps
 
-	// invoices
-	invoiceRepo := &repositories.InvoiceRepository{DB: db}
-	invoiceHandler := &handlers.InvoiceHandler{Repo: invoiceRepo}
+	// GraphQL, over the same repositories, with per-request batching
+	graphqlHandler := &handlers.GraphQLHandler{
+		Artists:    artistRepo,
+		Albums:     albumRepo,
+		Tracks:     trackRepo,
+		Genres:     genreRepo,
+		MediaTypes: mediaTypesRepo,
+		Playlists:  playlistRepo,
+		Customers:  customerRepo,
+		Invoices:   invoiceRepo,
+		Employees:  employeeRepo,
+		Batch:      &repositories.Batcher{DB: db},
+		Limits: graph.Limits{
+			MaxDepth:      cfg.GraphQL.MaxDepth,
+			MaxComplexity: cfg.GraphQL.MaxComplexity,
+		},
+	}
+	// after PageLimits, which set the default page size in the schema
+	if err := graphqlHandler.BuildSchema(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to build GraphQL schema")
+	}
+
+	// search
+	searchRepo := &repositories.SearchRepository{DB: db}
+	searchHandler := &handlers.SearchHandler{Repo: searchRepo, Fuzzy: fuzzyIndex, Autocomplete: autocompleteIndex}
+
+	// sales analytics
+	analyticsRepo := &repositories.AnalyticsRepository{DB: db}
+	analyticsHandler := &handlers.AnalyticsHandler{Repo: analyticsRepo}
+
+	// sales rep and manager reports
+	reportRepo := &repositories.ReportRepository{DB: db}
+	reportHandler := &handlers.ReportHandler{
+		Repo:      reportRepo,
+		Users:     userRepo,
+		Employees: employeeRepo,
+		Plan: models.CommissionPlan{
+			Rate:         cfg.Commission.Rate,
+			OverrideRate: cfg.Commission.OverrideRate,
+			Threshold:    cfg.Commission.Threshold,
+		},
+	}
 
-	r.NoRoute(notFoundHandler)
-	r.Use(internalServerErrorMiddleware())
+	// backups
+	backupHandler := &handlers.BackupHandler{Manager: backups}
+
+	// catalog imports
+	importer := &catalog.Importer{DB: db, Watchers: catalogWatchers}
+	importHandler := &handlers.ImportHandler{Importer: importer}
+
+	// background jobs
+	jobHandler := &handlers.JobHandler{
+		Queue:        jobQueue,
+		Repo:         jobQueue.Repo,
+		Users:        userRepo,
+		Invoices:     invoiceRepo,
+		Customers:    customerRepo,
+		Tracks:       trackRepo,
+		Importer:     importer,
+		Reports:      reportHandler,
+		AdminImports: cfg.IsProduction(),
+	}
+	jobHandler.RegisterRunners()
+
+	// stored responses for POST retries with an Idempotency-Key
+	idempotencyRepo := &repositories.IdempotencyRepository{DB: db}
+
+	r.NoRoute(handlers.NotFound)
+	r.Use(handlers.ErrorMiddleware())
+	r.Use(maxBodyMiddleware(cfg.Limits.MaxBodyBytes))
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
-	version := os.Getenv("API_VERSION")
-	api := r.Group("/api/" + version)
+	api := r.Group("/api/" + cfg.Server.APIVersion)
+	api.Use(handlers.ETags())
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -82,22 +184,36 @@ func SetupRoutes(r *gin.Engine, db *sql.DB) {
 
 	// Protected routes
 	var protected *gin.RouterGroup
-	if os.Getenv("GO_ENV") == "production" {
+	if cfg.IsProduction() {
 		protected = api.Group("", utils.AuthMiddlewareJWT())
 	} else {
 		protected = api.Group("")
 	}
+	// after authentication, since Idempotency-Keys are scoped to the user.
+	// The /auth group above is left out on purpose: it has no user to scope
+	// keys to, and login and refresh responses carry tokens that must not
+	// be stored and replayed.
+	protected.Use(handlers.Idempotency(idempotencyRepo, cfg.Idempotency.TTL))
 	{
 		protected.GET("/auth/me", authHandler.Me)
 		protected.POST("/auth/logout", authHandler.Logout)
+		protected.GET("/search", searchHandler.Search)
+		protected.GET("/autocomplete", searchHandler.Complete)
+		protected.POST("/graphql", graphqlHandler.Query)
+		if !cfg.IsProduction() {
+			protected.GET("/graphql", graphqlHandler.GraphiQL)
+		}
 		artists := protected.Group("/artists")
 		{
 			artists.GET("", artistHandler.GetAll)
 			artists.GET("/:id", artistHandler.GetOne)
 			artists.POST("", artistHandler.Create)
+			artists.POST("/bulk", artistHandler.Bulk)
 			artists.PUT("/:id", artistHandler.Update)
+			artists.PATCH("/:id", artistHandler.Patch)
 			artists.DELETE("/:id", artistHandler.Delete)
 			artists.GET("/search", artistHandler.SearchByName)
+			artists.GET("/:id/albums", artistHandler.GetAlbums)
 		}
 
 		albums := protected.Group("/albums")
@@ -105,38 +221,56 @@ func SetupRoutes(r *gin.Engine, db *sql.DB) {
 			albums.GET("", albumHandler.GetAll)
 			albums.GET("/:id", albumHandler.GetOne)
 			albums.POST("", albumHandler.Create)
+			albums.POST("/bulk", albumHandler.Bulk)
 			albums.PUT("/:id", albumHandler.Update)
+			albums.PATCH("/:id", albumHandler.Patch)
 			albums.DELETE("/:id", albumHandler.Delete)
+			albums.GET("/:id/tracks", albumHandler.GetTracks)
 		}
 
 		employees := protected.Group("/employees")
 		{
 			employees.GET("", employeeHandler.GetAll)
+			employees.GET("/tree", employeeHandler.GetTree)
 			employees.GET("/:id", employeeHandler.GetOne)
+			employees.GET("/:id/customers", employeeHandler.GetCustomers)
+			employees.GET("/:id/reports", employeeHandler.GetReports)
+			employees.GET("/:id/chain", employeeHandler.GetChain)
+			employees.POST("", employeeHandler.Create)
+			employees.PUT("/:id", employeeHandler.Update)
+			employees.PATCH("/:id", employeeHandler.Patch)
+			employees.DELETE("/:id", employeeHandler.Delete)
+			employees.POST("/:id/reassign-customers", employeeHandler.ReassignCustomers)
 		}
 
 		tracks := protected.Group("/tracks")
 		{
 			tracks.GET("", trackHandler.GetAll)
 			tracks.GET("/:id", trackHandler.GetOne)
+			tracks.POST("/bulk", trackHandler.Bulk)
+			tracks.PATCH("/:id", trackHandler.Patch)
+			tracks.GET("/:id/playlists", trackHandler.GetPlaylists)
 		}
 
 		genres := protected.Group("/genres")
 		{
 			genres.GET("", genreHandler.GetAll)
 			genres.GET("/:id", genreHandler.GetOne)
+			genres.GET("/:id/tracks", genreHandler.GetTracks)
 		}
 
 		mediaTypes := protected.Group("/media_types")
 		{
 			mediaTypes.GET("", mediaTypeHandler.GetAll)
 			mediaTypes.GET("/:id", mediaTypeHandler.GetOne)
+			mediaTypes.GET("/:id/tracks", mediaTypeHandler.GetTracks)
 		}
 
 		playlists := protected.Group("/playlists")
 		{
 			playlists.GET("", playlistHandler.GetAll)
 			playlists.GET("/:id", playlistHandler.GetOne)
+			playlists.PATCH("/:id", playlistHandler.Patch)
 			playlists.GET("/:id/tracks", playlistTrackHandler.GetPlaylistTrack)
 		}
 
@@ -144,6 +278,11 @@ func SetupRoutes(r *gin.Engine, db *sql.DB) {
 		{
 			customers.GET("", customerHandler.GetAll)
 			customers.GET("/:id", customerHandler.GetOne)
+			customers.GET("/:id/invoices", customerHandler.GetInvoices)
+			customers.POST("", customerHandler.Create)
+			customers.PUT("/:id", customerHandler.Update)
+			customers.PATCH("/:id", customerHandler.Patch)
+			customers.DELETE("/:id", customerHandler.Delete)
 		}
 
 		invoices := protected.Group("/invoices")
@@ -153,22 +292,43 @@ func SetupRoutes(r *gin.Engine, db *sql.DB) {
 			invoices.GET("/:id/lines", invoiceHandler.GetInvoiceLines)
 		}
 
-	}
-}
+		analytics := protected.Group("/analytics")
+		{
+			analytics.GET("/sales", analyticsHandler.Sales)
+			analytics.GET("/breakdown", analyticsHandler.Breakdown)
+		}
+
+		reports := protected.Group("/reports")
+		{
+			reports.GET("/sales-reps", reportHandler.SalesReps)
+			reports.GET("/managers", reportHandler.Managers)
+		}
 
-// Custom 404 handler
-func notFoundHandler(c *gin.Context) {
-	c.JSON(404, gin.H{"error": "resource not found"})
+		jobRoutes := protected.Group("/jobs")
+		{
+			jobRoutes.POST("", jobHandler.Create)
+			jobRoutes.GET("/:id", jobHandler.Get)
+			jobRoutes.GET("/:id/result", jobHandler.Result)
+			jobRoutes.POST("/:id/cancel", jobHandler.Cancel)
+		}
+
+		admin := protected.Group("/admin")
+		if cfg.IsProduction() {
+			admin.Use(authHandler.RequireRole("admin"))
+		}
+		{
+			admin.GET("/backups", backupHandler.List)
+			admin.POST("/backups", backupHandler.Create)
+			admin.POST("/imports", importHandler.Create)
+		}
+
+	}
 }
 
-// Custom 500 middleware
-func internalServerErrorMiddleware() gin.HandlerFunc {
+// Caps request bodies so oversized payloads fail while binding
+func maxBodyMiddleware(limit int64) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
 		c.Next()
-		if len(c.Errors) > 0 && !c.Writer.Written() {
-			c.JSON(500, gin.H{
-				"error": "internal server error " + c.Errors.Last().Error(),
-			})
-		}
 	}
 }
@@ -0,0 +1,272 @@
+package search
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+)
+
+var salesQueries = []struct {
+	kind  string
+	query string
+}{
+	{Track, "SELECT TrackId, SUM(Quantity) FROM InvoiceLine GROUP BY TrackId"},
+	{Album, `SELECT t.AlbumId, SUM(il.Quantity) FROM InvoiceLine il
+		JOIN Track t ON t.TrackId = il.TrackId
+		WHERE t.AlbumId IS NOT NULL GROUP BY t.AlbumId`},
+	{Artist, `SELECT al.ArtistId, SUM(il.Quantity) FROM InvoiceLine il
+		JOIN Track t ON t.TrackId = il.TrackId
+		JOIN Album al ON al.AlbumId = t.AlbumId
+		GROUP BY al.ArtistId`},
+}
+
+// prefixKey is the normalized text of a name from one word onwards, so that
+// "zep" completes "Led Zeppelin" as well as "led" does.
+type prefixKey struct {
+	text string
+	key  entryKey
+}
+
+// AutocompleteIndex completes partial names from sorted per-kind slices of
+// word-suffix keys, found by binary search and ranked by units sold. It is
+// safe for concurrent use and updated in place as the catalog changes.
+type AutocompleteIndex struct {
+	mu    sync.RWMutex
+	keys  map[string][]prefixKey
+	names map[entryKey]string
+	sales map[entryKey]int
+}
+
+func NewAutocompleteIndex() *AutocompleteIndex {
+	return &AutocompleteIndex{
+		keys:  map[string][]prefixKey{},
+		names: map[entryKey]string{},
+		sales: map[entryKey]int{},
+	}
+}
+
+// Reload rebuilds the index and the sales counts from the database.
+func (x *AutocompleteIndex) Reload(ctx context.Context, db *database.DB) error {
+	entries, err := LoadCatalog(ctx, db)
+	if err != nil {
+		return err
+	}
+	sales, err := loadSales(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	keys := map[string][]prefixKey{}
+	names := make(map[entryKey]string, len(entries))
+	for _, e := range entries {
+		k := entryKey{e.Kind, e.ID}
+		names[k] = e.Name
+		for _, text := range prefixTexts(e.Name) {
+			keys[e.Kind] = append(keys[e.Kind], prefixKey{text, k})
+		}
+	}
+	for _, ks := range keys {
+		sort.Slice(ks, func(i, j int) bool { return lessKey(ks[i], ks[j]) })
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.keys, x.names, x.sales = keys, names, sales
+	return nil
+}
+
+func loadSales(ctx context.Context, db *database.DB) (map[entryKey]int, error) {
+	sales := map[entryKey]int{}
+	for _, q := range salesQueries {
+		rows, err := db.QueryContext(ctx, q.query)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s sales: %w", q.kind, err)
+		}
+		for rows.Next() {
+			var id, n int
+			if err := rows.Scan(&id, &n); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scanning %s sales: %w", q.kind, err)
+			}
+			sales[entryKey{q.kind, id}] = n
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("loading %s sales: %w", q.kind, err)
+		}
+	}
+	return sales, nil
+}
+
+// Upsert adds or renames an entry. Its sales count is kept across renames.
+func (x *AutocompleteIndex) Upsert(e Entry) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	k := entryKey{e.Kind, e.ID}
+	x.removeKeys(k)
+	x.names[k] = e.Name
+	for _, text := range prefixTexts(e.Name) {
+		x.insertKey(prefixKey{text, k})
+	}
+}
+
+func (x *AutocompleteIndex) Remove(kind string, id int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	k := entryKey{kind, id}
+	x.removeKeys(k)
+	delete(x.names, k)
+	delete(x.sales, k)
+}
+
+func (x *AutocompleteIndex) insertKey(pk prefixKey) {
+	ks := x.keys[pk.key.kind]
+	i := sort.Search(len(ks), func(i int) bool { return !lessKey(ks[i], pk) })
+	x.keys[pk.key.kind] = slices.Insert(ks, i, pk)
+}
+
+func (x *AutocompleteIndex) removeKeys(k entryKey) {
+	name, ok := x.names[k]
+	if !ok {
+		return
+	}
+	for _, text := range prefixTexts(name) {
+		pk := prefixKey{text, k}
+		ks := x.keys[k.kind]
+		i := sort.Search(len(ks), func(i int) bool { return !lessKey(ks[i], pk) })
+		if i < len(ks) && ks[i] == pk {
+			x.keys[k.kind] = slices.Delete(ks, i, i+1)
+		}
+	}
+}
+
+// Complete returns, for each kind, up to limit names containing a word that
+// starts with q. Matches are ranked by units sold, doubled when the name
+// itself starts with q, then by shorter name. Every match is weighed, since
+// the best seller may sort last by name; only the best limit are kept while
+// scanning.
+func (x *AutocompleteIndex) Complete(q string, kinds []string, limit int) map[string][]models.AutocompleteItem {
+	prefix := normalize(q)
+	if r := []rune(prefix); len(r) > maxQueryRunes {
+		prefix = string(r[:maxQueryRunes])
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	results := make(map[string][]models.AutocompleteItem, len(kinds))
+	for _, kind := range kinds {
+		items := []models.AutocompleteItem{}
+		if prefix == "" || limit <= 0 {
+			results[kind] = items
+			continue
+		}
+
+		ks := x.keys[kind]
+		start := sort.Search(len(ks), func(i int) bool { return ks[i].text >= prefix })
+		seen := map[entryKey]bool{}
+		top := make(topCandidates, 0, limit)
+		for i := start; i < len(ks) && strings.HasPrefix(ks[i].text, prefix); i++ {
+			k := ks[i].key
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			name := x.names[k]
+			weight := x.sales[k] + 1
+			if strings.HasPrefix(normalize(name), prefix) {
+				weight *= 2
+			}
+			c := candidate{
+				item:   models.AutocompleteItem{ID: k.id, Name: name, Sales: x.sales[k]},
+				weight: weight,
+			}
+			switch {
+			case len(top) < limit:
+				heap.Push(&top, c)
+			case c.better(top[0]):
+				top[0] = c
+				heap.Fix(&top, 0)
+			}
+		}
+
+		sort.Slice(top, func(i, j int) bool { return top[i].better(top[j]) })
+		for _, c := range top {
+			items = append(items, c.item)
+		}
+		results[kind] = items
+	}
+	return results
+}
+
+type candidate struct {
+	item   models.AutocompleteItem
+	weight int
+}
+
+// better reports whether c ranks above d: by weight, then by shorter name,
+// then by id.
+func (c candidate) better(d candidate) bool {
+	if c.weight != d.weight {
+		return c.weight > d.weight
+	}
+	if len(c.item.Name) != len(d.item.Name) {
+		return len(c.item.Name) < len(d.item.Name)
+	}
+	return c.item.ID < d.item.ID
+}
+
+// topCandidates is a heap of the best candidates so far with the worst at
+// the root, which is the one a better candidate replaces.
+type topCandidates []candidate
+
+func (h topCandidates) Len() int           { return len(h) }
+func (h topCandidates) Less(i, j int) bool { return h[j].better(h[i]) }
+func (h topCandidates) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *topCandidates) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *topCandidates) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func lessKey(a, b prefixKey) bool {
+	if a.text != b.text {
+		return a.text < b.text
+	}
+	return a.key.id < b.key.id
+}
+
+// normalize lowercases s and reduces every run of punctuation and spaces to
+// a single space.
+func normalize(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// prefixTexts returns the normalized name starting at each of its words,
+// without duplicates.
+func prefixTexts(name string) []string {
+	words := strings.Fields(normalize(name))
+	texts := make([]string, 0, len(words))
+	seen := map[string]bool{}
+	for i := range words {
+		text := strings.Join(words[i:], " ")
+		if !seen[text] {
+			seen[text] = true
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
@@ -0,0 +1,37 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompleteRanksEveryMatch(t *testing.T) {
+	x := NewAutocompleteIndex()
+	// more matches than used to be ranked, all sorting before the best seller
+	for id := 1; id <= 3000; id++ {
+		x.Upsert(Entry{Kind: Track, ID: id, Name: fmt.Sprintf("Aa %04d", id)})
+	}
+	x.Upsert(Entry{Kind: Track, ID: 5000, Name: "Azure"})
+	x.Upsert(Entry{Kind: Track, ID: 5001, Name: "Blue Azalea"})
+	x.sales[entryKey{Track, 5000}] = 10
+	x.sales[entryKey{Track, 5001}] = 30
+	x.sales[entryKey{Track, 7}] = 1
+
+	got := x.Complete("a", []string{Track}, 3)[Track]
+	var ids []int
+	for _, item := range got {
+		ids = append(ids, item.ID)
+	}
+	// Blue Azalea only matches on its second word, so it is not doubled:
+	// 31 against Azure's 22
+	if want := []int{5001, 5000, 7}; fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("Complete(a) = %v, want %v", ids, want)
+	}
+
+	if got := x.Complete("aa 000", []string{Track}, 50)[Track]; len(got) != 9 || got[0].ID != 7 {
+		t.Errorf("Complete(aa 000) = %v, want the 9 tracks 0001 to 0009, 0007 first", got)
+	}
+	if got := x.Complete("a", []string{Track}, 0)[Track]; len(got) != 0 {
+		t.Errorf("Complete with limit 0 = %v, want none", got)
+	}
+}
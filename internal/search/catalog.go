@@ -0,0 +1,81 @@
+// Package search holds the in-memory indexes over catalog names that back
+// fuzzy suggestions. Indexes are loaded from the database at startup and
+// kept current by the repositories, which notify Watchers after every
+// successful write.
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"chinook-api/internal/database"
+)
+
+// Kinds of catalog entries, matching the types used by the search endpoints.
+const (
+	Artist = "artist"
+	Album  = "album"
+	Track  = "track"
+)
+
+// Entry is one named catalog row.
+type Entry struct {
+	Kind string
+	ID   int
+	Name string
+}
+
+// Watcher receives catalog changes.
+type Watcher interface {
+	Upsert(e Entry)
+	Remove(kind string, id int)
+}
+
+// Watchers fans changes out to every registered watcher. A nil Watchers is
+// valid and ignores changes, so repositories built without indexes still work.
+type Watchers []Watcher
+
+func (ws Watchers) Upsert(e Entry) {
+	for _, w := range ws {
+		w.Upsert(e)
+	}
+}
+
+func (ws Watchers) Remove(kind string, id int) {
+	for _, w := range ws {
+		w.Remove(kind, id)
+	}
+}
+
+var catalogQueries = []struct {
+	kind  string
+	query string
+}{
+	{Artist, "SELECT ArtistId, Name FROM Artist WHERE Name IS NOT NULL"},
+	{Album, "SELECT AlbumId, Title FROM Album"},
+	{Track, "SELECT TrackId, Name FROM Track"},
+}
+
+// LoadCatalog reads every artist, album and track name.
+func LoadCatalog(ctx context.Context, db *database.DB) ([]Entry, error) {
+	var entries []Entry
+	for _, q := range catalogQueries {
+		rows, err := db.QueryContext(ctx, q.query)
+		if err != nil {
+			return nil, fmt.Errorf("loading %ss: %w", q.kind, err)
+		}
+		for rows.Next() {
+			e := Entry{Kind: q.kind}
+			if err := rows.Scan(&e.ID, &e.Name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scanning %s: %w", q.kind, err)
+			}
+			entries = append(entries, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("loading %ss: %w", q.kind, err)
+		}
+	}
+	return entries, nil
+}
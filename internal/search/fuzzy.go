@@ -0,0 +1,188 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"chinook-api/internal/database"
+	"chinook-api/internal/models"
+)
+
+// MinSimilarity is the lowest trigram similarity reported as a suggestion,
+// the same default cut-off as PostgreSQL's pg_trgm.
+const MinSimilarity = 0.3
+
+// maxQueryRunes bounds the work done for a single lookup.
+const maxQueryRunes = 100
+
+type entryKey struct {
+	kind string
+	id   int
+}
+
+type fuzzyEntry struct {
+	name  string
+	grams []string
+}
+
+// FuzzyIndex finds catalog names similar to a possibly misspelled query by
+// trigram similarity: the number of shared three-letter sequences divided by
+// the number of distinct sequences in both strings. It is safe for
+// concurrent use.
+type FuzzyIndex struct {
+	mu       sync.RWMutex
+	entries  map[entryKey]fuzzyEntry
+	postings map[string]map[entryKey]struct{}
+}
+
+func NewFuzzyIndex() *FuzzyIndex {
+	return &FuzzyIndex{
+		entries:  map[entryKey]fuzzyEntry{},
+		postings: map[string]map[entryKey]struct{}{},
+	}
+}
+
+// Reload rebuilds the index from the database, replacing its contents.
+func (x *FuzzyIndex) Reload(ctx context.Context, db *database.DB) error {
+	entries, err := LoadCatalog(ctx, db)
+	if err != nil {
+		return err
+	}
+	fresh := NewFuzzyIndex()
+	for _, e := range entries {
+		fresh.add(e)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.entries, x.postings = fresh.entries, fresh.postings
+	return nil
+}
+
+// Len returns the number of indexed names.
+func (x *FuzzyIndex) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.entries)
+}
+
+func (x *FuzzyIndex) Upsert(e Entry) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(entryKey{e.Kind, e.ID})
+	x.add(e)
+}
+
+func (x *FuzzyIndex) Remove(kind string, id int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(entryKey{kind, id})
+}
+
+func (x *FuzzyIndex) add(e Entry) {
+	grams := trigrams(e.Name)
+	if len(grams) == 0 {
+		return
+	}
+	k := entryKey{e.Kind, e.ID}
+	x.entries[k] = fuzzyEntry{name: e.Name, grams: grams}
+	for _, g := range grams {
+		keys, ok := x.postings[g]
+		if !ok {
+			keys = map[entryKey]struct{}{}
+			x.postings[g] = keys
+		}
+		keys[k] = struct{}{}
+	}
+}
+
+func (x *FuzzyIndex) remove(k entryKey) {
+	old, ok := x.entries[k]
+	if !ok {
+		return
+	}
+	delete(x.entries, k)
+	for _, g := range old.grams {
+		delete(x.postings[g], k)
+		if len(x.postings[g]) == 0 {
+			delete(x.postings, g)
+		}
+	}
+}
+
+// Suggest returns up to limit names of the given kinds whose similarity to q
+// is at least MinSimilarity, most similar first. An empty kinds matches all.
+func (x *FuzzyIndex) Suggest(q string, kinds []string, limit int) []models.Suggestion {
+	if r := []rune(q); len(r) > maxQueryRunes {
+		q = string(r[:maxQueryRunes])
+	}
+	grams := trigrams(q)
+	suggestions := []models.Suggestion{}
+	if len(grams) == 0 || limit <= 0 {
+		return suggestions
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	shared := map[entryKey]int{}
+	for _, g := range grams {
+		for k := range x.postings[g] {
+			if len(kinds) == 0 || slices.Contains(kinds, k.kind) {
+				shared[k]++
+			}
+		}
+	}
+	for k, n := range shared {
+		e := x.entries[k]
+		score := float64(n) / float64(len(grams)+len(e.grams)-n)
+		if score < MinSimilarity {
+			continue
+		}
+		suggestions = append(suggestions, models.Suggestion{Type: k.kind, ID: k.id, Name: e.name, Score: score})
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.ID < b.ID
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// trigrams returns the distinct trigrams of s, lowercased and computed per
+// word with the word padded by two leading spaces and one trailing space,
+// so that word starts weigh more than word endings.
+func trigrams(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := map[string]struct{}{}
+	var grams []string
+	for _, w := range words {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			g := string(r[i : i+3])
+			if _, ok := seen[g]; !ok {
+				seen[g] = struct{}{}
+				grams = append(grams, g)
+			}
+		}
+	}
+	return grams
+}
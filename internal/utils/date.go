@@ -33,9 +33,10 @@ func (d *DateOnly) Scan(value interface{}) error {
 	return nil
 }
 
-// Value implements driver.Valuer to write DateOnly to the database
+// Value implements driver.Valuer to write DateOnly to the database, in the
+// same text form as the Chinook sample data
 func (d DateOnly) Value() (driver.Value, error) {
-	return d.Time, nil
+	return d.Time.Format(time.DateTime), nil
 }
 
 // MarshalJSON implements json.Marshaler
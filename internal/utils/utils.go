@@ -1,12 +1,10 @@
 package utils
 
 import (
+	"chinook-api/internal/apperr"
 	"fmt"
-	"net/http"
 	"time"
 
-	"os"
-
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/rs/zerolog/log"
@@ -16,11 +14,27 @@ import (
 	"encoding/base64"
 )
 
+var (
+	jwtSecret string
+	jwtTTL    = 24 * time.Hour
+)
+
+// ConfigureJWT sets the signing secret and lifetime used for access tokens.
+func ConfigureJWT(secret string, ttl time.Duration) {
+	jwtSecret = secret
+	jwtTTL = ttl
+}
+
 // GenerateJWT generates a JWT token for a username
 func GenerateJWT(username string) (string, error) {
+	return GenerateJWTWithTTL(username, jwtTTL)
+}
+
+// GenerateJWTWithTTL generates a JWT token for a username that expires after ttl
+func GenerateJWTWithTTL(username string, ttl time.Duration) (string, error) {
 	claims := jwt.MapClaims{
 		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
+		"exp":      time.Now().Add(ttl).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(getJWTSecret()))
@@ -40,8 +54,8 @@ func AuthMiddlewareJWT() gin.HandlerFunc {
 		}
 
 		if tokenString == "" {
-			c.Error(fmt.Errorf("missing token"))
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+			c.Error(apperr.Unauthorized("missing_token", "missing token"))
+			c.Abort()
 			return
 		}
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -52,31 +66,24 @@ func AuthMiddlewareJWT() gin.HandlerFunc {
 		})
 		if err != nil || !token.Valid {
 			log.Error().Err(err).Msg("Invalid token")
-			c.Error(fmt.Errorf("invalid token"))
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			c.Error(apperr.Unauthorized("invalid_token", "invalid or expired token"))
+			c.Abort()
 			return
 		}
 		c.Next()
 	}
 }
 
-func ParseInt(s string) int {
-	i := 0
-	fmt.Sscan(s, &i)
-	return i
-}
-
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
 }
 
 func getJWTSecret() string {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		panic("JWT_SECRET environment variable not set")
+	if jwtSecret == "" {
+		panic("JWT secret not configured")
 	}
-	return secret
+	return jwtSecret
 }
 
 func GenerateRefreshToken() (string, error) {
@@ -0,0 +1,150 @@
+// Package validation adds the address and contact rules used by the API's
+// request bodies to a go-playground validator:
+//
+//	country   a country name, e.g. "Brazil", "USA" or "United Kingdom"
+//	postcode  a postal code in the format of the country named by the field
+//	          given as parameter, e.g. postcode=Country
+//	phone     an international phone or fax number such as "+1 (780) 428-9482"
+package validation
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// postcodes holds the postal code formats of the countries in the catalog's
+// customer base. Other countries accept any short alphanumeric code.
+var postcodes = map[string]*regexp.Regexp{
+	"argentina":      regexp.MustCompile(`^([A-Za-z]\d{4}[A-Za-z]{3}|\d{4})$`),
+	"australia":      regexp.MustCompile(`^\d{4}$`),
+	"austria":        regexp.MustCompile(`^\d{4}$`),
+	"belgium":        regexp.MustCompile(`^\d{4}$`),
+	"brazil":         regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"canada":         regexp.MustCompile(`^[A-Za-z]\d[A-Za-z] ?\d[A-Za-z]\d$`),
+	"czech republic": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"denmark":        regexp.MustCompile(`^\d{4}$`),
+	"finland":        regexp.MustCompile(`^\d{5}$`),
+	"france":         regexp.MustCompile(`^\d{5}$`),
+	"germany":        regexp.MustCompile(`^\d{5}$`),
+	"hungary":        regexp.MustCompile(`^(H-)?\d{4}$`),
+	"india":          regexp.MustCompile(`^\d{3} ?\d{3}$`),
+	"italy":          regexp.MustCompile(`^\d{5}$`),
+	"netherlands":    regexp.MustCompile(`^\d{4} ?([A-Za-z]{2})?$`),
+	"norway":         regexp.MustCompile(`^\d{4}$`),
+	"poland":         regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"portugal":       regexp.MustCompile(`^\d{4}(-\d{3})?$`),
+	"spain":          regexp.MustCompile(`^\d{5}$`),
+	"sweden":         regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"united kingdom": regexp.MustCompile(`^[A-Za-z]{1,2}\d[A-Za-z\d]? ?\d[A-Za-z]{2}$`),
+	"united states":  regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+}
+
+var (
+	anyPostcode = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 -]{1,9}$`)
+	phoneChars  = regexp.MustCompile(`^\+?[0-9 ().-]+$`)
+)
+
+// Register adds the country, postcode and phone rules to v and makes it
+// report fields by their JSON names.
+func Register(v *validator.Validate) {
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || name == "" {
+			return f.Name
+		}
+		return name
+	})
+	v.RegisterValidation("country", validCountry)
+	v.RegisterValidation("postcode", validPostcode)
+	v.RegisterValidation("phone", validPhone)
+}
+
+func validCountry(fl validator.FieldLevel) bool {
+	_, ok := canonicalCountry(fl.Field().String())
+	return ok
+}
+
+// validPostcode checks the code against the format of the country in the
+// sibling field named by the tag parameter. Unknown or missing countries
+// fall back to the generic format; the country field reports its own error.
+func validPostcode(fl validator.FieldLevel) bool {
+	code := strings.TrimSpace(fl.Field().String())
+	pattern := anyPostcode
+	if country, ok := siblingString(fl, fl.Param()); ok {
+		if key, ok := canonicalCountry(country); ok && postcodes[key] != nil {
+			pattern = postcodes[key]
+		}
+	}
+	return pattern.MatchString(code)
+}
+
+func siblingString(fl validator.FieldLevel, name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	field := fl.Parent().FieldByName(name)
+	for field.IsValid() && field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return "", false
+		}
+		field = field.Elem()
+	}
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return "", false
+	}
+	return field.String(), true
+}
+
+// validPhone accepts digits with an optional leading +, spaces, dots,
+// hyphens and parentheses, holding 7 to 15 digits as E.164 allows.
+func validPhone(fl validator.FieldLevel) bool {
+	phone := fl.Field().String()
+	if !phoneChars.MatchString(phone) {
+		return false
+	}
+	digits := 0
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 7 && digits <= 15
+}
+
+// Message describes a failed rule in words for API responses.
+func Message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "country":
+		return "must be a country name"
+	case "postcode":
+		return "is not a valid postal code for the country"
+	case "phone":
+		return "must be a phone number of 7 to 15 digits"
+	case "max":
+		return "must be at most " + fe.Param() + " " + unit(fe)
+	case "min":
+		return "must be at least " + fe.Param() + " " + unit(fe)
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
+
+// unit is what the length rules of fe count.
+func unit(fe validator.FieldError) string {
+	if fe.Kind() == reflect.Slice {
+		return "items"
+	}
+	return "characters"
+}